@@ -0,0 +1,100 @@
+// Command import upserts the books of a CSV file or of MARC 21 records into
+// the catalogue of the database at DB_URL, just like POST
+// /api/v1/books/import, for files too large to upload comfortably.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/service"
+	"github.com/utilyre/lms/internal/store/bunstore"
+)
+
+var (
+	format string
+	dryRun bool
+)
+
+func init() {
+	flag.StringVar(&format, "format", "", "specify format of the file (csv, marc, marcxml), which is otherwise told by its extension")
+	flag.BoolVar(&dryRun, "dry-run", false, "report what the import would do without changing anything")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file>\n\nReads from stdin when the file is -.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		slog.Error("Failed to import books", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errors.New("expected exactly one file")
+	}
+
+	var r io.Reader = os.Stdin
+	if name := flag.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+
+		if format == "" {
+			format = service.ImportFormatOf(name)
+		}
+	}
+
+	db := bun.NewDB(
+		sql.OpenDB(
+			pgdriver.NewConnector(pgdriver.WithDSN(os.Getenv("DB_URL"))),
+		),
+		pgdialect.New(),
+	)
+	defer db.Close()
+
+	bookSVC := service.BookService{Store: bunstore.New(db)}
+	report, err := bookSVC.Import(ctx, service.BookImportParams{
+		Format: format,
+		Data:   r,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range report.Errors {
+		fmt.Println(rowErr)
+	}
+	summary := fmt.Sprintf("%d created, %d updated, %d unchanged, %d skipped",
+		report.Created, report.Updated, report.Unchanged, len(report.Errors))
+	if dryRun {
+		summary += " (dry run)"
+	}
+	fmt.Println(summary)
+
+	return nil
+}
@@ -0,0 +1,995 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/handler"
+	"github.com/utilyre/lms/internal/idempotency"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/model"
+)
+
+func TestUsersAPI(t *testing.T) {
+	h := newHarness(t)
+
+	jane := map[string]any{
+		"name":     "Jane Doe",
+		"email":    "jane@example.com",
+		"password": "correct horse",
+		"role":     "member",
+	}
+	h.expect(http.MethodPost, "/api/v1/users/", jane, http.StatusCreated, "create")
+	h.expect(http.MethodPost, "/api/v1/users/", jane, http.StatusConflict, "create_duplicate")
+	h.expect(http.MethodPost, "/api/v1/users/", map[string]any{
+		"name":     "John Doe",
+		"email":    "john",
+		"password": "correct horse",
+	}, http.StatusUnprocessableEntity, "create_invalid")
+
+	h.expect(http.MethodGet, "/api/v1/users/1", nil, http.StatusOK, "get")
+	h.expect(http.MethodGet, "/api/v1/users/0", nil, http.StatusUnprocessableEntity, "get_invalid")
+	h.expect(http.MethodPut, "/api/v1/users/1", map[string]any{
+		"name":  "Jane Roe",
+		"email": "roe@example.com",
+		"role":  "librarian",
+	}, http.StatusOK, "update")
+	h.expectPatch("/api/v1/users/1", map[string]any{
+		"name": "Jane Doe",
+		"role": nil,
+	}, http.StatusOK, "patch")
+	h.expectPatch("/api/v1/users/1", map[string]any{
+		"email": nil,
+	}, http.StatusUnprocessableEntity, "patch_invalid")
+	h.expect(http.MethodPatch, "/api/v1/users/1", map[string]any{
+		"name": "Jane Doe",
+	}, http.StatusUnsupportedMediaType, "patch_unsupported")
+
+	h.expect(http.MethodDelete, "/api/v1/users/1", nil, http.StatusOK, "delete")
+	h.expect(http.MethodGet, "/api/v1/users/1", nil, http.StatusNotFound, "get_deleted")
+	h.expect(http.MethodDelete, "/api/v1/users/1", nil, http.StatusNotFound, "delete_missing")
+	h.expect(http.MethodPut, "/api/v1/users/1", map[string]any{
+		"name":  "Jane Roe",
+		"email": "roe@example.com",
+	}, http.StatusNotFound, "update_missing")
+}
+
+func TestRolesAPI(t *testing.T) {
+	h := newHarness(t)
+
+	h.expectWith(http.MethodPost, "/api/v1/users/", anonymous, map[string]any{
+		"name":     "Jane Doe",
+		"email":    "jane@example.com",
+		"password": "correct horse",
+		"role":     "admin",
+	}, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodPost, "/api/v1/users/", anonymous, map[string]any{
+		"name":     "Jane Doe",
+		"email":    "jane@example.com",
+		"password": "correct horse",
+	}, http.StatusCreated, "register")
+	h.createUser("John Doe", "john@example.com")
+
+	member := h.login("jane@example.com")
+	h.expectWith(http.MethodPost, "/api/v1/users/", member, map[string]any{
+		"name":     "Mallory",
+		"email":    "mallory@example.com",
+		"password": "correct horse",
+		"role":     "admin",
+	}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPut, "/api/v1/users/1", member, map[string]any{
+		"name":  "Jane Doe",
+		"email": "jane@example.com",
+		"role":  "admin",
+	}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPatch, "/api/v1/users/1", http.Header{
+		echo.HeaderAuthorization: member.Values(echo.HeaderAuthorization),
+		echo.HeaderContentType:   {handler.MIMEApplicationMergePatchJSON},
+	}, map[string]any{"role": "admin"}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPut, "/api/v1/users/1", member, map[string]any{
+		"name":  "Jane Roe",
+		"email": "jane@example.com",
+	}, http.StatusOK, "update_self")
+	h.expectWith(http.MethodPut, "/api/v1/users/2", member, map[string]any{
+		"name":  "John Roe",
+		"email": "john@example.com",
+	}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2", member, nil, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2", anonymous, nil, http.StatusUnauthorized, "anonymous")
+
+	book := map[string]any{
+		"title":   "Dune",
+		"authors": []string{"Frank Herbert"},
+		"isbn":    "9780441013593",
+	}
+	h.expectWith(http.MethodPost, "/api/v1/books/", anonymous, book, http.StatusUnauthorized, "anonymous")
+	h.createBook("Dune", "Frank Herbert", "9780441013593")
+	h.expectWith(http.MethodPut, "/api/v1/books/1", anonymous, book, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1", anonymous, nil, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodPost, "/api/v1/books/", member, book, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPut, "/api/v1/books/1", member, book, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPatch, "/api/v1/books/1", http.Header{
+		echo.HeaderAuthorization: member.Values(echo.HeaderAuthorization),
+		echo.HeaderContentType:   {handler.MIMEApplicationMergePatchJSON},
+	}, map[string]any{"title": "Dune Messiah"}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1", member, nil, http.StatusForbidden, "forbidden")
+
+	john := h.login("john@example.com")
+	h.expectWith(http.MethodPost, "/api/v1/loans/", member, map[string]any{
+		"user_id": 2,
+		"book_id": 1,
+	}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPost, "/api/v1/loans/", member, map[string]any{
+		"user_id": 1,
+		"book_id": 1,
+	}, http.StatusCreated, "borrow_self")
+	h.expectWith(http.MethodPut, "/api/v1/loans/1", john, map[string]any{
+		"return_date": time.Now().Format(time.DateOnly),
+	}, http.StatusNotFound, "loan_not_found")
+	h.expectWith(http.MethodPost, "/api/v1/reservations/", member, map[string]any{
+		"user_id": 2,
+		"book_id": 1,
+	}, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPost, "/api/v1/reservations/", john, map[string]any{
+		"user_id": 2,
+		"book_id": 1,
+	}, http.StatusCreated, "reserve_self")
+	h.expectWith(http.MethodDelete, "/api/v1/reservations/1", member, nil, http.StatusNotFound, "reservation_not_found")
+
+	h.expectWith(http.MethodGet, "/api/v1/users/2", anonymous, nil, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodGet, "/api/v1/users/2", member, nil, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodGet, "/api/v1/reports/user-activity/2", anonymous, nil, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodGet, "/api/v1/reports/user-activity/2", member, nil, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodGet, "/api/v1/reports/overdue-loans", anonymous, nil, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodGet, "/api/v1/reports/overdue-loans", member, nil, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodGet, "/api/v1/reports/user-activity/1", member, nil, http.StatusOK, "user_activity_self")
+}
+
+func TestAdminRoutesAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createUser("John Doe", "john@example.com")
+	h.createBook("Dune", "Frank Herbert", "9780441013593")
+	h.expectWith(http.MethodPost, "/api/v1/users/", anonymous, map[string]any{
+		"name":     "Mallory",
+		"email":    "mallory@example.com",
+		"password": "correct horse",
+	}, http.StatusCreated, "register")
+	mallory := h.login("mallory@example.com")
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/api/v1/users/1/restore"},
+		{http.MethodDelete, "/api/v1/users/1/purge"},
+		{http.MethodGet, "/api/v1/users/1/export"},
+		{http.MethodPost, "/api/v1/users/1/anonymize"},
+		{http.MethodDelete, "/api/v1/users/1/lockout"},
+		{http.MethodDelete, "/api/v1/users/1/sessions"},
+		{http.MethodPost, "/api/v1/books/import"},
+		{http.MethodGet, "/api/v1/books/export"},
+		{http.MethodPost, "/api/v1/books/1/restore"},
+		{http.MethodDelete, "/api/v1/books/1/purge"},
+		{http.MethodPost, "/api/v1/categories/"},
+		{http.MethodDelete, "/api/v1/categories/1"},
+		{http.MethodGet, "/api/v1/audit-log"},
+		{http.MethodGet, "/api/v1/reports/overdue-loans"},
+	}
+	for _, route := range routes {
+		h.expectWith(route.method, route.path, mallory, nil, http.StatusForbidden, "forbidden")
+	}
+}
+
+func TestBooksAPI(t *testing.T) {
+	h := newHarness(t)
+
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":   "The Go Programming Language",
+		"authors": []string{"Alan Donovan"},
+		"isbn":    "9780134190440",
+	}, http.StatusCreated, "create")
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":   "The Go Programming Language",
+		"authors": []string{"Alan Donovan"},
+	}, http.StatusUnprocessableEntity, "create_invalid")
+
+	h.expect(http.MethodGet, "/api/v1/books/1", nil, http.StatusOK, "get")
+	h.expect(http.MethodPut, "/api/v1/books/1", map[string]any{
+		"title":               "The Go Programming Language",
+		"authors":             []string{"Alan A. A. Donovan"},
+		"isbn":                "9780134190440",
+		"availability_status": "lost",
+	}, http.StatusOK, "update")
+	h.expectPatch("/api/v1/books/1", map[string]any{
+		"authors":             []string{"Alan Donovan"},
+		"availability_status": nil,
+	}, http.StatusOK, "patch")
+
+	h.expect(http.MethodDelete, "/api/v1/books/1", nil, http.StatusOK, "delete")
+	h.expect(http.MethodGet, "/api/v1/books/1", nil, http.StatusNotFound, "get_deleted")
+	h.expect(http.MethodDelete, "/api/v1/books/1", nil, http.StatusNotFound, "delete_missing")
+	h.expectPatch("/api/v1/books/1", map[string]any{
+		"title": "The Go Programming Language",
+	}, http.StatusNotFound, "patch_missing")
+	h.expect(http.MethodPut, "/api/v1/books/1", map[string]any{
+		"title":   "The Go Programming Language",
+		"authors": []string{"Alan Donovan"},
+		"isbn":    "9780134190440",
+	}, http.StatusNotFound, "update_missing")
+}
+
+// TestBookAuthorAPI covers the single author older clients still send in
+// place of authors.
+func TestBookAuthorAPI(t *testing.T) {
+	h := newHarness(t)
+
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":  "Dune",
+		"author": "Frank Herbert",
+		"isbn":   "9780441013593",
+	}, http.StatusCreated, "create")
+	h.expectPatch("/api/v1/books/1", map[string]any{
+		"author": "Brian Herbert",
+	}, http.StatusOK, "patch")
+	h.expectPatch("/api/v1/books/1", map[string]any{
+		"authors": []string{"Frank Herbert"},
+		"author":  "Brian Herbert",
+	}, http.StatusOK, "patch_both")
+	h.expect(http.MethodPut, "/api/v1/books/1", map[string]any{
+		"title":  "Dune Messiah",
+		"author": "Brian Herbert",
+		"isbn":   "9780441013593",
+	}, http.StatusOK, "update")
+}
+
+func TestLoansAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	book := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+
+	h.expect(http.MethodPost, "/api/v1/loans/", map[string]any{
+		"user_id": jane,
+		"book_id": book,
+	}, http.StatusCreated, "borrow")
+	h.expect(http.MethodPost, "/api/v1/loans/", map[string]any{
+		"user_id": 0,
+		"book_id": book,
+	}, http.StatusUnprocessableEntity, "borrow_invalid")
+
+	h.expect(http.MethodPut, "/api/v1/loans/1", map[string]any{
+		"return_date": "2000-01-01",
+	}, http.StatusUnprocessableEntity, "return_early")
+	h.expect(http.MethodPut, "/api/v1/loans/1", map[string]any{
+		"return_date": time.Now().Format(time.DateOnly),
+	}, http.StatusOK, "return")
+	h.expect(http.MethodPut, "/api/v1/loans/1", map[string]any{
+		"return_date": time.Now().Format(time.DateOnly),
+	}, http.StatusConflict, "return_twice")
+	h.expect(http.MethodPut, "/api/v1/loans/2", map[string]any{
+		"return_date": time.Now().Format(time.DateOnly),
+	}, http.StatusNotFound, "return_missing")
+}
+
+func TestReservationsAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	john := h.createUser("John Doe", "john@example.com")
+	book := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+
+	h.expect(http.MethodPost, "/api/v1/reservations/", map[string]any{
+		"user_id": john,
+		"book_id": book,
+	}, http.StatusCreated, "reserve")
+	h.expect(http.MethodPost, "/api/v1/loans/", map[string]any{
+		"user_id": jane,
+		"book_id": book,
+	}, http.StatusConflict, "borrow_reserved")
+
+	h.expect(http.MethodDelete, "/api/v1/reservations/1", nil, http.StatusOK, "cancel")
+	h.expect(http.MethodDelete, "/api/v1/reservations/1", nil, http.StatusConflict, "cancel_twice")
+	h.expect(http.MethodDelete, "/api/v1/reservations/2", nil, http.StatusNotFound, "cancel_missing")
+	h.expect(http.MethodPost, "/api/v1/loans/", map[string]any{
+		"user_id": jane,
+		"book_id": book,
+	}, http.StatusCreated, "borrow")
+}
+
+func TestReportsAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	john := h.createUser("John Doe", "john@example.com")
+	gopl := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	kr := h.createBook("The C Programming Language", "Brian Kernighan", "9780131103627")
+	sicp := h.createBook("Structure and Interpretation", "Harold Abelson", "9780262510875")
+
+	h.borrow(jane, gopl)
+	h.borrow(john, gopl)
+	h.borrow(jane, kr)
+
+	overdue := model.Loan{
+		UserID:   john,
+		BookID:   sicp,
+		LoanDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:  time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	}
+	if _, err := h.db.NewInsert().Model(&overdue).Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	h.expect(http.MethodGet, "/api/v1/reports/popular-books", nil, http.StatusOK, "popular_books")
+	h.expect(http.MethodGet, "/api/v1/reports/overdue-loans", nil, http.StatusOK, "overdue_loans")
+	h.expect(http.MethodGet, fmt.Sprint("/api/v1/reports/user-activity/", jane), nil, http.StatusOK, "user_activity")
+}
+
+func TestSoftDeleteAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	book := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	admin := h.login("ada@example.com")
+
+	h.expect(http.MethodDelete, "/api/v1/books/1", nil, http.StatusOK, "delete_book")
+	h.expectWith(http.MethodPost, "/api/v1/books/1/restore", h.login("jane@example.com"), nil, http.StatusForbidden, "restore_forbidden")
+	h.expectWith(http.MethodPost, "/api/v1/books/1/restore", admin, nil, http.StatusOK, "restore_book")
+	h.expectWith(http.MethodPost, "/api/v1/books/1/restore", admin, nil, http.StatusConflict, "restore_live")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1/purge", admin, nil, http.StatusConflict, "purge_live")
+
+	h.expect(http.MethodDelete, "/api/v1/users/2", nil, http.StatusOK, "delete_user")
+	h.expect(http.MethodPost, "/api/v1/loans/", map[string]any{
+		"user_id": jane,
+		"book_id": book,
+	}, http.StatusNotFound, "borrow_deleted")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2/purge", admin, nil, http.StatusOK, "purge_user")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2/purge", admin, nil, http.StatusNotFound, "purge_missing")
+}
+
+func TestDeletionGuards(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	john := h.createUser("John Doe", "john@example.com")
+	book := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	h.borrow(jane, book)
+	h.reserve(john, book)
+
+	h.expect(http.MethodDelete, "/api/v1/users/2", nil, http.StatusConflict, "delete_user_in_use")
+	h.expect(http.MethodDelete, "/api/v1/books/1", nil, http.StatusConflict, "delete_book_in_use")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2?force=true", anonymous, nil, http.StatusUnauthorized, "force_anonymous")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1?force=true", anonymous, nil, http.StatusUnauthorized, "force_anonymous")
+	h.expectWith(http.MethodDelete, "/api/v1/users/2?force=true", h.login("jane@example.com"), nil, http.StatusForbidden, "force_forbidden")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1?force=true", h.login("jane@example.com"), nil, http.StatusForbidden, "force_forbidden")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1?force=true", h.login("ada@example.com"), nil, http.StatusOK, "force_delete_book")
+
+	h.expect(http.MethodPut, "/api/v1/loans/1", map[string]any{
+		"return_date": time.Now().Format(time.DateOnly),
+	}, http.StatusConflict, "return_closed")
+	h.expect(http.MethodDelete, "/api/v1/users/2", nil, http.StatusOK, "delete_user")
+}
+
+func TestPrivacyAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	loan := h.borrow(jane, h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440"))
+	admin := h.login("ada@example.com")
+
+	h.expectWith(http.MethodGet, "/api/v1/users/2/export", h.login("jane@example.com"), nil, http.StatusForbidden, "export_forbidden")
+	h.expectWith(http.MethodGet, "/api/v1/users/2/export?format=xml", admin, nil, http.StatusUnprocessableEntity, "export_invalid")
+
+	rec := h.send(http.MethodGet, "/api/v1/users/2/export", admin, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got, want := rec.Header().Get(echo.HeaderContentDisposition), `attachment; filename="user-2.json"`; got != want {
+		t.Errorf("Content-Disposition = %s; want %s", got, want)
+	}
+	var export struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+		Loans []struct {
+			ID int32 `json:"id"`
+		} `json:"loans"`
+		AuditLog []struct {
+			Action string `json:"action"`
+			Entity string `json:"entity"`
+		} `json:"audit_log"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	}
+	if export.User.Email != "jane@example.com" || len(export.Loans) != 1 || export.Loans[0].ID != loan {
+		t.Errorf("export = %+v; want jane with loan %d", export, loan)
+	}
+	if len(export.AuditLog) != 1 || export.AuditLog[0].Action != "create" || export.AuditLog[0].Entity != "user" {
+		t.Errorf("audit log = %+v; want jane's creation", export.AuditLog)
+	}
+
+	rec = h.send(http.MethodGet, "/api/v1/users/2/export?format=zip", admin, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export zip: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if want := []string{"user.json", "loans.json", "reservations.json", "api_keys.json", "login_attempts.json", "audit_log.json"}; !slices.Equal(names, want) {
+		t.Errorf("files = %v; want %v", names, want)
+	}
+
+	h.expectWith(http.MethodPost, "/api/v1/users/2/anonymize", admin, nil, http.StatusOK, "anonymize")
+	h.expect(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    "jane@example.com",
+		"password": "correct horse",
+	}, http.StatusUnauthorized, "login_anonymized")
+
+	rec = h.send(http.MethodGet, "/api/v1/audit-log?entity=user&entity_id=2", admin, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit log: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "Jane") || strings.Contains(body, "jane@") {
+		t.Errorf("audit log = %s; want no trace of jane", body)
+	}
+}
+
+func TestBookImportAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	h.createUser("Jane Doe", "jane@example.com")
+	h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	asCSV := func(header http.Header) http.Header {
+		header.Set(echo.HeaderContentType, handler.MIMETextCSV)
+		return header
+	}
+	admin := asCSV(h.login("ada@example.com"))
+
+	const catalogue = "title,author,isbn\n" +
+		"The Go Programming Language,Alan A. A. Donovan,9780134190440\n" +
+		"Dune,Frank Herbert,9780441013593\n" +
+		",Nobody,9780000000000\n"
+	h.expectWith(http.MethodPost, "/api/v1/books/import", asCSV(h.login("jane@example.com")), strings.NewReader(catalogue), http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodPost, "/api/v1/books/import", admin, strings.NewReader("title,author\n"), http.StatusUnprocessableEntity, "missing_column")
+	h.expectWith(http.MethodPost, "/api/v1/books/import?dry_run=true", admin, strings.NewReader(catalogue), http.StatusOK, "dry_run")
+	h.expect(http.MethodGet, "/api/v1/books/2", nil, http.StatusNotFound, "get_dry_run")
+	h.expectWith(http.MethodPost, "/api/v1/books/import", admin, strings.NewReader(catalogue), http.StatusOK, "import")
+	h.expect(http.MethodGet, "/api/v1/books/2", nil, http.StatusOK, "get")
+
+	// Importing the same file again changes nothing, this time as a form.
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	w, err := mw.CreateFormFile("file", "catalogue.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, catalogue); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	admin.Set(echo.HeaderContentType, mw.FormDataContentType())
+	h.expectWith(http.MethodPost, "/api/v1/books/import", admin, &form, http.StatusOK, "reimport")
+}
+
+func TestBookMARCAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	h.createUser("Jane Doe", "jane@example.com")
+	h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	admin := h.login("ada@example.com")
+
+	h.expect(http.MethodGet, "/api/v1/books/1/export?format=json", nil, http.StatusUnprocessableEntity, "bad_format")
+	h.expect(http.MethodGet, "/api/v1/books/2/export", nil, http.StatusNotFound, "not_found")
+	h.expectWith(http.MethodGet, "/api/v1/books/export", h.login("jane@example.com"), nil, http.StatusForbidden, "forbidden")
+
+	rec := h.send(http.MethodGet, "/api/v1/books/1/export", nil, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != marc.XMLMIMEType {
+		t.Fatalf("GET /api/v1/books/1/export: status = %d, content type = %q; want 200 and %s", rec.Code, rec.Header().Get(echo.HeaderContentType), marc.XMLMIMEType)
+	}
+	if !strings.Contains(rec.Body.String(), `<subfield code="a">The Go Programming Language</subfield>`) {
+		t.Errorf("GET /api/v1/books/1/export: body = %s; want the title in 245 $a", rec.Body)
+	}
+
+	const dune = `<record xmlns="http://www.loc.gov/MARC21/slim">
+  <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441013593</subfield></datafield>
+  <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank,</subfield></datafield>
+  <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Dune.</subfield></datafield>
+</record>`
+	admin.Set(echo.HeaderContentType, marc.XMLMIMEType)
+	h.expectWith(http.MethodPost, "/api/v1/books/import", admin, strings.NewReader(dune), http.StatusOK, "import")
+	h.expect(http.MethodGet, "/api/v1/books/2", nil, http.StatusOK, "get")
+
+	// The whole catalogue round-trips through ISO 2709 unchanged.
+	admin.Del(echo.HeaderContentType)
+	rec = h.send(http.MethodGet, "/api/v1/books/export?format=marc", admin, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != marc.MIMEType {
+		t.Fatalf("GET /api/v1/books/export?format=marc: status = %d, content type = %q; want 200 and %s", rec.Code, rec.Header().Get(echo.HeaderContentType), marc.MIMEType)
+	}
+	admin.Set(echo.HeaderContentType, marc.MIMEType)
+	h.expectWith(http.MethodPost, "/api/v1/books/import", admin, bytes.NewReader(rec.Body.Bytes()), http.StatusOK, "reimport")
+}
+
+func TestCatalogAPI(t *testing.T) {
+	h := newHarness(t)
+
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":            "The Go Programming Language",
+		"authors":          []string{"Alan Donovan", "Brian Kernighan"},
+		"publishers":       []string{"Addison-Wesley"},
+		"subjects":         []string{"Programming"},
+		"isbn":             "9780134190440",
+		"publication_year": 2015,
+		"language":         "eng",
+		"page_count":       380,
+	}, http.StatusCreated, "create")
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":   "The Go Programming Language",
+		"authors": []string{"Alan Donovan", "Alan Donovan"},
+		"isbn":    "9780134190440",
+	}, http.StatusUnprocessableEntity, "create_duplicate_author")
+	h.create("/api/v1/books/", map[string]any{
+		"title":            "The C Programming Language",
+		"authors":          []string{"Brian Kernighan", "Dennis Ritchie"},
+		"publishers":       []string{"Prentice Hall"},
+		"subjects":         []string{"Programming"},
+		"isbn":             "9780131103627",
+		"publication_year": 1988,
+		"language":         "eng",
+	})
+
+	h.expect(http.MethodGet, "/api/v1/books/?author_id=2", nil, http.StatusOK, "list_by_author")
+	h.expect(http.MethodGet, "/api/v1/books/?subject_id=1&year_from=2000", nil, http.StatusOK, "list_by_year")
+	h.expect(http.MethodGet, "/api/v1/books/?language=English", nil, http.StatusUnprocessableEntity, "list_invalid")
+
+	h.expect(http.MethodGet, "/api/v1/authors/?prefix=b", nil, http.StatusOK, "authors")
+	h.expect(http.MethodGet, "/api/v1/authors/2", nil, http.StatusOK, "author")
+	h.expect(http.MethodGet, "/api/v1/authors/99", nil, http.StatusNotFound, "author_not_found")
+	h.expect(http.MethodGet, "/api/v1/publishers/?after=Addison-Wesley", nil, http.StatusOK, "publishers")
+	h.expect(http.MethodGet, "/api/v1/subjects/1", nil, http.StatusOK, "subject")
+}
+
+func TestClassificationAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createAdmin("Ada Admin", "ada@example.com")
+	h.createUser("Jane Doe", "jane@example.com")
+	admin := h.login("ada@example.com")
+
+	h.expectWith(http.MethodPost, "/api/v1/categories/", h.login("jane@example.com"), map[string]any{
+		"name": "Science",
+	}, http.StatusForbidden, "create_category_forbidden")
+	h.expectWith(http.MethodPost, "/api/v1/categories/", admin, map[string]any{
+		"name": "Science",
+	}, http.StatusCreated, "create_category")
+	for _, category := range []map[string]any{
+		{"name": "Computing", "parent_id": 1},
+		{"name": "Astronomy", "parent_id": 1},
+	} {
+		if rec := h.send(http.MethodPost, "/api/v1/categories/", admin, category); rec.Code != http.StatusCreated {
+			t.Fatalf("create category %s: status = %d; want %d\n%s", category["name"], rec.Code, http.StatusCreated, rec.Body)
+		}
+	}
+	h.expectWith(http.MethodPost, "/api/v1/categories/", admin, map[string]any{
+		"name": "Science",
+	}, http.StatusConflict, "create_category_duplicate")
+	h.expectWith(http.MethodPost, "/api/v1/categories/", admin, map[string]any{
+		"name":      "Physics",
+		"parent_id": 99,
+	}, http.StatusUnprocessableEntity, "create_category_unknown_parent")
+
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":          "The Go Programming Language",
+		"authors":        []string{"Alan Donovan"},
+		"isbn":           "9780134190440",
+		"categories":     []int32{2},
+		"tags":           []string{"Go", "Programming"},
+		"classification": "lcc",
+		"call_number":    "QA76.73.G63 D66 2015",
+	}, http.StatusCreated, "create_book")
+	h.create("/api/v1/books/", map[string]any{
+		"title":          "Cosmos",
+		"authors":        []string{"Carl Sagan"},
+		"isbn":           "9780345539434",
+		"categories":     []int32{3},
+		"tags":           []string{"classics"},
+		"classification": "dewey",
+		"call_number":    "520 SAG",
+	})
+	h.create("/api/v1/books/", map[string]any{
+		"title":          "The C Programming Language",
+		"authors":        []string{"Brian Kernighan", "Dennis Ritchie"},
+		"isbn":           "9780131103627",
+		"classification": "lcc",
+		"call_number":    "QA76.73.C15 K47 1988",
+	})
+
+	h.expect(http.MethodGet, "/api/v1/categories/", nil, http.StatusOK, "tree")
+	h.expect(http.MethodGet, "/api/v1/categories/2", nil, http.StatusOK, "category")
+	h.expect(http.MethodGet, "/api/v1/categories/99", nil, http.StatusNotFound, "category_not_found")
+	h.expect(http.MethodGet, "/api/v1/books/?category_id=1&tag_id=3", nil, http.StatusOK, "list_by_category")
+	h.expect(http.MethodGet, "/api/v1/tags/?prefix=p", nil, http.StatusOK, "tags")
+	h.expect(http.MethodGet, "/api/v1/tags/99", nil, http.StatusNotFound, "tag_not_found")
+	h.expect(http.MethodGet, "/api/v1/books/shelf?classification=lcc&to=QA76.73", nil, http.StatusOK, "shelf")
+	h.expect(http.MethodGet, "/api/v1/books/shelf?classification=udc", nil, http.StatusUnprocessableEntity, "shelf_invalid")
+
+	h.expectWith(http.MethodDelete, "/api/v1/categories/1", admin, nil, http.StatusConflict, "delete_category_not_empty")
+	h.expectWith(http.MethodDelete, "/api/v1/categories/3", admin, nil, http.StatusOK, "delete_category")
+	h.expect(http.MethodGet, "/api/v1/books/2", nil, http.StatusOK, "get_book")
+
+	h.expect(http.MethodPost, "/api/v1/books/", map[string]any{
+		"title":      "Dune",
+		"authors":    []string{"Frank Herbert"},
+		"isbn":       "9780441013593",
+		"categories": []int32{3},
+	}, http.StatusUnprocessableEntity, "create_book_unknown_category")
+}
+
+func TestConditionalRequests(t *testing.T) {
+	h := newHarness(t)
+	book := map[string]any{
+		"title":   "The Go Programming Language",
+		"authors": []string{"Alan Donovan"},
+		"isbn":    "9780134190440",
+	}
+
+	rec := h.expect(http.MethodPost, "/api/v1/books/", book, http.StatusCreated, "create")
+	if got := rec.Header().Get(handler.HeaderETag); got != `"1"` {
+		t.Fatalf("ETag = %s; want %q", got, `"1"`)
+	}
+
+	rec = h.send(http.MethodGet, "/api/v1/books/1", http.Header{
+		handler.HeaderIfNoneMatch: {`W/"1"`},
+	}, nil)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("GET with matching If-None-Match: status = %d, body = %q; want 304 without body", rec.Code, rec.Body)
+	}
+
+	book["availability_status"] = "lost"
+	h.expectWith(http.MethodPut, "/api/v1/books/1", http.Header{
+		handler.HeaderIfMatch: {`"2"`},
+	}, book, http.StatusPreconditionFailed, "update_stale")
+	rec = h.expectWith(http.MethodPut, "/api/v1/books/1", http.Header{
+		handler.HeaderIfMatch: {`"3", "1"`},
+	}, book, http.StatusOK, "update")
+	if got := rec.Header().Get(handler.HeaderETag); got != `"2"` {
+		t.Fatalf("ETag = %s; want %q", got, `"2"`)
+	}
+
+	h.expectWith(http.MethodPatch, "/api/v1/books/1", http.Header{
+		echo.HeaderContentType: {handler.MIMEApplicationMergePatchJSON},
+		handler.HeaderIfMatch:  {`"1"`},
+	}, map[string]any{"availability_status": nil}, http.StatusPreconditionFailed, "patch_stale")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1", http.Header{
+		handler.HeaderIfMatch: {`W/"2"`},
+	}, nil, http.StatusPreconditionFailed, "delete_weak")
+	h.expectWith(http.MethodDelete, "/api/v1/books/1", http.Header{
+		handler.HeaderIfMatch: {`"2"`},
+	}, nil, http.StatusOK, "delete")
+}
+
+func TestIdempotentBorrow(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	book := h.createBook("The Go Programming Language", "Alan Donovan", "9780134190440")
+	header := http.Header{idempotency.HeaderIdempotencyKey: {"borrow-1"}}
+	body := map[string]any{
+		"user_id": jane,
+		"book_id": book,
+	}
+
+	h.expectWith(http.MethodPost, "/api/v1/loans/", header, body, http.StatusCreated, "borrow")
+	rec := h.expectWith(http.MethodPost, "/api/v1/loans/", header, body, http.StatusCreated, "borrow")
+	if got := rec.Header().Get(idempotency.HeaderReplayed); got != "true" {
+		t.Errorf("%s = %q; want %q", idempotency.HeaderReplayed, got, "true")
+	}
+
+	body["book_id"] = book + 1
+	h.expectWith(http.MethodPost, "/api/v1/loans/", header, body, http.StatusUnprocessableEntity, "borrow_reused")
+	h.expect(http.MethodGet, fmt.Sprint("/api/v1/reports/user-activity/", jane), nil, http.StatusOK, "user_activity")
+}
+
+var reResetToken = regexp.MustCompile(`(?m)^[\w-]{43}$`)
+
+func TestPasswordsAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	path := fmt.Sprint("/api/v1/users/", jane, "/password")
+
+	h.expect(http.MethodPut, path, map[string]any{
+		"current_password": "wrong",
+		"new_password":     "battery staple",
+	}, http.StatusUnprocessableEntity, "change_wrong")
+	h.expect(http.MethodPut, path, map[string]any{
+		"current_password": "correct horse",
+		"new_password":     "battery staple",
+	}, http.StatusOK, "change")
+	h.expect(http.MethodPut, fmt.Sprint("/api/v1/users/", jane+1, "/password"), map[string]any{
+		"current_password": "correct horse",
+		"new_password":     "battery staple",
+	}, http.StatusNotFound, "change_missing")
+
+	h.expect(http.MethodPost, "/api/v1/password-resets/", map[string]any{
+		"email": "john@example.com",
+	}, http.StatusAccepted, "request")
+	if msgs := h.mail.messages(); len(msgs) != 0 {
+		t.Fatalf("mailed %d messages for an unknown email; want none", len(msgs))
+	}
+	h.expect(http.MethodPost, "/api/v1/password-resets/", map[string]any{
+		"email": "jane@example.com",
+	}, http.StatusAccepted, "request")
+
+	msgs := h.mail.messages()
+	if len(msgs) != 1 || msgs[0].To != "jane@example.com" {
+		t.Fatalf("messages = %+v; want one to jane@example.com", msgs)
+	}
+	token := reResetToken.FindString(msgs[0].Body)
+	if token == "" {
+		t.Fatalf("no token in message:\n%s", msgs[0].Body)
+	}
+
+	reset := map[string]any{
+		"token":    token,
+		"password": "horse battery",
+	}
+	h.expect(http.MethodPost, "/api/v1/password-resets/confirm", reset, http.StatusOK, "reset")
+	h.expect(http.MethodPost, "/api/v1/password-resets/confirm", reset, http.StatusUnprocessableEntity, "reset_used")
+	h.expect(http.MethodPut, path, map[string]any{
+		"current_password": "horse battery",
+		"new_password":     "correct horse",
+	}, http.StatusOK, "change")
+}
+
+func TestAuthAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+
+	rec := h.do(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    "jane@example.com",
+		"password": "correct horse",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.TokenType != "Bearer" || resp.ExpiresIn <= 0 {
+		t.Errorf("login response = %+v; want a bearer token that expires", resp)
+	}
+	id, err := auth.Tokens{Secret: []byte("test")}.Parse(resp.AccessToken)
+	if err != nil || id.UserID != jane {
+		t.Errorf("token identity = %+v, %v; want user %d", id, err, jane)
+	}
+
+	h.expect(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    "jane@example.com",
+		"password": "battery staple",
+	}, http.StatusUnauthorized, "login_wrong")
+	h.expect(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    "john@example.com",
+		"password": "correct horse",
+	}, http.StatusUnauthorized, "login_wrong")
+	h.expectWith(http.MethodGet, fmt.Sprint("/api/v1/users/", jane), http.Header{
+		echo.HeaderAuthorization: {"Bearer bogus"},
+	}, nil, http.StatusUnauthorized, "invalid_token")
+}
+
+func TestLoginLockout(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	h.createUser("John Doe", "john@example.com")
+	h.createAdmin("Ada Admin", "ada@example.com")
+	wrong := map[string]any{
+		"email":    "jane@example.com",
+		"password": "battery staple",
+	}
+
+	for range 5 {
+		h.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized, "login_wrong")
+	}
+	rec := h.expect(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    "jane@example.com",
+		"password": "correct horse",
+	}, http.StatusTooManyRequests, "login_locked")
+	if got := rec.Header().Get(echo.HeaderRetryAfter); got != "30" {
+		t.Errorf("%s = %q; want %q", echo.HeaderRetryAfter, got, "30")
+	}
+
+	path := fmt.Sprint("/api/v1/users/", jane, "/lockout")
+	h.expectWith(http.MethodDelete, path, anonymous, nil, http.StatusUnauthorized, "unlock_anonymous")
+	h.expectWith(http.MethodDelete, path, h.login("john@example.com"), nil, http.StatusForbidden, "unlock_forbidden")
+	h.expectWith(http.MethodDelete, path, h.login("ada@example.com"), nil, http.StatusOK, "unlock")
+	h.login("jane@example.com")
+}
+
+func TestSessionsAPI(t *testing.T) {
+	h := newHarness(t)
+	jane := h.createUser("Jane Doe", "jane@example.com")
+	h.createAdmin("Ada Admin", "ada@example.com")
+
+	refresh := func(token string) string {
+		t.Helper()
+		rec := h.do(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refresh_token": token})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("refresh: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+		}
+
+		var resp struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.RefreshToken
+	}
+	login := func() string {
+		t.Helper()
+		rec := h.do(http.MethodPost, "/api/v1/auth/login", map[string]any{
+			"email":    "jane@example.com",
+			"password": "correct horse",
+		})
+
+		var resp struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.RefreshToken
+	}
+
+	first := login()
+	second := refresh(first)
+	h.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refresh_token": first}, http.StatusUnauthorized, "refresh_invalid")
+	h.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refresh_token": second}, http.StatusUnauthorized, "refresh_invalid")
+	h.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]any{}, http.StatusUnprocessableEntity, "refresh_missing")
+
+	token := login()
+	h.expect(http.MethodPost, "/api/v1/auth/logout", map[string]any{"refresh_token": token}, http.StatusOK, "logout")
+	h.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refresh_token": token}, http.StatusUnauthorized, "refresh_invalid")
+
+	token = login()
+	path := fmt.Sprint("/api/v1/users/", jane, "/sessions")
+	h.expectWith(http.MethodDelete, path, h.login("jane@example.com"), nil, http.StatusForbidden, "revoke_forbidden")
+	h.expectWith(http.MethodDelete, path, h.login("ada@example.com"), nil, http.StatusOK, "revoke")
+	h.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refresh_token": token}, http.StatusUnauthorized, "refresh_invalid")
+}
+
+func TestAPIKeysAPI(t *testing.T) {
+	h := newHarness(t)
+	h.createUser("Jane Doe", "jane@example.com")
+	book := h.createBook("Dune", "Frank Herbert", "9780441013593")
+	jane := h.login("jane@example.com")
+
+	h.expectWith(http.MethodPost, "/api/v1/api-keys/", anonymous, map[string]any{
+		"name":   "Kiosk",
+		"scopes": []string{"books:read"},
+	}, http.StatusUnauthorized, "anonymous")
+	h.expectWith(http.MethodPost, "/api/v1/api-keys/", jane, map[string]any{
+		"name":   "Kiosk",
+		"scopes": []string{"fines:read"},
+	}, http.StatusUnprocessableEntity, "create_invalid_scope")
+
+	rec := h.send(http.MethodPost, "/api/v1/api-keys/", jane, map[string]any{
+		"name":   "Kiosk",
+		"scopes": []string{"books:read"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d; want %d\n%s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var created struct {
+		ID  int32  `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	kiosk := http.Header{echo.HeaderAuthorization: {"Bearer " + created.Key}}
+
+	if rec := h.send(http.MethodGet, fmt.Sprint("/api/v1/books/", book), kiosk, nil); rec.Code != http.StatusOK {
+		t.Fatalf("get book with key: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+	h.expectWith(http.MethodPost, "/api/v1/books/", kiosk, map[string]any{
+		"title":   "Emma",
+		"authors": []string{"Jane Austen"},
+		"isbn":    "9780141439587",
+	}, http.StatusForbidden, "insufficient_scope")
+	h.expectWith(http.MethodGet, "/api/v1/api-keys/", kiosk, nil, http.StatusForbidden, "insufficient_scope")
+
+	rec = h.send(http.MethodGet, "/api/v1/api-keys/", jane, nil)
+	var keys []struct {
+		Name       string     `json:"name"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Name != "Kiosk" || keys[0].LastUsedAt == nil {
+		t.Errorf("keys = %+v; want the used kiosk key", keys)
+	}
+
+	path := fmt.Sprint("/api/v1/api-keys/", created.ID)
+	h.expectWith(http.MethodDelete, path, jane, nil, http.StatusOK, "delete")
+	h.expectWith(http.MethodDelete, path, jane, nil, http.StatusNotFound, "delete_missing")
+	h.expectWith(http.MethodGet, fmt.Sprint("/api/v1/books/", book), kiosk, nil, http.StatusUnauthorized, "invalid_key")
+}
+
+func TestAuditLogAPI(t *testing.T) {
+	h := newHarness(t)
+	ada := h.createAdmin("Ada Admin", "ada@example.com")
+	h.createUser("Jane Doe", "jane@example.com")
+	admin := h.login("ada@example.com")
+
+	rec := h.send(http.MethodPost, "/api/v1/books/", admin, map[string]any{
+		"title":   "Dune",
+		"authors": []string{"Frank Herbert"},
+		"isbn":    "9780441013593",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create book: status = %d; want %d\n%s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var book struct {
+		ID int32 `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&book); err != nil {
+		t.Fatal(err)
+	}
+	if rec := h.send(http.MethodDelete, fmt.Sprint("/api/v1/books/", book.ID), admin, nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete book: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	rec = h.send(http.MethodGet, fmt.Sprint("/api/v1/audit-log?entity=book&entity_id=", book.ID), admin, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status = %d; want %d\n%s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var entries []struct {
+		ActorID   *int32         `json:"actor_id"`
+		Action    string         `json:"action"`
+		Before    map[string]any `json:"before"`
+		After     map[string]any `json:"after"`
+		IP        string         `json:"ip"`
+		RequestID string         `json:"request_id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "delete" || entries[1].Action != "create" {
+		t.Fatalf("entries = %+v; want the deletion and creation of the book", entries)
+	}
+	for i, entry := range entries {
+		if entry.ActorID == nil || *entry.ActorID != ada {
+			t.Errorf("entries[%d].actor_id = %v; want %d", i, entry.ActorID, ada)
+		}
+		if entry.IP == "" || entry.RequestID == "" {
+			t.Errorf("entries[%d] = %+v; want its ip and request id", i, entry)
+		}
+	}
+	if entries[0].Before["title"] != "Dune" || entries[0].After != nil {
+		t.Errorf("deletion = %+v; want the book before it and nothing after", entries[0])
+	}
+
+	h.expectWith(http.MethodGet, "/api/v1/audit-log", h.login("jane@example.com"), nil, http.StatusForbidden, "forbidden")
+	h.expectWith(http.MethodGet, "/api/v1/audit-log?limit=1000", admin, nil, http.StatusUnprocessableEntity, "limit_too_large")
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/handler"
+	"github.com/utilyre/lms/internal/mail"
+	"github.com/utilyre/lms/internal/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The integration harness runs against the Postgres at LMS_TEST_DB_URL or,
+// when that's unset, against a throwaway cluster launched with the initdb and
+// pg_ctl binaries found on the machine. The cache is the Redis at
+// LMS_TEST_CACHE_URL or an in-process miniredis.
+//
+// Tests that need it call newHarness, which skips them when no Postgres is
+// available.
+
+var update = flag.Bool("update", false, "rewrite golden files with actual responses")
+
+var (
+	testDB    *bun.DB
+	testDBErr error
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	var stop func()
+	if !testing.Short() {
+		testDB, stop, testDBErr = openTestDB()
+	}
+
+	code := m.Run()
+
+	if testDB != nil {
+		_ = testDB.Close()
+	}
+	if stop != nil {
+		stop()
+	}
+	os.Exit(code)
+}
+
+func openTestDB() (*bun.DB, func(), error) {
+	dsn := os.Getenv("LMS_TEST_DB_URL")
+	stop := func() {}
+	if dsn == "" {
+		var err error
+		dsn, stop, err = startPostgres()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	db := bun.NewDB(
+		sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))),
+		pgdialect.New(),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := migrate(ctx, db, "../../migrations"); err != nil {
+		_ = db.Close()
+		stop()
+		return nil, nil, err
+	}
+
+	return db, stop, nil
+}
+
+func findPostgresBin(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%s not found", name)
+	}
+
+	slices.Sort(matches)
+	return matches[len(matches)-1], nil
+}
+
+func startPostgres() (dsn string, stop func(), err error) {
+	initdb, err := findPostgresBin("initdb")
+	if err != nil {
+		return "", nil, err
+	}
+	pgctl, err := findPostgresBin("pg_ctl")
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "lms-postgres-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(
+		initdb, "-D", data, "-U", "lms", "-A", "trust", "-E", "UTF8", "--no-sync",
+	).CombinedOutput(); err != nil {
+		return "", nil, fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", nil, err
+	}
+
+	opts := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -F", port, dir)
+	if out, err := exec.Command(
+		pgctl, "-D", data, "-o", opts, "-l", filepath.Join(dir, "postgres.log"), "-w", "start",
+	).CombinedOutput(); err != nil {
+		return "", nil, fmt.Errorf("pg_ctl start: %w: %s", err, out)
+	}
+
+	stop = func() {
+		_ = exec.Command(pgctl, "-D", data, "-m", "immediate", "stop").Run()
+		_ = os.RemoveAll(dir)
+	}
+
+	return fmt.Sprintf("postgres://lms@127.0.0.1:%d/postgres?sslmode=disable", port), stop, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// migrate applies the Up section of every goose migration in dir in order.
+func migrate(ctx context.Context, db *bun.DB, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		_, up, ok := strings.Cut(string(data), "-- +goose Up")
+		if !ok {
+			return fmt.Errorf("%s: missing up section", file)
+		}
+		up, _, _ = strings.Cut(up, "-- +goose Down")
+
+		lines := strings.Split(up, "\n")
+		lines = slices.DeleteFunc(lines, func(line string) bool {
+			return strings.HasPrefix(strings.TrimSpace(line), "-- +goose")
+		})
+
+		if _, err := db.ExecContext(ctx, strings.Join(lines, "\n")); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+type harness struct {
+	t    *testing.T
+	db   *bun.DB
+	e    *echo.Echo
+	mail *mailbox
+	// operator authorizes the requests that don't have an Authorization
+	// header of their own.
+	operator string
+}
+
+// operatorID is the user ID of the operator of the harness, an admin with no
+// user behind them that no test should ever reach.
+const operatorID = math.MaxInt32
+
+// anonymous is a header that makes requests go without authorization.
+var anonymous = http.Header{echo.HeaderAuthorization: nil}
+
+// mailbox keeps the messages sent by the server instead of delivering them.
+type mailbox struct {
+	mu   sync.Mutex
+	msgs []mail.Message
+}
+
+func (mb *mailbox) Send(_ context.Context, msg mail.Message) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.msgs = append(mb.msgs, msg)
+	return nil
+}
+
+func (mb *mailbox) messages() []mail.Message {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return slices.Clone(mb.msgs)
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	if testDBErr != nil {
+		t.Skipf("skipping integration test: no postgres: %v", testDBErr)
+	}
+
+	ctx := context.Background()
+	if _, err := testDB.ExecContext(ctx, `TRUNCATE "users", "books", "authors", "publishers", "subjects", "categories", "tags", "loans", "reservations", "audit_log" RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheURL := os.Getenv("LMS_TEST_CACHE_URL")
+	if cacheURL == "" {
+		cacheURL = miniredis.RunT(t).Addr()
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: cacheURL})
+	t.Cleanup(func() { _ = rdb.Close() })
+	if err := rdb.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := auth.Tokens{Secret: []byte("test")}
+	operator, _, err := tokens.Issue(auth.Identity{UserID: operatorID, Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mb := new(mailbox)
+	e := newServer(logger, testDB, rdb, serverConfig{
+		Mailer: mb,
+		Tokens: tokens,
+		Hasher: password.Hasher{BcryptCost: bcrypt.MinCost},
+	})
+	return &harness{t: t, db: testDB, e: e, mail: mb, operator: "Bearer " + operator}
+}
+
+// do sends a request with body encoded as JSON, unless it is nil, to the
+// server and returns the recorded response.
+func (h *harness) do(method, path string, body any) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.send(method, path, nil, body)
+}
+
+// send is like do but adds header to the request. The request is made by the
+// operator of the harness unless header has an Authorization, which the
+// anonymous header clears. The body is sent as is when it's an io.Reader, and
+// labeled as JSON unless header has a Content-Type.
+func (h *harness) send(method, path string, header http.Header, body any) *httptest.ResponseRecorder {
+	h.t.Helper()
+
+	var r io.Reader
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		r = body
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set(echo.HeaderAuthorization, h.operator)
+	maps.Copy(req.Header, header)
+	if body != nil && req.Header.Get(echo.HeaderContentType) == "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+
+	rec := httptest.NewRecorder()
+	h.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect sends a request and fails the test unless the response has the given
+// status and its body matches testdata/<test name>/<golden>.json.
+func (h *harness) expect(method, path string, body any, status int, golden string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.expectWith(method, path, nil, body, status, golden)
+}
+
+// expectPatch is like expect but sends body as a JSON Merge Patch.
+func (h *harness) expectPatch(path string, body any, status int, golden string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.expectWith(http.MethodPatch, path, http.Header{
+		echo.HeaderContentType: {handler.MIMEApplicationMergePatchJSON},
+	}, body, status, golden)
+}
+
+// expectWith is like expect but adds header to the request.
+func (h *harness) expectWith(method, path string, header http.Header, body any, status int, golden string) *httptest.ResponseRecorder {
+	h.t.Helper()
+
+	rec := h.send(method, path, header, body)
+	h.check(rec, method, path, status, golden)
+	return rec
+}
+
+func (h *harness) check(rec *httptest.ResponseRecorder, method, path string, status int, golden string) {
+	h.t.Helper()
+	if rec.Code != status {
+		h.t.Fatalf("%s %s: status = %d; want %d\n%s", method, path, rec.Code, status, rec.Body)
+	}
+
+	assertGolden(h.t, golden, rec.Body.Bytes())
+}
+
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, body)
+	}
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(scrubDates(got), '\n')
+
+	path := filepath.Join("testdata", t.Name(), name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist; rerun with -update to create it", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("response does not match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// scrubDates replaces the dates the server derives from the current time
+// with placeholders so that golden files stay stable.
+func scrubDates(data []byte) []byte {
+	var oldnew []string
+	for _, now := range []time.Time{time.Now(), time.Now().UTC()} {
+		oldnew = append(oldnew,
+			now.Format(time.DateOnly), "<today>",
+			now.Add(14*24*time.Hour).Format(time.DateOnly), "<today+14d>",
+		)
+	}
+
+	return []byte(strings.NewReplacer(oldnew...).Replace(string(data)))
+}
+
+func (h *harness) createUser(name, email string) int32 {
+	h.t.Helper()
+	return h.create("/api/v1/users/", map[string]any{
+		"name":     name,
+		"email":    email,
+		"password": "correct horse",
+		"role":     "member",
+	})
+}
+
+func (h *harness) createAdmin(name, email string) int32 {
+	h.t.Helper()
+	return h.create("/api/v1/users/", map[string]any{
+		"name":     name,
+		"email":    email,
+		"password": "correct horse",
+		"role":     "admin",
+	})
+}
+
+// login returns an Authorization header with an access token of the user with
+// the given email, whose password must be the one createUser sets.
+func (h *harness) login(email string) http.Header {
+	h.t.Helper()
+
+	rec := h.do(http.MethodPost, "/api/v1/auth/login", map[string]any{
+		"email":    email,
+		"password": "correct horse",
+	})
+	if rec.Code != http.StatusOK {
+		h.t.Fatalf("login as %s: status = %d; want %d\n%s", email, rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		h.t.Fatal(err)
+	}
+
+	return http.Header{echo.HeaderAuthorization: {"Bearer " + resp.AccessToken}}
+}
+
+func (h *harness) createBook(title, author, isbn string) int32 {
+	h.t.Helper()
+	return h.create("/api/v1/books/", map[string]any{
+		"title":   title,
+		"authors": []string{author},
+		"isbn":    isbn,
+	})
+}
+
+func (h *harness) borrow(userID, bookID int32) int32 {
+	h.t.Helper()
+	return h.create("/api/v1/loans/", map[string]any{
+		"user_id": userID,
+		"book_id": bookID,
+	})
+}
+
+func (h *harness) reserve(userID, bookID int32) int32 {
+	h.t.Helper()
+	return h.create("/api/v1/reservations/", map[string]any{
+		"user_id": userID,
+		"book_id": bookID,
+	})
+}
+
+func (h *harness) create(path string, body any) int32 {
+	h.t.Helper()
+
+	rec := h.do(http.MethodPost, path, body)
+	if rec.Code != http.StatusCreated {
+		h.t.Fatalf("POST %s: status = %d; want %d\n%s", path, rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	var resp struct {
+		ID int32 `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		h.t.Fatal(err)
+	}
+
+	return resp.ID
+}
@@ -1,57 +1,297 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
-	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/extra/bunotel"
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/auth"
 	"github.com/utilyre/lms/internal/handler"
+	"github.com/utilyre/lms/internal/logging"
+	"github.com/utilyre/lms/internal/mail"
+	"github.com/utilyre/lms/internal/metrics"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/ratelimit"
 	"github.com/utilyre/lms/internal/service"
+	"github.com/utilyre/lms/internal/store/bunstore"
+	"github.com/utilyre/lms/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
-var listenPort string
+// defaultRateLimits keep kiosks from hammering circulation and the
+// reports, which are the most expensive routes to serve, and slow down
+// guessing of passwords and password reset tokens.
+const defaultRateLimits = "POST /api/v1/loans/=30/1m, POST /api/v1/reservations/=30/1m, " +
+	"GET /api/v1/reports/*=20/1m, POST /api/v1/password-resets/*=5/15m, POST /api/v1/auth/*=10/1m, " +
+	"* /api/v1/*=300/1m"
+
+var (
+	listenPort        string
+	logLevel          string
+	rateLimits        string
+	passwordHash      string
+	passwordMinLength int
+	breachedPasswords string
+	trustedProxies    string
+)
 
 func init() {
 	flag.StringVar(&listenPort, "port", "8080", "specify port to listen on")
-	flag.Parse()
+	flag.StringVar(&logLevel, "log-level", os.Getenv("LOG_LEVEL"), "specify minimum log level (debug, info, warn, error)")
+	flag.StringVar(&rateLimits, "rate-limits", cmp.Or(os.Getenv("RATE_LIMITS"), defaultRateLimits), "specify per-route rate limits as comma-separated '<method> <path>=<limit>/<window>' rules")
+	flag.StringVar(&passwordHash, "password-hash", cmp.Or(os.Getenv("PASSWORD_HASH"), password.Bcrypt), "specify algorithm to hash passwords with (bcrypt, argon2id)")
+	flag.IntVar(&passwordMinLength, "password-min-length", 8, "specify minimum length of passwords")
+	flag.StringVar(&breachedPasswords, "breached-passwords", os.Getenv("BREACHED_PASSWORDS_FILE"), "specify file listing breached passwords to reject, one per line")
+	flag.StringVar(&trustedProxies, "trusted-proxies", os.Getenv("TRUSTED_PROXIES"), "specify comma-separated CIDR ranges of proxies whose X-Forwarded-For header to trust")
 }
 
 func main() {
-	log.Printf("Connecting to database: %s\n", os.Getenv("DB_URL"))
+	flag.Parse()
+
+	if err := run(); err != nil {
+		slog.Error("Failed to run server", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	limits, err := ratelimit.ParseRules(rateLimits)
+	if err != nil {
+		return err
+	}
+
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return err
+	}
+
+	hasher := password.Hasher{Algorithm: passwordHash}
+	if err := hasher.Validate(); err != nil {
+		return err
+	}
+
+	policy := service.PasswordPolicy{MinLength: passwordMinLength}
+	if breachedPasswords != "" {
+		policy.Breached, err = service.LoadBreachedPasswords(breachedPasswords)
+		if err != nil {
+			return err
+		}
+		logger.Info("Loaded breached passwords", "count", len(policy.Breached))
+	}
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return errors.New("JWT_SECRET is not set")
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	logger.Info("Connecting to database", "url", os.Getenv("DB_URL"))
 	db := bun.NewDB(
 		sql.OpenDB(
 			pgdriver.NewConnector(pgdriver.WithDSN(os.Getenv("DB_URL"))),
 		),
 		pgdialect.New(),
 	)
-	db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+	db.AddQueryHook(logging.QueryHook{Logger: logger})
+	db.AddQueryHook(metrics.QueryHook{})
+	db.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName("lms")))
 
-	log.Printf("Connecting to cache: %s\n", os.Getenv("CACHE_URL"))
+	logger.Info("Connecting to cache", "url", os.Getenv("CACHE_URL"))
 	rdb := redis.NewClient(&redis.Options{Addr: os.Getenv("CACHE_URL")})
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		return err
+	}
+
+	prometheus.MustRegister(
+		metrics.NewDBStatsCollector(db),
+		metrics.NewRedisPoolCollector(rdb),
+		metrics.NewCirculationCollector(db),
+	)
+
+	var mailer mail.Mailer = mail.LogMailer{Logger: logger}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		logger.Info("Writing mail to files", "dir", dir)
+		mailer = mail.FileMailer{Dir: dir}
+	}
+
+	e := newServer(logger, db, rdb, serverConfig{
+		Mailer:         mailer,
+		RateLimits:     limits,
+		TrustedProxies: proxies,
+		Tokens:         auth.Tokens{Secret: []byte(jwtSecret)},
+		Hasher:         hasher,
+		Policy:         policy,
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("Listening", "port", listenPort)
+		errCh <- e.Start(":" + listenPort)
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+		logger.Info("Shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := e.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// serverConfig is what newServer needs besides its connections.
+type serverConfig struct {
+	Mailer     mail.Mailer
+	RateLimits []ratelimit.Rule
+	// TrustedProxies are the ranges of proxies whose X-Forwarded-For header
+	// tells the address of clients. Without any, it's that of the connection.
+	TrustedProxies []*net.IPNet
+	Tokens         auth.Tokens
+	Hasher         password.Hasher
+	Policy         service.PasswordPolicy
+}
+
+// parseTrustedProxies parses comma-separated CIDR ranges, such as
+// "10.0.0.0/8, fd00::/8".
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxies: %w", err)
+		}
+		proxies = append(proxies, ipNet)
+	}
+
+	return proxies, nil
+}
+
+// ipExtractor tells the address of clients by the connection or, for
+// connections from trusted proxies, by the X-Forwarded-For header they set,
+// so that clients can't pick their own address to dodge per-address limits.
+func ipExtractor(trusted []*net.IPNet) echo.IPExtractor {
+	if len(trusted) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipNet := range trusted {
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
 
-	userSVC := service.UserService{DB: db}
-	bookSVC := service.BookService{DB: db}
-	reportSVC := service.ReportService{DB: db, RDB: rdb}
+func newServer(logger *slog.Logger, db bun.IDB, rdb *redis.Client, config serverConfig) *echo.Echo {
+	st := bunstore.New(db)
+	userSVC := service.UserService{
+		Store:  st,
+		Hasher: config.Hasher,
+		Policy: config.Policy,
+		Mailer: config.Mailer,
+	}
+	loginSVC := service.LoginService{Store: st, RDB: rdb, Users: userSVC}
+	sessionSVC := service.SessionService{Store: st}
+	apiKeySVC := service.APIKeyService{Store: st}
+	auditSVC := service.AuditService{Store: st}
+	bookSVC := service.BookService{Store: st}
+	reportSVC := service.ReportService{Store: st, RDB: rdb}
 
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.JSONSerializer = tracing.JSONSerializer{}
+	e.IPExtractor = ipExtractor(config.TrustedProxies)
 
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
+	e.Use(otelecho.Middleware(tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		return c.Path() == "/metrics"
+	})))
+	e.Use(logging.RequestIDMiddleware())
+	e.Use(logging.RequestLoggerMiddleware(logger))
+	e.Use(metrics.Middleware())
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			logger.ErrorContext(c.Request().Context(), "Recovered from panic",
+				"error", err, "stack", string(stack))
+			return err
+		},
+	}))
+	e.Use(auth.Middleware(config.Tokens, apiKeySVC))
+	e.Use(audit.Middleware())
+	e.Use(ratelimit.Middleware(ratelimit.Config{RDB: rdb, Rules: config.RateLimits}))
 
 	setupRoutes(
 		e,
+		rdb,
+		handler.AuthHandler{LoginSVC: loginSVC, SessionSVC: sessionSVC, Tokens: config.Tokens},
+		handler.APIKeyHandler{APIKeySVC: apiKeySVC},
 		handler.UserHandler{UserSVC: userSVC},
 		handler.BookHandler{BookSVC: bookSVC},
 		handler.ReportHandler{ReportSVC: reportSVC},
+		handler.AuditHandler{AuditSVC: auditSVC},
 	)
 
-	log.Fatal(e.Start(":" + listenPort))
+	return e
 }
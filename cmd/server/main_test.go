@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/ratelimit"
+)
+
+func TestIPExtractor(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.1:1234", want: "203.0.113.1"},
+		{name: "direct from private address", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "trusted proxy", trusted: true, remoteAddr: "10.0.0.1:1234", want: "198.51.100.7"},
+		{name: "untrusted proxy", trusted: true, remoteAddr: "203.0.113.1:1234", want: "203.0.113.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+			extract := ipExtractor(nil)
+			if tt.trusted {
+				extract = ipExtractor(proxies)
+			}
+			if got := extract(req); got != tt.want {
+				t.Errorf("IP = %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseTrustedProxies("10.0.0.1"); err == nil {
+		t.Error("parsed an address without a prefix length")
+	}
+}
+
+func TestForgedForwardedForSharesRateLimit(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := newServer(logger, nil, rdb, serverConfig{
+		RateLimits: []ratelimit.Rule{{Method: http.MethodGet, Path: "/helloworld", Limit: 1, Window: time.Minute}},
+	})
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/helloworld", nil)
+		req.Header.Set("X-Forwarded-For", fmt.Sprint("198.51.100.", i+1))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("request %d: status = %d; want %d", i, rec.Code, want)
+		}
+	}
+}
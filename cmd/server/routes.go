@@ -1,55 +1,125 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/auth"
 	"github.com/utilyre/lms/internal/handler"
+	"github.com/utilyre/lms/internal/idempotency"
+	"github.com/utilyre/lms/internal/metrics"
 )
 
 func setupRoutes(
 	e *echo.Echo,
+	rdb *redis.Client,
+	authHandler handler.AuthHandler,
+	apiKeyHandler handler.APIKeyHandler,
 	userHandler handler.UserHandler,
 	bookHandler handler.BookHandler,
 	reportHandler handler.ReportHandler,
+	auditHandler handler.AuditHandler,
 ) {
 	e.GET("/helloworld", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello world!")
 	})
+	e.GET("/metrics", metrics.Handler())
 
 	apiV1 := e.Group("/api/v1")
+	apiV1.GET("/openapi.json", handler.ServeOpenAPI)
+	apiV1.GET("/docs", handler.ServeDocs)
 
-	users := apiV1.Group("/users")
+	authGroup := apiV1.Group("/auth")
+	authGroup.POST("/login", authHandler.Login)
+	authGroup.POST("/refresh", authHandler.Refresh)
+	authGroup.POST("/logout", authHandler.Logout)
+
+	// No scope covers API keys, so they can't be managed with one.
+	apiKeys := apiV1.Group("/api-keys", auth.RequireRole(), auth.RequireScope("api-keys"))
+	apiKeys.POST("/", apiKeyHandler.Create)
+	apiKeys.GET("/", apiKeyHandler.List)
+	apiKeys.DELETE("/:id", apiKeyHandler.Delete)
+
+	// Anyone may sign up, but only users themselves and admins may see or
+	// change them, and only admins may choose their role.
+	users := apiV1.Group("/users", auth.RequireScope("users"))
 	users.POST("/", userHandler.Create)
-	users.PUT("/:id", userHandler.Update)
-	users.GET("/:id", userHandler.Get)
-	users.DELETE("/:id", userHandler.Delete)
+	users.PUT("/:id", userHandler.Update, auth.RequireSelfOrRole("id", "admin"))
+	users.PATCH("/:id", userHandler.Patch, auth.RequireSelfOrRole("id", "admin"))
+	users.GET("/:id", userHandler.Get, auth.RequireSelfOrRole("id", "admin"))
+	users.DELETE("/:id", userHandler.Delete, auth.RequireSelfOrRole("id", "admin"))
+	users.POST("/:id/restore", userHandler.Restore, auth.RequireRole("admin"))
+	users.DELETE("/:id/purge", userHandler.Purge, auth.RequireRole("admin"))
+	users.GET("/:id/export", userHandler.Export, auth.RequireRole("admin"))
+	users.POST("/:id/anonymize", userHandler.Anonymize, auth.RequireRole("admin"))
+	users.PUT("/:id/password", userHandler.ChangePassword, auth.RequireSelfOrRole("id", "admin"))
+	users.DELETE("/:id/lockout", authHandler.Unlock, auth.RequireRole("admin"))
+	users.DELETE("/:id/sessions", authHandler.RevokeSessions, auth.RequireRole("admin"))
+
+	passwordResets := apiV1.Group("/password-resets")
+	passwordResets.POST("/", userHandler.RequestPasswordReset)
+	passwordResets.POST("/confirm", userHandler.ResetPassword)
 
-	books := apiV1.Group("/books")
-	books.DELETE("/:id", bookHandler.Delete)
-	books.PUT("/:id", bookHandler.Update)
+	// Anyone may browse the catalogue, but only admins may change it.
+	books := apiV1.Group("/books", auth.RequireScope("books"))
+	books.DELETE("/:id", bookHandler.Delete, auth.RequireRole("admin"))
+	books.PUT("/:id", bookHandler.Update, auth.RequireRole("admin"))
+	books.PATCH("/:id", bookHandler.Patch, auth.RequireRole("admin"))
 	books.GET("/:id", bookHandler.Get)
-	books.POST("/", bookHandler.Create)
+	books.GET("/", bookHandler.List)
+	books.GET("/shelf", bookHandler.ListShelf)
+	books.POST("/", bookHandler.Create, auth.RequireRole("admin"))
+	books.POST("/import", bookHandler.Import, auth.RequireRole("admin"))
+	books.GET("/export", bookHandler.ExportAll, auth.RequireRole("admin"))
+	books.GET("/:id/export", bookHandler.Export)
+	books.POST("/:id/restore", bookHandler.Restore, auth.RequireRole("admin"))
+	books.DELETE("/:id/purge", bookHandler.Purge, auth.RequireRole("admin"))
+
+	// Authors, publishers, subjects, tags and categories are part of the
+	// catalogue, so the scope of books covers them.
+	authors := apiV1.Group("/authors", auth.RequireScope("books"))
+	authors.GET("/", bookHandler.ListAuthors)
+	authors.GET("/:id", bookHandler.GetAuthor)
+
+	publishers := apiV1.Group("/publishers", auth.RequireScope("books"))
+	publishers.GET("/", bookHandler.ListPublishers)
+	publishers.GET("/:id", bookHandler.GetPublisher)
 
-	loans := apiV1.Group("/loans")
-	loans.POST("/", bookHandler.Borrow)
-	loans.PUT("/:id", bookHandler.ReturnLoan)
+	subjects := apiV1.Group("/subjects", auth.RequireScope("books"))
+	subjects.GET("/", bookHandler.ListSubjects)
+	subjects.GET("/:id", bookHandler.GetSubject)
 
-	reservations := apiV1.Group("/reservations")
-	reservations.POST("/", bookHandler.Reserve)
-	reservations.DELETE("/:id", bookHandler.CancelReservation)
+	tags := apiV1.Group("/tags", auth.RequireScope("books"))
+	tags.GET("/", bookHandler.ListTags)
+	tags.GET("/:id", bookHandler.GetTag)
 
-	reports := apiV1.Group("/reports")
-	reports.GET("/overdue-loans", reportHandler.GetOverdueLoans)
+	categories := apiV1.Group("/categories", auth.RequireScope("books"))
+	categories.GET("/", bookHandler.GetCategoryTree)
+	categories.GET("/:id", bookHandler.GetCategory)
+	categories.POST("/", bookHandler.CreateCategory, auth.RequireRole("admin"))
+	categories.DELETE("/:id", bookHandler.DeleteCategory, auth.RequireRole("admin"))
+
+	idempotent := idempotency.Middleware(idempotency.Config{RDB: rdb})
+
+	loans := apiV1.Group("/loans", auth.RequireScope("loans"))
+	loans.POST("/", bookHandler.Borrow, auth.RequireRole(), idempotent)
+	loans.PUT("/:id", bookHandler.ReturnLoan, auth.RequireRole())
+
+	reservations := apiV1.Group("/reservations", auth.RequireScope("reservations"))
+	reservations.POST("/", bookHandler.Reserve, auth.RequireRole(), idempotent)
+	reservations.DELETE("/:id", bookHandler.CancelReservation, auth.RequireRole())
+
+	reports := apiV1.Group("/reports", auth.RequireScope("reports"))
+	reports.GET("/overdue-loans", reportHandler.GetOverdueLoans, auth.RequireRole("admin"))
 	reports.GET("/popular-books", reportHandler.GetPopularBooks)
-	reports.GET("/user-activity/:id", reportHandler.GetUserActivity)
+	reports.GET("/user-activity/:id", reportHandler.GetUserActivity, auth.RequireSelfOrRole("id", "admin"))
+
+	// Neither does any scope cover the audit log.
+	apiV1.GET("/audit-log", auditHandler.List, auth.RequireRole("admin"), auth.RequireScope("audit-log"))
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(e.Routes()); err != nil {
-		log.Fatal(err)
+	for _, route := range e.Routes() {
+		slog.Debug("Registered route", "method", route.Method, "path", route.Path, "name", route.Name)
 	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/handler"
+	"github.com/utilyre/lms/internal/openapi"
+)
+
+var reParam = regexp.MustCompile(`:(\w+)`)
+
+// undocumentedRoutes are registered under /api/v1 but describe the API
+// rather than being part of it.
+var undocumentedRoutes = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /docs":         true,
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	e := echo.New()
+	setupRoutes(e, nil, handler.AuthHandler{}, handler.APIKeyHandler{}, handler.UserHandler{}, handler.BookHandler{}, handler.ReportHandler{}, handler.AuditHandler{})
+
+	doc := handler.OpenAPI()
+	if len(doc.Servers) != 1 {
+		t.Fatalf("len(servers) = %d; want 1", len(doc.Servers))
+	}
+	prefix := doc.Servers[0].URL
+
+	routes := make(map[string]bool)
+	for _, route := range e.Routes() {
+		// Groups with middleware route unknown paths through it.
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+		path, ok := strings.CutPrefix(route.Path, prefix)
+		if !ok {
+			continue
+		}
+
+		key := route.Method + " " + reParam.ReplaceAllString(path, "{$1}")
+		if undocumentedRoutes[key] {
+			continue
+		}
+
+		routes[key] = true
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	for key := range routes {
+		if !documented[key] {
+			t.Errorf("route %q is not documented", key)
+		}
+	}
+	for key := range documented {
+		if !routes[key] {
+			t.Errorf("documented operation %q is not routed", key)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	doc := handler.OpenAPI()
+
+	var check func(where string, schema *openapi.Schema)
+	check = func(where string, schema *openapi.Schema) {
+		if schema == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+			if _, ok := doc.Components.Schemas[name]; !ok {
+				t.Errorf("%s: unresolved reference %q", where, schema.Ref)
+			}
+		}
+
+		check(where, schema.Items)
+		for name, prop := range schema.Properties {
+			check(where+"."+name, prop)
+		}
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		check(name, schema)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item.Operations() {
+			where := method + " " + path
+			if len(op.Responses) == 0 {
+				t.Errorf("%s: no responses documented", where)
+			}
+			for _, param := range op.Parameters {
+				check(where, param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					check(where, media.Schema)
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, media := range resp.Content {
+					check(where, media.Schema)
+				}
+			}
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	e := echo.New()
+	setupRoutes(e, nil, handler.AuthHandler{}, handler.APIKeyHandler{}, handler.UserHandler{}, handler.BookHandler{}, handler.ReportHandler{}, handler.AuditHandler{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q; want %q", doc.OpenAPI, "3.0.3")
+	}
+}
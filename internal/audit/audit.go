@@ -0,0 +1,197 @@
+// Package audit records who changed what in the audit log, within the
+// transaction making the change.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/logging"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionBorrow    = "borrow"
+	ActionReturn    = "return"
+	ActionReserve   = "reserve"
+	ActionCancel    = "cancel"
+	ActionRestore   = "restore"
+	ActionPurge     = "purge"
+	ActionAnonymize = "anonymize"
+)
+
+type clientIPKey struct{}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// Middleware stores the IP address of the client in the request context so
+// that the changes it makes can be attributed to it. Anything but an address,
+// such as a forged header, is left out rather than failing every change.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var ip string
+			if addr, err := netip.ParseAddr(c.RealIP()); err == nil {
+				ip = addr.WithZone("").String()
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(WithClientIP(req.Context(), ip)))
+			return next(c)
+		}
+	}
+}
+
+// Record appends an entry about an action taken on an entity to the audit
+// log of tx, attributing it to whoever ctx was authenticated as. Before and
+// after are the states of the entity around the action, either of which may
+// be nil.
+func Record(ctx context.Context, tx store.Store, action, entity string, entityID int32, before, after any) error {
+	entry, err := newEntry(ctx, Change{
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Before:   before,
+		After:    after,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.AuditLog().Create(ctx, &entry)
+}
+
+// Change is an action taken on an entity, along with the states of the
+// entity around it.
+type Change struct {
+	Action   string
+	Entity   string
+	EntityID int32
+	Before   any
+	After    any
+}
+
+// RecordMany does what Record does for each of changes, inserting their
+// entries in batches.
+func RecordMany(ctx context.Context, tx store.Store, changes []Change) error {
+	entries := make([]model.AuditEntry, 0, len(changes))
+	for _, change := range changes {
+		entry, err := newEntry(ctx, change)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	return tx.AuditLog().CreateMany(ctx, entries)
+}
+
+func newEntry(ctx context.Context, change Change) (model.AuditEntry, error) {
+	entry := model.AuditEntry{
+		Action:    change.Action,
+		Entity:    change.Entity,
+		EntityID:  change.EntityID,
+		IP:        ClientIP(ctx),
+		RequestID: logging.RequestID(ctx),
+		CreatedAt: time.Now(),
+	}
+	if id, ok := auth.FromContext(ctx); ok {
+		entry.ActorID = sql.NullInt32{Int32: id.UserID, Valid: true}
+		if id.APIKeyID != 0 {
+			entry.APIKeyID = sql.NullInt32{Int32: id.APIKeyID, Valid: true}
+		}
+	}
+
+	var err error
+	if entry.Before, err = snapshot(change.Before); err != nil {
+		return model.AuditEntry{}, err
+	}
+	if entry.After, err = snapshot(change.After); err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	return entry, nil
+}
+
+var (
+	baseModelType = reflect.TypeFor[bun.BaseModel]()
+	valuerType    = reflect.TypeFor[driver.Valuer]()
+)
+
+// snapshot encodes the columns of a model as a JSON object, leaving out
+// fields tagged `audit:"-"` such as password hashes. Zero values of nullzero
+// columns are encoded as null, as they're stored. Fields tagged `bun:"-"`
+// hold entities linked to the model, which are encoded along with it.
+func snapshot(v any) (json.RawMessage, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return nil, nil
+	}
+	rv = reflect.Indirect(rv)
+
+	columns := make(map[string]any)
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		if !field.IsExported() || field.Type == baseModelType || field.Tag.Get("audit") == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("bun"), ",")
+		if name == "" || name == "-" {
+			name = underscore(field.Name)
+		}
+
+		value := rv.Field(i).Interface()
+		if rv.Field(i).IsZero() && slices.Contains(strings.Split(options, ","), "nullzero") {
+			value = nil
+		} else if field.Type.Implements(valuerType) {
+			var err error
+			if value, err = value.(driver.Valuer).Value(); err != nil {
+				return nil, err
+			}
+		}
+		columns[name] = value
+	}
+
+	return json.Marshal(columns)
+}
+
+// underscore converts a field name to the column name bun gives it, e.g.
+// "AvailabilityStatus" to "availability_status" and "ISBN" to "isbn".
+func underscore(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
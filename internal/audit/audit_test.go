@@ -0,0 +1,44 @@
+package audit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/audit"
+)
+
+func TestMiddlewareKeepsOnlyAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "ipv4", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "ipv6 with zone", remoteAddr: "[fe80::1%" + strings.Repeat("z", 50) + "]:1234", want: "fe80::1"},
+		{name: "garbage", remoteAddr: strings.Repeat("x", 50), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.IPExtractor = echo.ExtractIPDirect()
+
+			var got string
+			e.GET("/", func(c echo.Context) error {
+				got = audit.ClientIP(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			}, audit.Middleware())
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			e.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("client IP = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
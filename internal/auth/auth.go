@@ -0,0 +1,257 @@
+// Package auth issues signed access tokens to users who log in and
+// authenticates the requests that carry them or an API key.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// APIKeyPrefix starts every API key, which tells them apart from access
+// tokens.
+const APIKeyPrefix = "lms_"
+
+// Identity is who a request is made on behalf of.
+type Identity struct {
+	UserID int32
+	Role   string
+	// APIKeyID is the API key the request was made with, if any. Requests
+	// made with an API key are limited to its Scopes.
+	APIKeyID int32
+	Scopes   []string
+}
+
+// resources are what scopes grant access to.
+var resources = []string{"users", "books", "loans", "reservations", "reports"}
+
+// Scopes lists every valid scope.
+func Scopes() []string {
+	scopes := make([]string, 0, 2*len(resources))
+	for _, resource := range resources {
+		scopes = append(scopes, resource+":read", resource+":write")
+	}
+
+	return scopes
+}
+
+// ValidScope reports whether scope is a resource followed by ":read" or
+// ":write".
+func ValidScope(scope string) bool {
+	resource, access, _ := strings.Cut(scope, ":")
+	return slices.Contains(resources, resource) && (access == "read" || access == "write")
+}
+
+// Allows reports whether the identity may access resource in the given way.
+// Only API keys are limited by scopes.
+func (id Identity) Allows(resource, access string) bool {
+	return id.APIKeyID == 0 || slices.Contains(id.Scopes, resource+":"+access)
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity a request was authenticated as, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Tokens issues and verifies HMAC-signed JWT access tokens.
+type Tokens struct {
+	Secret []byte
+	// TTL is how long access tokens are valid. Zero means 15 minutes.
+	TTL time.Duration
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+func (t Tokens) ttl() time.Duration {
+	if t.TTL == 0 {
+		return 15 * time.Minute
+	}
+
+	return t.TTL
+}
+
+// Issue returns an access token for id along with how long it's valid.
+func (t Tokens) Issue(id Identity) (string, time.Duration, error) {
+	now := time.Now()
+	ttl := t.ttl()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(int64(id.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Role: id.Role,
+	}).SignedString(t.Secret)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return token, ttl, nil
+}
+
+// Parse returns ErrInvalidToken unless token was issued by t and hasn't
+// expired.
+func (t Tokens) Parse(token string) (Identity, error) {
+	var c claims
+	if _, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return t.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired()); err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(c.Subject, 10, 32)
+	if err != nil || userID < 1 {
+		return Identity{}, ErrInvalidToken
+	}
+
+	return Identity{UserID: int32(userID), Role: c.Role}, nil
+}
+
+// KeyVerifier authenticates API keys, returning ErrInvalidToken for those
+// that are unknown or expired.
+type KeyVerifier interface {
+	VerifyKey(ctx context.Context, key string) (Identity, error)
+}
+
+// Middleware authenticates requests with a bearer token, or an API key when
+// keys isn't nil, in their Authorization header, rejecting those whose token
+// is invalid. Requests without a token go through anonymously; routes that
+// need an identity must be guarded by RequireRole.
+func Middleware(tokens Tokens, keys KeyVerifier) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if header == "" {
+				return next(c)
+			}
+
+			scheme, token, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				return unauthorized(c, "unsupported authorization scheme")
+			}
+
+			token = strings.TrimSpace(token)
+			req := c.Request()
+
+			var (
+				id  Identity
+				err error
+			)
+			if keys != nil && strings.HasPrefix(token, APIKeyPrefix) {
+				id, err = keys.VerifyKey(req.Context(), token)
+			} else {
+				id, err = tokens.Parse(token)
+			}
+			if errors.Is(err, ErrInvalidToken) {
+				return unauthorized(c, "invalid or expired token")
+			}
+			if err != nil {
+				return err
+			}
+
+			c.SetRequest(req.WithContext(WithIdentity(req.Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// Client names who a request is made on behalf of: the API key or user it's
+// authenticated as or, for anonymous requests, the client's IP address.
+func Client(c echo.Context) string {
+	if id, ok := FromContext(c.Request().Context()); ok {
+		if id.APIKeyID != 0 {
+			return "key:" + strconv.FormatInt(int64(id.APIKeyID), 10)
+		}
+
+		return "user:" + strconv.FormatInt(int64(id.UserID), 10)
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+// RequireRole rejects anonymous requests and, when roles are given, requests
+// made by users whose role isn't one of them.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id, ok := FromContext(c.Request().Context())
+			if !ok {
+				return unauthorized(c, "authentication required")
+			}
+			if len(roles) > 0 && !slices.Contains(roles, id.Role) {
+				return c.JSON(http.StatusForbidden, map[string]any{
+					"type":    "logic",
+					"message": "insufficient role",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireSelfOrRole is RequireRole(roles...) except that it also lets users
+// through on routes whose param is their own ID.
+func RequireSelfOrRole(param string, roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		requireRole := RequireRole(roles...)(next)
+		return func(c echo.Context) error {
+			id, ok := FromContext(c.Request().Context())
+			if ok && c.Param(param) == strconv.Itoa(int(id.UserID)) {
+				return next(c)
+			}
+
+			return requireRole(c)
+		}
+	}
+}
+
+// RequireScope rejects requests made with API keys that may not access
+// resource: safe methods need its read scope and the others its write scope.
+func RequireScope(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			access := "write"
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				access = "read"
+			}
+
+			if id, ok := FromContext(c.Request().Context()); ok && !id.Allows(resource, access) {
+				return c.JSON(http.StatusForbidden, map[string]any{
+					"type":    "logic",
+					"message": "insufficient scope",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context, message string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return c.JSON(http.StatusUnauthorized, map[string]any{
+		"type":    "logic",
+		"message": message,
+	})
+}
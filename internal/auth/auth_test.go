@@ -0,0 +1,203 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/auth"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := auth.Tokens{Secret: []byte("secret")}
+	want := auth.Identity{UserID: 7, Role: "librarian"}
+
+	token, ttl, err := tokens.Issue(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl != 15*time.Minute {
+		t.Errorf("ttl = %s; want 15m", ttl)
+	}
+
+	got, err := tokens.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("identity = %+v; want %+v", got, want)
+	}
+
+	if _, err := (auth.Tokens{Secret: []byte("other")}).Parse(token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("token signed with another secret: err = %v; want %v", err, auth.ErrInvalidToken)
+	}
+
+	expired, _, err := auth.Tokens{Secret: tokens.Secret, TTL: -time.Minute}.Issue(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Parse(expired); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expired token: err = %v; want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+type keys map[string]auth.Identity
+
+func (k keys) VerifyKey(_ context.Context, key string) (auth.Identity, error) {
+	id, ok := k[key]
+	if !ok {
+		return auth.Identity{}, auth.ErrInvalidToken
+	}
+
+	return id, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	tokens := auth.Tokens{Secret: []byte("secret")}
+	token, _, err := tokens.Issue(auth.Identity{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.Use(auth.Middleware(tokens, keys{"lms_kiosk": {UserID: 8, APIKeyID: 1}}))
+	e.GET("/", func(c echo.Context) error {
+		if id, ok := auth.FromContext(c.Request().Context()); ok {
+			if id.APIKeyID != 0 {
+				return c.String(http.StatusOK, "key")
+			}
+			return c.String(http.StatusOK, "user")
+		}
+
+		return c.String(http.StatusOK, "anonymous")
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "anonymous", wantStatus: http.StatusOK, wantBody: "anonymous"},
+		{name: "valid token", header: "Bearer " + token, wantStatus: http.StatusOK, wantBody: "user"},
+		{name: "invalid token", header: "Bearer bogus", wantStatus: http.StatusUnauthorized},
+		{name: "valid key", header: "Bearer lms_kiosk", wantStatus: http.StatusOK, wantBody: "key"},
+		{name: "invalid key", header: "Bearer lms_bogus", wantStatus: http.StatusUnauthorized},
+		{name: "other scheme", header: "Basic amFuZTpzZWNyZXQ=", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q; want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/books", ok, auth.RequireScope("books"))
+	e.POST("/books", ok, auth.RequireScope("books"))
+
+	tests := []struct {
+		name       string
+		id         *auth.Identity
+		method     string
+		wantStatus int
+	}{
+		{name: "anonymous", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "user", id: &auth.Identity{UserID: 7}, method: http.MethodPost, wantStatus: http.StatusOK},
+		{
+			name:       "key with scope",
+			id:         &auth.Identity{UserID: 7, APIKeyID: 1, Scopes: []string{"books:read"}},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "key without scope",
+			id:         &auth.Identity{UserID: 7, APIKeyID: 1, Scopes: []string{"books:read"}},
+			method:     http.MethodPost,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/books", nil)
+			if tt.id != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.id))
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireSelfOrRole(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.PUT("/users/:id", ok, auth.RequireSelfOrRole("id", "admin"))
+
+	tests := []struct {
+		name       string
+		id         *auth.Identity
+		path       string
+		wantStatus int
+	}{
+		{name: "anonymous", path: "/users/7", wantStatus: http.StatusUnauthorized},
+		{name: "self", id: &auth.Identity{UserID: 7}, path: "/users/7", wantStatus: http.StatusOK},
+		{name: "other", id: &auth.Identity{UserID: 7}, path: "/users/8", wantStatus: http.StatusForbidden},
+		{name: "padded self", id: &auth.Identity{UserID: 7}, path: "/users/007", wantStatus: http.StatusForbidden},
+		{name: "admin", id: &auth.Identity{UserID: 1, Role: "admin"}, path: "/users/8", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			if tt.id != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.id))
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for scope, want := range map[string]bool{
+		"books:read":  true,
+		"loans:write": true,
+		"books":       false,
+		"books:admin": false,
+		"fines:read":  false,
+	} {
+		if got := auth.ValidScope(scope); got != want {
+			t.Errorf("ValidScope(%q) = %t; want %t", scope, got, want)
+		}
+	}
+}
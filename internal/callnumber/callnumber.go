@@ -0,0 +1,92 @@
+// Package callnumber parses call numbers of the Dewey Decimal and Library of
+// Congress classifications into keys that sort them in shelf order.
+package callnumber
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Scheme is a classification call numbers are written in.
+type Scheme string
+
+const (
+	Dewey Scheme = "dewey"
+	LCC   Scheme = "lcc"
+)
+
+var (
+	ErrUnknownScheme = errors.New("unknown classification")
+	ErrMalformed     = errors.New("malformed call number")
+)
+
+var (
+	// A Dewey call number is a class of three digits, such as 595.789,
+	// followed by a book number such as a Cutter number.
+	deweyRe = regexp.MustCompile(`^(\d{3})(?:\.(\d+))?(.*)$`)
+	// An LCC call number is a class of up to three letters, optionally
+	// narrowed by a number such as 76.73, followed by Cutter numbers and
+	// a date, as in QA76.73.G63 D66 2015.
+	lccRe = regexp.MustCompile(`^([A-Z]{1,3})(?:\s*(\d{1,4})(?:\.(\d+))?)?(.*)$`)
+)
+
+// Key returns the sort key of callNumber in scheme. Keys compare byte by
+// byte in shelf order, and consist of digits, uppercase letters and spaces
+// only. The key of a call number is a prefix of the keys of those it
+// contains, so that QA76 is a prefix of QA76.73.G63 for instance.
+func Key(scheme Scheme, callNumber string) (string, error) {
+	// Slashes and primes mark where a Dewey number may be shortened.
+	s := strings.NewReplacer("/", "", "'", "").Replace(callNumber)
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	var class, rest string
+	switch scheme {
+	case Dewey:
+		m := deweyRe.FindStringSubmatch(s)
+		if m == nil {
+			return "", ErrMalformed
+		}
+
+		// The decimals are appended as they are, since .7 goes between .69
+		// and .71 just like the strings do.
+		class, rest = m[1]+m[2], m[3]
+	case LCC:
+		m := lccRe.FindStringSubmatch(s)
+		if m == nil {
+			return "", ErrMalformed
+		}
+
+		// Padding the letters and the whole number makes Q go before QA and
+		// QA9 before QA76.
+		class = m[1] + strings.Repeat(" ", 3-len(m[1]))
+		if m[2] != "" {
+			class += strings.Repeat("0", 4-len(m[2])) + m[2] + m[3]
+		}
+		rest = m[4]
+	default:
+		return "", ErrUnknownScheme
+	}
+	if rest != "" && isAlnum(rune(rest[0])) {
+		return "", ErrMalformed
+	}
+
+	// The rest is compared token by token, the space separating them going
+	// before any digit or letter so that 595 SMI is shelved before 595.1.
+	key := class
+	for _, token := range strings.FieldsFunc(rest, func(r rune) bool { return !isAlnum(r) }) {
+		key += " " + token
+	}
+
+	return key, nil
+}
+
+// UpperBound returns a key greater than those key is a prefix of, and less
+// than any other key greater than key.
+func UpperBound(key string) string {
+	return key + "~"
+}
+
+func isAlnum(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'A' && r <= 'Z'
+}
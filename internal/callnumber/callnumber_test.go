@@ -0,0 +1,127 @@
+package callnumber_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/utilyre/lms/internal/callnumber"
+)
+
+func TestKeySortsInShelfOrder(t *testing.T) {
+	tests := []struct {
+		scheme callnumber.Scheme
+		// shelf lists call numbers in the order they're shelved.
+		shelf []string
+	}{
+		{
+			scheme: callnumber.Dewey,
+			shelf: []string{
+				"005.133 DON",
+				"005.133 KER",
+				"005.2",
+				"595 SMI",
+				"595.7",
+				"595.78/9 NAB",
+				"595.79",
+				"813.54 HER",
+			},
+		},
+		{
+			scheme: callnumber.LCC,
+			shelf: []string{
+				"PS3558.E63 D8 1965",
+				"PS3558.E63 D8 1990",
+				"Q172",
+				"QA9.58 .K53",
+				"QA76 .K47",
+				"QA76.73.C15 K47 1988",
+				"QA76.73.G63 D66 2015",
+				"QA76.8",
+				"QA761 .B3",
+				"QB43.2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scheme), func(t *testing.T) {
+			keys := make(map[string]string, len(tt.shelf))
+			for _, callNumber := range tt.shelf {
+				key, err := callnumber.Key(tt.scheme, callNumber)
+				if err != nil {
+					t.Fatalf("Key(%q) = %v", callNumber, err)
+				}
+				keys[callNumber] = key
+			}
+
+			shuffled := slices.Clone(tt.shelf)
+			rand.Shuffle(len(shuffled), func(i, j int) {
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			})
+			slices.SortFunc(shuffled, func(a, b string) int {
+				return strings.Compare(keys[a], keys[b])
+			})
+			if !slices.Equal(shuffled, tt.shelf) {
+				t.Errorf("sorted = %q; want %q", shuffled, tt.shelf)
+			}
+		})
+	}
+}
+
+func TestKeyRanges(t *testing.T) {
+	tests := []struct {
+		scheme     callnumber.Scheme
+		class      string
+		callNumber string
+		want       bool
+	}{
+		{scheme: callnumber.Dewey, class: "595", callNumber: "595.789 NAB", want: true},
+		{scheme: callnumber.Dewey, class: "595.7", callNumber: "595.789 NAB", want: true},
+		{scheme: callnumber.Dewey, class: "595.8", callNumber: "595.789 NAB", want: false},
+		{scheme: callnumber.LCC, class: "QA", callNumber: "QA76.73.G63 D66 2015", want: true},
+		{scheme: callnumber.LCC, class: "QA76", callNumber: "QA76.73.G63 D66 2015", want: true},
+		{scheme: callnumber.LCC, class: "Q", callNumber: "QA76.73.G63 D66 2015", want: false},
+		{scheme: callnumber.LCC, class: "QA7", callNumber: "QA76.73.G63 D66 2015", want: false},
+	}
+
+	for _, tt := range tests {
+		class, err := callnumber.Key(tt.scheme, tt.class)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := callnumber.Key(tt.scheme, tt.callNumber)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := key >= class && key < callnumber.UpperBound(class); got != tt.want {
+			t.Errorf("%s in class %s = %t; want %t", tt.callNumber, tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestKeyMalformed(t *testing.T) {
+	tests := []struct {
+		scheme     callnumber.Scheme
+		callNumber string
+		wantErr    error
+	}{
+		{scheme: callnumber.Dewey, callNumber: "", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.Dewey, callNumber: "59", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.Dewey, callNumber: "5951", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.Dewey, callNumber: "QA76", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.LCC, callNumber: "595.7", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.LCC, callNumber: "QABC76", wantErr: callnumber.ErrMalformed},
+		{scheme: callnumber.LCC, callNumber: "QA12345", wantErr: callnumber.ErrMalformed},
+		{scheme: "udc", callNumber: "004.43", wantErr: callnumber.ErrUnknownScheme},
+	}
+
+	for _, tt := range tests {
+		if _, err := callnumber.Key(tt.scheme, tt.callNumber); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Key(%s, %q) = %v; want %v", tt.scheme, tt.callNumber, err, tt.wantErr)
+		}
+	}
+}
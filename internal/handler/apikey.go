@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/service"
+)
+
+type APIKeyHandler struct {
+	APIKeySVC service.APIKeyService
+}
+
+type apiKeyResp struct {
+	ID         int32      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newAPIKeyResp(key model.APIKey) apiKeyResp {
+	resp := apiKeyResp{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		resp.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		resp.LastUsedAt = &key.LastUsedAt.Time
+	}
+
+	return resp
+}
+
+func (akh APIKeyHandler) Create(c echo.Context) error {
+	type Req struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	id, _ := auth.FromContext(c.Request().Context())
+	apiKey, key, err := akh.APIKeySVC.Create(c.Request().Context(), id.UserID, service.APIKeyCreateParams{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	type Resp struct {
+		apiKeyResp
+		Key string `json:"key"`
+	}
+	return c.JSON(http.StatusCreated, Resp{
+		apiKeyResp: newAPIKeyResp(*apiKey),
+		Key:        key,
+	})
+}
+
+func (akh APIKeyHandler) List(c echo.Context) error {
+	id, _ := auth.FromContext(c.Request().Context())
+	keys, err := akh.APIKeySVC.List(c.Request().Context(), id.UserID)
+	if err != nil {
+		return err
+	}
+
+	resp := make([]apiKeyResp, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, newAPIKeyResp(key))
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (akh APIKeyHandler) Delete(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	id, _ := auth.FromContext(c.Request().Context())
+	if err := akh.APIKeySVC.DeleteByID(c.Request().Context(), id.UserID, req.ID); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "api key not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "API key deleted successfully",
+	})
+}
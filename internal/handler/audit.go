@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/service"
+)
+
+// auditEntryResp is how audit log entries are represented in responses.
+type auditEntryResp struct {
+	ID        int32           `json:"id"`
+	ActorID   *int32          `json:"actor_id"`
+	APIKeyID  *int32          `json:"api_key_id"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int32           `json:"entity_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	IP        string          `json:"ip"`
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newAuditEntryResp(entry model.AuditEntry) auditEntryResp {
+	resp := auditEntryResp{
+		ID:        entry.ID,
+		Action:    entry.Action,
+		Entity:    entry.Entity,
+		EntityID:  entry.EntityID,
+		Before:    entry.Before,
+		After:     entry.After,
+		IP:        entry.IP,
+		RequestID: entry.RequestID,
+		CreatedAt: entry.CreatedAt,
+	}
+	if entry.ActorID.Valid {
+		resp.ActorID = &entry.ActorID.Int32
+	}
+	if entry.APIKeyID.Valid {
+		resp.APIKeyID = &entry.APIKeyID.Int32
+	}
+
+	return resp
+}
+
+type AuditHandler struct {
+	AuditSVC service.AuditService
+}
+
+func (ah AuditHandler) List(c echo.Context) error {
+	type Req struct {
+		ActorID  int32     `query:"actor_id"`
+		Action   string    `query:"action"`
+		Entity   string    `query:"entity"`
+		EntityID int32     `query:"entity_id"`
+		Since    time.Time `query:"since"`
+		Until    time.Time `query:"until"`
+		BeforeID int32     `query:"before_id"`
+		Limit    int       `query:"limit"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	entries, err := ah.AuditSVC.List(c.Request().Context(), service.AuditListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	resp := make([]auditEntryResp, 0, len(entries))
+	for _, entry := range entries {
+		resp = append(resp, newAuditEntryResp(entry))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/model"
 	"github.com/utilyre/lms/internal/service"
 )
 
@@ -14,9 +15,51 @@ type BookHandler struct {
 	BookSVC service.BookService
 }
 
+// bookResp is how books are represented in responses.
+type bookResp struct {
+	ID                 int32             `json:"id"`
+	Title              string            `json:"title"`
+	Authors            []model.Author    `json:"authors"`
+	Publishers         []model.Publisher `json:"publishers"`
+	Subjects           []model.Subject   `json:"subjects"`
+	ISBN               string            `json:"isbn"`
+	PublicationYear    int32             `json:"publication_year,omitempty"`
+	Edition            string            `json:"edition,omitempty"`
+	Language           string            `json:"language,omitempty"`
+	PageCount          int32             `json:"page_count,omitempty"`
+	Categories         []model.Category  `json:"categories"`
+	Tags               []model.Tag       `json:"tags"`
+	Classification     string            `json:"classification,omitempty"`
+	CallNumber         string            `json:"call_number,omitempty"`
+	AvailabilityStatus string            `json:"availability_status"`
+}
+
+func newBookResp(book *model.Book) bookResp {
+	return bookResp{
+		ID:    book.ID,
+		Title: book.Title,
+		// Books without publishers, subjects, categories or tags have empty
+		// lists of them rather than null.
+		Authors:            append([]model.Author{}, book.Authors...),
+		Publishers:         append([]model.Publisher{}, book.Publishers...),
+		Subjects:           append([]model.Subject{}, book.Subjects...),
+		ISBN:               book.ISBN,
+		PublicationYear:    book.PublicationYear,
+		Edition:            book.Edition,
+		Language:           book.Language,
+		PageCount:          book.PageCount,
+		Categories:         append([]model.Category{}, book.Categories...),
+		Tags:               append([]model.Tag{}, book.Tags...),
+		Classification:     book.Classification,
+		CallNumber:         book.CallNumber,
+		AvailabilityStatus: book.AvailabilityStatus,
+	}
+}
+
 func (bh BookHandler) Delete(c echo.Context) error {
 	type Req struct {
-		ID int32 `param:"id"`
+		ID    int32 `param:"id"`
+		Force bool  `query:"force"`
 	}
 
 	var req Req
@@ -24,7 +67,23 @@ func (bh BookHandler) Delete(c echo.Context) error {
 		return err
 	}
 
-	err := bh.BookSVC.DeleteByID(c.Request().Context(), req.ID)
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "book has been modified",
+		})
+	}
+
+	deleteByID := bh.BookSVC.DeleteByID
+	if req.Force {
+		if ok, err := authorize(c, "admin"); !ok {
+			return err
+		}
+		deleteByID = bh.BookSVC.ForceDeleteByID
+	}
+
+	err := deleteByID(c.Request().Context(), req.ID, versions...)
 	if err != nil {
 		var validationErr service.ValidationError
 		if errors.As(err, &validationErr) {
@@ -33,6 +92,27 @@ func (bh BookHandler) Delete(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+		var inUseErr service.InUseError
+		if errors.As(err, &inUseErr) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":         "logic",
+				"message":      "book has open loans or active reservations",
+				"loans":        inUseErr.LoanIDs,
+				"reservations": inUseErr.ReservationIDs,
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "book has been modified",
+			})
+		}
 
 		return err
 	}
@@ -44,22 +124,52 @@ func (bh BookHandler) Delete(c echo.Context) error {
 
 func (bh BookHandler) Update(c echo.Context) error {
 	type Req struct {
-		ID                 int32  `param:"id"`
-		Title              string `json:"title"`
-		Author             string `json:"author"`
-		ISBN               string `json:"isbn"`
-		AvailabilityStatus string `json:"availability_status"`
+		ID                 int32    `param:"id"`
+		Title              string   `json:"title"`
+		Authors            []string `json:"authors"`
+		Author             string   `json:"author"`
+		Publishers         []string `json:"publishers"`
+		Subjects           []string `json:"subjects"`
+		ISBN               string   `json:"isbn"`
+		PublicationYear    int32    `json:"publication_year"`
+		Edition            string   `json:"edition"`
+		Language           string   `json:"language"`
+		PageCount          int32    `json:"page_count"`
+		Categories         []int32  `json:"categories"`
+		Tags               []string `json:"tags"`
+		Classification     string   `json:"classification"`
+		CallNumber         string   `json:"call_number"`
+		AvailabilityStatus string   `json:"availability_status"`
 	}
 	var req Req
 	if err := c.Bind(&req); err != nil {
 		return err
 	}
 
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "book has been modified",
+		})
+	}
+
 	book, err := bh.BookSVC.UpdateByID(c.Request().Context(), req.ID, service.BookUpdateByIDParams{
 		Title:              req.Title,
-		Author:             req.Author,
+		Authors:            withAuthor(req.Authors, req.Author),
+		Publishers:         req.Publishers,
+		Subjects:           req.Subjects,
 		ISBN:               req.ISBN,
+		PublicationYear:    req.PublicationYear,
+		Edition:            req.Edition,
+		Language:           req.Language,
+		PageCount:          req.PageCount,
+		Categories:         req.Categories,
+		Tags:               req.Tags,
+		Classification:     req.Classification,
+		CallNumber:         req.CallNumber,
 		AvailabilityStatus: req.AvailabilityStatus,
+		Versions:           versions,
 	})
 	if err != nil {
 		var validationErr service.ValidationError
@@ -69,24 +179,111 @@ func (bh BookHandler) Update(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "book has been modified",
+			})
+		}
 
 		return err
 	}
 
-	type Resp struct {
-		ID                 int32  `json:"id"`
-		Title              string `json:"title"`
-		Author             string `json:"author"`
-		ISBN               string `json:"isbn"`
-		AvailabilityStatus string `json:"availability_status"`
+	c.Response().Header().Set(HeaderETag, etag(book.Version))
+
+	return c.JSON(http.StatusOK, newBookResp(book))
+}
+
+func (bh BookHandler) Patch(c echo.Context) error {
+	type Req struct {
+		ID                 int32                      `param:"id"`
+		Title              service.Optional[string]   `json:"title"`
+		Authors            service.Optional[[]string] `json:"authors"`
+		Author             service.Optional[string]   `json:"author"`
+		Publishers         service.Optional[[]string] `json:"publishers"`
+		Subjects           service.Optional[[]string] `json:"subjects"`
+		ISBN               service.Optional[string]   `json:"isbn"`
+		PublicationYear    service.Optional[int32]    `json:"publication_year"`
+		Edition            service.Optional[string]   `json:"edition"`
+		Language           service.Optional[string]   `json:"language"`
+		PageCount          service.Optional[int32]    `json:"page_count"`
+		Categories         service.Optional[[]int32]  `json:"categories"`
+		Tags               service.Optional[[]string] `json:"tags"`
+		Classification     service.Optional[string]   `json:"classification"`
+		CallNumber         service.Optional[string]   `json:"call_number"`
+		AvailabilityStatus service.Optional[string]   `json:"availability_status"`
 	}
-	return c.JSON(http.StatusCreated, Resp{
-		ID:                 book.ID,
-		Title:              book.Title,
-		Author:             book.Author,
-		ISBN:               book.ISBN,
-		AvailabilityStatus: book.AvailabilityStatus,
+	var req Req
+	if err := bindMergePatch(c, &req); err != nil {
+		return err
+	}
+
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "book has been modified",
+		})
+	}
+
+	// Older clients send the one author books used to have.
+	if !req.Authors.Set && req.Author.Set {
+		req.Authors = service.Optional[[]string]{Set: true, Null: req.Author.Null}
+		if !req.Author.Null {
+			req.Authors.Value = []string{req.Author.Value}
+		}
+	}
+
+	book, err := bh.BookSVC.PatchByID(c.Request().Context(), req.ID, service.BookPatchByIDParams{
+		Title:              req.Title,
+		Authors:            req.Authors,
+		Publishers:         req.Publishers,
+		Subjects:           req.Subjects,
+		ISBN:               req.ISBN,
+		PublicationYear:    req.PublicationYear,
+		Edition:            req.Edition,
+		Language:           req.Language,
+		PageCount:          req.PageCount,
+		Categories:         req.Categories,
+		Tags:               req.Tags,
+		Classification:     req.Classification,
+		CallNumber:         req.CallNumber,
+		AvailabilityStatus: req.AvailabilityStatus,
+		Versions:           versions,
 	})
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "book has been modified",
+			})
+		}
+
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, etag(book.Version))
+
+	return c.JSON(http.StatusOK, newBookResp(book))
 }
 
 func (bh BookHandler) Get(c echo.Context) error {
@@ -117,38 +314,40 @@ func (bh BookHandler) Get(c echo.Context) error {
 		return err
 	}
 
-	type Resp struct {
-		ID                 int32  `json:"id"`
-		Title              string `json:"title"`
-		Author             string `json:"author"`
-		ISBN               string `json:"isbn"`
-		AvailabilityStatus string `json:"availability_status"`
+	c.Response().Header().Set(HeaderETag, etag(book.Version))
+	if notModified(c, book.Version) {
+		return c.NoContent(http.StatusNotModified)
 	}
-	return c.JSON(http.StatusCreated, Resp{
-		ID:                 book.ID,
-		Title:              book.Title,
-		Author:             book.Author,
-		ISBN:               book.ISBN,
-		AvailabilityStatus: book.AvailabilityStatus,
-	})
+
+	return c.JSON(http.StatusOK, newBookResp(book))
 }
 
 func (bh BookHandler) Create(c echo.Context) error {
 	type Req struct {
-		Title  string `json:"title"`
+		Title           string   `json:"title"`
+		Authors         []string `json:"authors"`
+		Publishers      []string `json:"publishers"`
+		Subjects        []string `json:"subjects"`
+		ISBN            string   `json:"isbn"`
+		PublicationYear int32    `json:"publication_year"`
+		Edition         string   `json:"edition"`
+		Language        string   `json:"language"`
+		PageCount       int32    `json:"page_count"`
+		Categories      []int32  `json:"categories"`
+		Tags            []string `json:"tags"`
+		Classification  string   `json:"classification"`
+		CallNumber      string   `json:"call_number"`
+	}
+	var req struct {
+		Req
 		Author string `json:"author"`
-		ISBN   string `json:"isbn"`
 	}
-	var req Req
 	if err := c.Bind(&req); err != nil {
 		return err
 	}
+	req.Authors = withAuthor(req.Authors, req.Author)
 
-	book, err := bh.BookSVC.Create(c.Request().Context(), service.BookCreateParams{
-		Title:  req.Title,
-		Author: req.Author,
-		ISBN:   req.ISBN,
-	})
+	book, err := bh.BookSVC.Create(c.Request().Context(), service.BookCreateParams(req.Req))
 	if err != nil {
 		var validationErr service.ValidationError
 		if errors.As(err, &validationErr) {
@@ -161,20 +360,19 @@ func (bh BookHandler) Create(c echo.Context) error {
 		return err
 	}
 
-	type Resp struct {
-		ID                 int32  `json:"id"`
-		Title              string `json:"title"`
-		Author             string `json:"author"`
-		ISBN               string `json:"isbn"`
-		AvailabilityStatus string `json:"availability_status"`
+	c.Response().Header().Set(HeaderETag, etag(book.Version))
+
+	return c.JSON(http.StatusCreated, newBookResp(book))
+}
+
+// withAuthor returns authors or else the one given as author, which is what
+// books had before they could have several and older clients still send.
+func withAuthor(authors []string, author string) []string {
+	if authors == nil && author != "" {
+		return []string{author}
 	}
-	return c.JSON(http.StatusCreated, Resp{
-		ID:                 book.ID,
-		Title:              book.Title,
-		Author:             book.Author,
-		ISBN:               book.ISBN,
-		AvailabilityStatus: book.AvailabilityStatus,
-	})
+
+	return authors
 }
 
 type DateOnly struct{ time.Time }
@@ -208,6 +406,10 @@ func (bh BookHandler) Borrow(c echo.Context) error {
 		return err
 	}
 
+	if ok, err := authorizeFor(c, req.UserID); !ok {
+		return err
+	}
+
 	loan, err := bh.BookSVC.Borrow(c.Request().Context(), service.BookBorrowParams{
 		UserID: req.UserID,
 		BookID: req.BookID,
@@ -220,6 +422,18 @@ func (bh BookHandler) Borrow(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
 		if errors.Is(err, service.ErrBookReserved) {
 			return c.JSON(http.StatusConflict, map[string]any{
 				"type":    "logic",
@@ -265,6 +479,7 @@ func (bh BookHandler) ReturnLoan(c echo.Context) error {
 	loan, err := bh.BookSVC.ReturnLoan(c.Request().Context(), service.BookReturnLoanParams{
 		LoanID:     req.ID,
 		ReturnDate: req.ReturnDate.Time,
+		UserID:     owner(c),
 	})
 	if err != nil {
 		var validationErr service.ValidationError
@@ -274,6 +489,18 @@ func (bh BookHandler) ReturnLoan(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrLoanNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "loan not found",
+			})
+		}
+		if errors.Is(err, service.ErrLoanReturned) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "loan already returned",
+			})
+		}
 
 		return err
 	}
@@ -306,6 +533,10 @@ func (bh BookHandler) Reserve(c echo.Context) error {
 		return err
 	}
 
+	if ok, err := authorizeFor(c, req.UserID); !ok {
+		return err
+	}
+
 	reservation, err := bh.BookSVC.Reserve(c.Request().Context(), service.BookReserveParams{
 		UserID: req.UserID,
 		BookID: req.BookID,
@@ -318,6 +549,18 @@ func (bh BookHandler) Reserve(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
 
 		return err
 	}
@@ -343,7 +586,7 @@ func (bh BookHandler) CancelReservation(c echo.Context) error {
 		return err
 	}
 
-	if err := bh.BookSVC.CancelReservation(c.Request().Context(), req.ID); err != nil {
+	if err := bh.BookSVC.CancelReservation(c.Request().Context(), owner(c), req.ID); err != nil {
 		var validationErr service.ValidationError
 		if errors.As(err, &validationErr) {
 			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
@@ -351,6 +594,18 @@ func (bh BookHandler) CancelReservation(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrReservationNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "reservation not found",
+			})
+		}
+		if errors.Is(err, service.ErrReservationCanceled) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "reservation already canceled",
+			})
+		}
 
 		return err
 	}
@@ -359,3 +614,80 @@ func (bh BookHandler) CancelReservation(c echo.Context) error {
 		"message": "Reservation canceled successfully",
 	})
 }
+
+func (bh BookHandler) Restore(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	book, err := bh.BookSVC.RestoreByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "book is not deleted",
+			})
+		}
+
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, etag(book.Version))
+
+	return c.JSON(http.StatusOK, newBookResp(book))
+}
+
+func (bh BookHandler) Purge(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := bh.BookSVC.PurgeByID(c.Request().Context(), req.ID); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "book is not deleted",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "Book purged successfully",
+	})
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func (bh BookHandler) List(c echo.Context) error {
+	type Req struct {
+		AuthorID    int32  `query:"author_id"`
+		PublisherID int32  `query:"publisher_id"`
+		SubjectID   int32  `query:"subject_id"`
+		CategoryID  int32  `query:"category_id"`
+		TagID       int32  `query:"tag_id"`
+		Language    string `query:"language"`
+		YearFrom    int32  `query:"year_from"`
+		YearTo      int32  `query:"year_to"`
+		AfterID     int32  `query:"after_id"`
+		Limit       int    `query:"limit"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	books, err := bh.BookSVC.List(c.Request().Context(), service.BookListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	resp := make([]bookResp, 0, len(books))
+	for _, book := range books {
+		resp = append(resp, newBookResp(&book))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// nameListReq is the query of the endpoints listing authors, publishers,
+// subjects and tags.
+type nameListReq struct {
+	Prefix string `query:"prefix"`
+	After  string `query:"after"`
+	Limit  int    `query:"limit"`
+}
+
+func (bh BookHandler) ListAuthors(c echo.Context) error {
+	var req nameListReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	authors, err := bh.BookSVC.ListAuthors(c.Request().Context(), service.NameListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, authors)
+}
+
+func (bh BookHandler) GetAuthor(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	author, err := bh.BookSVC.GetAuthorByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrAuthorNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "author not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, author)
+}
+
+func (bh BookHandler) ListPublishers(c echo.Context) error {
+	var req nameListReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	publishers, err := bh.BookSVC.ListPublishers(c.Request().Context(), service.NameListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, publishers)
+}
+
+func (bh BookHandler) GetPublisher(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	publisher, err := bh.BookSVC.GetPublisherByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrPublisherNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "publisher not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, publisher)
+}
+
+func (bh BookHandler) ListSubjects(c echo.Context) error {
+	var req nameListReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	subjects, err := bh.BookSVC.ListSubjects(c.Request().Context(), service.NameListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, subjects)
+}
+
+func (bh BookHandler) GetSubject(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	subject, err := bh.BookSVC.GetSubjectByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrSubjectNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "subject not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, subject)
+}
+
+func (bh BookHandler) ListTags(c echo.Context) error {
+	var req nameListReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	tags, err := bh.BookSVC.ListTags(c.Request().Context(), service.NameListParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+func (bh BookHandler) GetTag(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	tag, err := bh.BookSVC.GetTagByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrTagNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "tag not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/service"
+)
+
+// categoryResp is how categories are represented in responses, along with
+// their descendants.
+type categoryResp struct {
+	ID       int32          `json:"id"`
+	ParentID int32          `json:"parent_id,omitempty"`
+	Name     string         `json:"name"`
+	Children []categoryResp `json:"children"`
+}
+
+func newCategoryResp(node service.CategoryNode) categoryResp {
+	resp := categoryResp{
+		ID:       node.ID,
+		ParentID: node.ParentID,
+		Name:     node.Name,
+		Children: make([]categoryResp, 0, len(node.Children)),
+	}
+	for _, child := range node.Children {
+		resp.Children = append(resp.Children, newCategoryResp(child))
+	}
+
+	return resp
+}
+
+func (bh BookHandler) GetCategoryTree(c echo.Context) error {
+	tree, err := bh.BookSVC.GetCategoryTree(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	resp := make([]categoryResp, 0, len(tree))
+	for _, node := range tree {
+		resp = append(resp, newCategoryResp(node))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (bh BookHandler) GetCategory(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	node, err := bh.BookSVC.GetCategoryByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "category not found",
+			})
+		}
+
+		return err
+	}
+
+	type Resp struct {
+		categoryResp
+		Ancestors []model.Category `json:"ancestors"`
+	}
+	return c.JSON(http.StatusOK, Resp{
+		categoryResp: newCategoryResp(*node),
+		Ancestors:    node.Ancestors,
+	})
+}
+
+func (bh BookHandler) CreateCategory(c echo.Context) error {
+	type Req struct {
+		Name     string `json:"name"`
+		ParentID int32  `json:"parent_id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	category, err := bh.BookSVC.CreateCategory(c.Request().Context(), service.CategoryCreateParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrCategoryDup) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "category already exists",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newCategoryResp(service.CategoryNode{Category: *category}))
+}
+
+func (bh BookHandler) DeleteCategory(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	err := bh.BookSVC.DeleteCategoryByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "category not found",
+			})
+		}
+		if errors.Is(err, service.ErrCategoryNotEmpty) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "category has subcategories",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "Category deleted successfully",
+	})
+}
+
+func (bh BookHandler) ListShelf(c echo.Context) error {
+	type Req struct {
+		Classification string `query:"classification"`
+		From           string `query:"from"`
+		To             string `query:"to"`
+		AfterID        int32  `query:"after_id"`
+		Limit          int    `query:"limit"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	books, err := bh.BookSVC.ListShelf(c.Request().Context(), service.BookShelfParams(req))
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	resp := make([]bookResp, 0, len(books))
+	for _, book := range books {
+		resp = append(resp, newBookResp(&book))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
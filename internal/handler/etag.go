@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// etag returns the entity tag of a resource at version.
+func etag(version int32) string {
+	return strconv.Quote(strconv.FormatInt(int64(version), 10))
+}
+
+type entityTag struct {
+	weak   bool
+	opaque string
+}
+
+// parseETags parses the comma separated entity tags of an If-Match or
+// If-None-Match header, skipping malformed ones. wildcard reports whether the
+// header is "*".
+func parseETags(header string) (tags []entityTag, wildcard bool) {
+	for _, field := range strings.Split(header, ",") {
+		field = strings.TrimSpace(field)
+		if field == "*" {
+			return nil, true
+		}
+
+		var tag entityTag
+		if rest, ok := strings.CutPrefix(field, "W/"); ok {
+			tag.weak = true
+			field = rest
+		}
+		if len(field) < 2 || field[0] != '"' || field[len(field)-1] != '"' {
+			continue
+		}
+
+		tag.opaque = field[1 : len(field)-1]
+		tags = append(tags, tag)
+	}
+
+	return tags, false
+}
+
+// ifMatch returns the versions the If-Match header of the request expects
+// the resource to be at, which are none when the header is absent or "*".
+// ok is false when the header lists no tag the API could have issued, so
+// the precondition can't hold.
+func ifMatch(c echo.Context) (versions []int32, ok bool) {
+	header := c.Request().Header.Get(HeaderIfMatch)
+	if header == "" {
+		return nil, true
+	}
+
+	tags, wildcard := parseETags(header)
+	if wildcard {
+		return nil, true
+	}
+
+	// If-Match compares tags strongly, so weak ones never match.
+	for _, tag := range tags {
+		if tag.weak {
+			continue
+		}
+		if version, err := strconv.ParseInt(tag.opaque, 10, 32); err == nil {
+			versions = append(versions, int32(version))
+		}
+	}
+
+	return versions, len(versions) > 0
+}
+
+// notModified reports whether the If-None-Match header of the request lists
+// the tag of the resource at version.
+func notModified(c echo.Context, version int32) bool {
+	header := c.Request().Header.Get(HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+
+	tags, wildcard := parseETags(header)
+	if wildcard {
+		return true
+	}
+
+	want := strconv.FormatInt(int64(version), 10)
+	for _, tag := range tags {
+		if tag.opaque == want {
+			return true
+		}
+	}
+
+	return false
+}
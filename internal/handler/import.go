@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/service"
+)
+
+const MIMETextCSV = "text/csv"
+
+// importFormats maps the media types of the files books can be imported
+// from to their formats.
+var importFormats = map[string]string{
+	MIMETextCSV:             service.ImportFormatCSV,
+	marc.MIMEType:           service.ImportFormatMARC,
+	marc.XMLMIMEType:        service.ImportFormatMARCXML,
+	echo.MIMEApplicationXML: service.ImportFormatMARCXML,
+	echo.MIMETextXML:        service.ImportFormatMARCXML,
+}
+
+func (bh BookHandler) Import(c echo.Context) error {
+	type Req struct {
+		DryRun bool `query:"dry_run"`
+	}
+	var req Req
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return err
+	}
+
+	// The file is either the body itself or the file field of a form, whose
+	// format is told by its name unless its media type says otherwise.
+	var body io.Reader = c.Request().Body
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	format, ok := importFormats[mediaType]
+	switch {
+	case mediaType == echo.MIMEMultipartForm:
+		header, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": "file: required",
+			})
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		body = file
+
+		partType, _, _ := mime.ParseMediaType(header.Header.Get(echo.HeaderContentType))
+		if format, ok = importFormats[partType]; !ok {
+			format = service.ImportFormatOf(header.Filename)
+		}
+	case !ok:
+		return echo.ErrUnsupportedMediaType
+	}
+
+	report, err := bh.BookSVC.Import(c.Request().Context(), service.BookImportParams{
+		Format: format,
+		Data:   body,
+		DryRun: req.DryRun,
+	})
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	type RowError struct {
+		Row     int    `json:"row"`
+		Message string `json:"message"`
+	}
+	type Resp struct {
+		DryRun    bool       `json:"dry_run"`
+		Created   int        `json:"created"`
+		Updated   int        `json:"updated"`
+		Unchanged int        `json:"unchanged"`
+		Errors    []RowError `json:"errors"`
+	}
+	resp := Resp{
+		DryRun:    req.DryRun,
+		Created:   report.Created,
+		Updated:   report.Updated,
+		Unchanged: report.Unchanged,
+		Errors:    make([]RowError, 0, len(report.Errors)),
+	}
+	for _, rowErr := range report.Errors {
+		resp.Errors = append(resp.Errors, RowError{
+			Row:     rowErr.Row,
+			Message: rowErr.Err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
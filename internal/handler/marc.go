@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/service"
+)
+
+type recordWriter interface {
+	Write(rec *marc.Record) error
+	Close() error
+}
+
+// marcExport describes a format books can be exported in.
+type marcExport struct {
+	mediaType string
+	ext       string
+	newWriter func(w io.Writer) recordWriter
+}
+
+var marcExports = map[string]marcExport{
+	"marcxml": {
+		mediaType: marc.XMLMIMEType,
+		ext:       "xml",
+		newWriter: func(w io.Writer) recordWriter { return marc.NewXMLWriter(w) },
+	},
+	"marc": {
+		mediaType: marc.MIMEType,
+		ext:       "mrc",
+		newWriter: func(w io.Writer) recordWriter { return marc.NewWriter(w) },
+	},
+}
+
+func (bh BookHandler) Export(c echo.Context) error {
+	type Req struct {
+		ID     int32  `param:"id"`
+		Format string `query:"format"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if req.Format == "" {
+		req.Format = "marcxml"
+	}
+	export, ok := marcExports[req.Format]
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"type":    "validation",
+			"message": "format: must be marcxml or marc",
+		})
+	}
+
+	rec, err := bh.BookSVC.GetMARCByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrBookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "book not found",
+			})
+		}
+
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := export.newWriter(&buf)
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("book-%d.%s", req.ID, export.ext)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, export.mediaType, buf.Bytes())
+}
+
+func (bh BookHandler) ExportAll(c echo.Context) error {
+	type Req struct {
+		Format string `query:"format"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if req.Format == "" {
+		req.Format = "marcxml"
+	}
+	export, ok := marcExports[req.Format]
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"type":    "validation",
+			"message": "format: must be marcxml or marc",
+		})
+	}
+
+	// The catalogue is streamed, so errors past this point can only cut the
+	// response short.
+	filename := "books." + export.ext
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().Header().Set(echo.HeaderContentType, export.mediaType)
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := export.newWriter(c.Response())
+	if err := bh.BookSVC.ExportMARC(c.Request().Context(), w.Write); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
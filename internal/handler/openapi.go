@@ -0,0 +1,1383 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/idempotency"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/openapi"
+	"github.com/utilyre/lms/internal/ratelimit"
+)
+
+var OpenAPI = sync.OnceValue(func() *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:       "LMS",
+			Description: "Library Management System API",
+			Version:     "1.0.0",
+		},
+		Servers: []openapi.Server{{URL: "/api/v1"}},
+		Paths: map[string]*openapi.PathItem{
+			"/auth/login": {
+				Post: &openapi.Operation{
+					OperationID: "login",
+					Summary:     "Exchange credentials for an access token",
+					Tags:        []string{"auth"},
+					RequestBody: jsonBody("Login"),
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Access token", openapi.Ref("AccessToken")),
+						"401": errorResponse("Invalid email or password"),
+						"429": withRetryAfter(errorResponse("Too many failed logins for the account or address")),
+					},
+				},
+			},
+			"/auth/refresh": {
+				Post: &openapi.Operation{
+					OperationID: "refreshToken",
+					Summary:     "Exchange a refresh token for new tokens",
+					Tags:        []string{"auth"},
+					RequestBody: jsonBody("RefreshToken"),
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Access token", openapi.Ref("AccessToken")),
+						"401": errorResponse("Refresh token is invalid, expired, revoked or reused"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/auth/logout": {
+				Post: &openapi.Operation{
+					OperationID: "logout",
+					Summary:     "Revoke the session of a refresh token",
+					Tags:        []string{"auth"},
+					RequestBody: jsonBody("RefreshToken"),
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Logged out", openapi.Ref("Message")),
+						"401": errorResponse("Unknown refresh token"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/api-keys/": {
+				Post: &openapi.Operation{
+					OperationID: "createAPIKey",
+					Summary:     "Create an API key",
+					Tags:        []string{"api-keys"},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					RequestBody: jsonBody("APIKeyCreate"),
+					Responses: map[string]openapi.Response{
+						"201": jsonResponse("Created API key, along with the key itself", openapi.Ref("CreatedAPIKey")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("API keys can't manage API keys"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Get: &openapi.Operation{
+					OperationID: "listAPIKeys",
+					Summary:     "List the API keys of the caller",
+					Tags:        []string{"api-keys"},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("API keys", openapi.ArrayOf(openapi.Ref("APIKey"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("API keys can't manage API keys"),
+					},
+				},
+			},
+			"/api-keys/{id}": {
+				Delete: &openapi.Operation{
+					OperationID: "deleteAPIKey",
+					Summary:     "Revoke an API key of the caller",
+					Tags:        []string{"api-keys"},
+					Parameters:  []openapi.Parameter{idParam("API key")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("API key deleted", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("API keys can't manage API keys"),
+						"404": errorResponse("API key not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/": {
+				Post: &openapi.Operation{
+					OperationID: "createUser",
+					Summary:     "Create a user",
+					Description: "Anyone may sign up, but only admins may choose the role of the users they create.",
+					Tags:        []string{"users"},
+					RequestBody: jsonBody("UserCreate"),
+					Responses: map[string]openapi.Response{
+						"201": withETag(jsonResponse("Created user", openapi.Ref("User"))),
+						"401": errorResponse("Role set without authentication"),
+						"403": errorResponse("Caller isn't an admin but set the role"),
+						"409": errorResponse("User already exists"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}": {
+				Get: &openapi.Operation{
+					OperationID: "getUser",
+					Summary:     "Get a user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user"), ifNoneMatchParam()},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Found user", openapi.Ref("User"))),
+						"304": withETag(openapi.Response{Description: "User not modified"}),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Put: &openapi.Operation{
+					OperationID: "updateUser",
+					Summary:     "Replace a user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user"), ifMatchParam()},
+					RequestBody: jsonBody("UserUpdate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Updated user", openapi.Ref("User"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin, or isn't an admin but set the role"),
+						"404": errorResponse("User not found"),
+						"409": errorResponse("Email taken by another user"),
+						"412": errorResponse("User has been modified"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Patch: &openapi.Operation{
+					OperationID: "patchUser",
+					Summary:     "Update some fields of a user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user"), ifMatchParam()},
+					RequestBody: mergePatchBody("UserPatch"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Updated user", openapi.Ref("User"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin, or isn't an admin but set the role"),
+						"404": errorResponse("User not found"),
+						"409": errorResponse("Email taken by another user"),
+						"412": errorResponse("User has been modified"),
+						"415": jsonResponse("Body is not a merge patch", openapi.Ref("Message")),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Delete: &openapi.Operation{
+					OperationID: "deleteUser",
+					Summary:     "Delete a user",
+					Description: "Deleted users keep their loans and can be restored until they're purged.",
+					Tags:        []string{"users"},
+					Parameters: []openapi.Parameter{
+						idParam("user"),
+						ifMatchParam(),
+						queryParam("force", "Return open loans and cancel active reservations instead of refusing to delete. Only admins may force deletion.", &openapi.Schema{Type: "boolean"}),
+					},
+					Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("User deleted", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin, or isn't an admin but forced deletion"),
+						"404": errorResponse("User not found"),
+						"409": jsonResponse("User has open loans or active reservations", openapi.Ref("InUseError")),
+						"412": errorResponse("User has been modified"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/password": {
+				Put: &openapi.Operation{
+					OperationID: "changePassword",
+					Summary:     "Change the password of a user",
+					Description: "Also revokes every session of the user.",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					RequestBody: jsonBody("PasswordChange"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Password changed", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed or current password is wrong"),
+					},
+				},
+			},
+			"/users/{id}/lockout": {
+				Delete: &openapi.Operation{
+					OperationID: "unlockUser",
+					Summary:     "Lift the login lockout of a user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("User unlocked", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/sessions": {
+				Delete: &openapi.Operation{
+					OperationID: "revokeUserSessions",
+					Summary:     "Revoke every session of a user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Sessions revoked", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/restore": {
+				Post: &openapi.Operation{
+					OperationID: "restoreUser",
+					Summary:     "Restore a deleted user",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Restored user", openapi.Ref("User"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"409": errorResponse("User isn't deleted, or another user has taken its email"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/purge": {
+				Delete: &openapi.Operation{
+					OperationID: "purgeUser",
+					Summary:     "Permanently remove a deleted user",
+					Description: "Removes the user along with their loans, reservations, sessions and API keys.",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("User purged", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"409": errorResponse("User isn't deleted"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/export": {
+				Get: &openapi.Operation{
+					OperationID: "exportUser",
+					Summary:     "Export everything kept about a user",
+					Description: "Works for deleted users too, to answer data subject access requests.",
+					Tags:        []string{"users"},
+					Parameters: []openapi.Parameter{
+						idParam("user"),
+						queryParam("format", "Whether to export a JSON document or a ZIP archive with a JSON file for each of its fields", &openapi.Schema{
+							Type:    "string",
+							Enum:    []string{"json", "zip"},
+							Default: "json",
+						}),
+					},
+					Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": {
+							Description: "Exported user, as an attachment",
+							Content: map[string]openapi.MediaType{
+								"application/json": {Schema: openapi.Ref("UserExport")},
+								"application/zip":  {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+							},
+						},
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/users/{id}/anonymize": {
+				Post: &openapi.Operation{
+					OperationID: "anonymizeUser",
+					Summary:     "Anonymize a user",
+					Description: "Replaces the name, email and password of the user, deleted or not, removes their sessions, API keys, password resets and login attempts, and clears the IP of the actions they took from the audit log. Their loans and reservations are kept.",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Anonymized user", openapi.Ref("User"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("User not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/password-resets/": {
+				Post: &openapi.Operation{
+					OperationID: "requestPasswordReset",
+					Summary:     "Mail a password reset token",
+					Tags:        []string{"users"},
+					RequestBody: jsonBody("PasswordResetRequest"),
+					Responses: map[string]openapi.Response{
+						"202": jsonResponse("Token mailed if a user has the email", openapi.Ref("Message")),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/password-resets/confirm": {
+				Post: &openapi.Operation{
+					OperationID: "resetPassword",
+					Summary:     "Reset a password with a mailed token",
+					Description: "Also revokes every session of the user.",
+					Tags:        []string{"users"},
+					RequestBody: jsonBody("PasswordReset"),
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Password reset", openapi.Ref("Message")),
+						"422": errorResponse("Validation failed or token is invalid, expired or used"),
+					},
+				},
+			},
+			"/books/": {
+				Get: &openapi.Operation{
+					OperationID: "listBooks",
+					Summary:     "List books, oldest first",
+					Tags:        []string{"books"},
+					Parameters: []openapi.Parameter{
+						queryParam("author_id", "Only books by this author", idSchema()),
+						queryParam("publisher_id", "Only books from this publisher", idSchema()),
+						queryParam("subject_id", "Only books about this subject", idSchema()),
+						queryParam("category_id", "Only books filed under this category or any of its subcategories", idSchema()),
+						queryParam("tag_id", "Only books with this tag", idSchema()),
+						queryParam("language", "Only books in this language", languageSchema()),
+						queryParam("year_from", "Only books published in or after this year", &openapi.Schema{Type: "integer", Minimum: ptr(1.0)}),
+						queryParam("year_to", "Only books published in or before this year", &openapi.Schema{Type: "integer", Minimum: ptr(1.0)}),
+						queryParam("after_id", "Only books newer than this one, for paging", idSchema()),
+						limitParam("books"),
+					},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Books", openapi.ArrayOf(openapi.Ref("Book"))),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Post: &openapi.Operation{
+					OperationID: "createBook",
+					Summary:     "Create a book",
+					Tags:        []string{"books"},
+					RequestBody: jsonBody("BookCreate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"201": withETag(jsonResponse("Created book", openapi.Ref("Book"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/shelf": {
+				Get: &openapi.Operation{
+					OperationID: "listShelf",
+					Summary:     "List books by call number, in shelf order",
+					Description: "The range includes the call numbers to is a prefix of, so that to=QA76 includes QA76.73.G63 D66 2015.",
+					Tags:        []string{"books"},
+					Parameters: []openapi.Parameter{
+						{
+							Name:        "classification",
+							In:          "query",
+							Description: "Classification of the call numbers",
+							Required:    true,
+							Schema:      classificationSchema(),
+						},
+						queryParam("from", "Only books with this call number or a later one", &openapi.Schema{Type: "string", Example: "QA76"}),
+						queryParam("to", "Only books with this call number or an earlier one", &openapi.Schema{Type: "string", Example: "QA76.9"}),
+						queryParam("after_id", "Only books shelved after this one, for paging", idSchema()),
+						limitParam("books"),
+					},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Books", openapi.ArrayOf(openapi.Ref("Book"))),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/import": {
+				Post: &openapi.Operation{
+					OperationID: "importBooks",
+					Summary:     "Import books from a CSV file or MARC 21 records",
+					Description: "A CSV file starts with a header naming its columns, of which title, author and isbn are required and " +
+						"publisher, subject, tag, publication_year, edition, language, page_count, classification, call_number " +
+						"and availability_status are optional. " +
+						"The author, publisher, subject and tag columns hold names separated by semicolons. " +
+						"MARC records are mapped by their 008, 020, 041, 050, 082, 100, 110, 245, 250, 260, 264, 300, 650, 651, 653, " +
+						"700 and 710 fields. " +
+						"Each row or record creates a book when none has its ISBN and updates the book that has it otherwise, " +
+						"leaving the optional fields the row or record doesn't have alone. " +
+						"Invalid rows are skipped and reported while the rest are imported. " +
+						"The format of a form's file is told by its media type or, failing that, its extension.",
+					Tags: []string{"books"},
+					Parameters: []openapi.Parameter{
+						queryParam("dry_run", "Report what the import would do without changing anything", &openapi.Schema{Type: "boolean"}),
+					},
+					RequestBody: &openapi.RequestBody{
+						Required: true,
+						Content: map[string]openapi.MediaType{
+							MIMETextCSV:      {Schema: &openapi.Schema{Type: "string"}},
+							marc.MIMEType:    {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+							marc.XMLMIMEType: {Schema: &openapi.Schema{Type: "string"}},
+							echo.MIMEMultipartForm: {Schema: object(map[string]*openapi.Schema{
+								"file": {Type: "string", Format: "binary"},
+							}, "file")},
+						},
+					},
+					Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("What the import did, or would have done on a dry run", openapi.Ref("BookImportReport")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"415": jsonResponse("Body is in none of the supported formats", openapi.Ref("Message")),
+						"422": errorResponse("File is malformed or misses a required column"),
+					},
+				},
+			},
+			"/books/export": {
+				Get: &openapi.Operation{
+					OperationID: "exportBooks",
+					Summary:     "Export every book as MARC 21 records",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{marcFormatParam()},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": marcResponse("Exported books, as an attachment"),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/{id}/export": {
+				Get: &openapi.Operation{
+					OperationID: "exportBook",
+					Summary:     "Export a book as a MARC 21 record",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book"), marcFormatParam()},
+					Responses: map[string]openapi.Response{
+						"200": marcResponse("Exported book, as an attachment"),
+						"404": errorResponse("Book not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/{id}": {
+				Get: &openapi.Operation{
+					OperationID: "getBook",
+					Summary:     "Get a book",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book"), ifNoneMatchParam()},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Found book", openapi.Ref("Book"))),
+						"304": withETag(openapi.Response{Description: "Book not modified"}),
+						"404": errorResponse("Book not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Put: &openapi.Operation{
+					OperationID: "updateBook",
+					Summary:     "Replace a book",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book"), ifMatchParam()},
+					RequestBody: jsonBody("BookUpdate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Updated book", openapi.Ref("Book"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Book not found"),
+						"412": errorResponse("Book has been modified"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Patch: &openapi.Operation{
+					OperationID: "patchBook",
+					Summary:     "Update some fields of a book",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book"), ifMatchParam()},
+					RequestBody: mergePatchBody("BookPatch"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Updated book", openapi.Ref("Book"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Book not found"),
+						"412": errorResponse("Book has been modified"),
+						"415": jsonResponse("Body is not a merge patch", openapi.Ref("Message")),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Delete: &openapi.Operation{
+					OperationID: "deleteBook",
+					Summary:     "Delete a book",
+					Description: "Deleted books keep their loans and can be restored until they're purged.",
+					Tags:        []string{"books"},
+					Parameters: []openapi.Parameter{
+						idParam("book"),
+						ifMatchParam(),
+						queryParam("force", "Return open loans and cancel active reservations instead of refusing to delete", &openapi.Schema{Type: "boolean"}),
+					},
+					Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Book deleted", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Book not found"),
+						"409": jsonResponse("Book has open loans or active reservations", openapi.Ref("InUseError")),
+						"412": errorResponse("Book has been modified"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/{id}/restore": {
+				Post: &openapi.Operation{
+					OperationID: "restoreBook",
+					Summary:     "Restore a deleted book",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": withETag(jsonResponse("Restored book", openapi.Ref("Book"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Book not found"),
+						"409": errorResponse("Book isn't deleted"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/books/{id}/purge": {
+				Delete: &openapi.Operation{
+					OperationID: "purgeBook",
+					Summary:     "Permanently remove a deleted book",
+					Description: "Removes the book along with its loans and reservations.",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("book")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Book purged", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Book not found"),
+						"409": errorResponse("Book isn't deleted"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/loans/": {
+				Post: &openapi.Operation{
+					OperationID: "borrowBook",
+					Summary:     "Borrow a book",
+					Tags:        []string{"loans"},
+					Parameters:  []openapi.Parameter{idempotencyKeyParam()},
+					RequestBody: jsonBody("LoanCreate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"201": jsonResponse("Created loan", openapi.Ref("Loan")),
+						"400": errorResponse("Idempotency key is too long"),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin"),
+						"404": errorResponse("User or book not found"),
+						"409": errorResponse("Book already borrowed or reserved, or a request with the same idempotency key is in progress"),
+						"413": errorResponse("Request body with an idempotency key is larger than 1 MiB"),
+						"422": errorResponse("Validation failed, or the idempotency key was used for a different request"),
+					},
+				},
+			},
+			"/loans/{id}": {
+				Put: &openapi.Operation{
+					OperationID: "returnLoan",
+					Summary:     "Return a borrowed book",
+					Tags:        []string{"loans"},
+					Parameters:  []openapi.Parameter{idParam("loan")},
+					RequestBody: jsonBody("LoanReturn"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Returned loan", openapi.Ref("Loan")),
+						"401": errorResponse("Authentication required"),
+						"404": errorResponse("Loan not found, or caller is neither its user nor an admin"),
+						"409": errorResponse("Loan already returned"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/reservations/": {
+				Post: &openapi.Operation{
+					OperationID: "reserveBook",
+					Summary:     "Reserve a book",
+					Tags:        []string{"reservations"},
+					Parameters:  []openapi.Parameter{idempotencyKeyParam()},
+					RequestBody: jsonBody("ReservationCreate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"201": jsonResponse("Created reservation", openapi.Ref("Reservation")),
+						"400": errorResponse("Idempotency key is too long"),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin"),
+						"404": errorResponse("User or book not found"),
+						"409": errorResponse("A request with the same idempotency key is in progress"),
+						"413": errorResponse("Request body with an idempotency key is larger than 1 MiB"),
+						"422": errorResponse("Validation failed, or the idempotency key was used for a different request"),
+					},
+				},
+			},
+			"/reservations/{id}": {
+				Delete: &openapi.Operation{
+					OperationID: "cancelReservation",
+					Summary:     "Cancel a reservation",
+					Tags:        []string{"reservations"},
+					Parameters:  []openapi.Parameter{idParam("reservation")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Reservation canceled", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"404": errorResponse("Reservation not found, or caller is neither its user nor an admin"),
+						"409": errorResponse("Reservation already canceled"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/reports/overdue-loans": {
+				Get: &openapi.Operation{
+					OperationID: "getOverdueLoans",
+					Summary:     "List overdue loans",
+					Tags:        []string{"reports"},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Overdue loans", openapi.ArrayOf(openapi.Ref("Loan"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+					},
+				},
+			},
+			"/reports/popular-books": {
+				Get: &openapi.Operation{
+					OperationID: "getPopularBooks",
+					Summary:     "List the ten most borrowed books",
+					Tags:        []string{"reports"},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Popular books", openapi.ArrayOf(openapi.Ref("PopularBook"))),
+					},
+				},
+			},
+			"/reports/user-activity/{id}": {
+				Get: &openapi.Operation{
+					OperationID: "getUserActivity",
+					Summary:     "List the loans of a user",
+					Tags:        []string{"reports"},
+					Parameters:  []openapi.Parameter{idParam("user")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Loans of the user", openapi.ArrayOf(openapi.Ref("UserActivityLoan"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller is neither the user nor an admin"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/authors/":        namedListPath("authors", "Author"),
+			"/authors/{id}":    namedPath("author", "Author"),
+			"/publishers/":     namedListPath("publishers", "Publisher"),
+			"/publishers/{id}": namedPath("publisher", "Publisher"),
+			"/subjects/":       namedListPath("subjects", "Subject"),
+			"/subjects/{id}":   namedPath("subject", "Subject"),
+			"/tags/":           namedListPath("tags", "Tag"),
+			"/tags/{id}":       namedPath("tag", "Tag"),
+			"/categories/": {
+				Get: &openapi.Operation{
+					OperationID: "getCategoryTree",
+					Summary:     "Get the tree of categories",
+					Description: "Every level of the tree is ordered by name.",
+					Tags:        []string{"books"},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Root categories", openapi.ArrayOf(openapi.Ref("CategoryNode"))),
+					},
+				},
+				Post: &openapi.Operation{
+					OperationID: "createCategory",
+					Summary:     "Create a category",
+					Tags:        []string{"books"},
+					RequestBody: jsonBody("CategoryCreate"),
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"201": jsonResponse("Created category", openapi.Ref("CategoryNode")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"409": errorResponse("Parent already has a category of this name"),
+						"422": errorResponse("Validation failed or parent not found"),
+					},
+				},
+			},
+			"/categories/{id}": {
+				Get: &openapi.Operation{
+					OperationID: "getCategory",
+					Summary:     "Get a category with its ancestors and subcategories",
+					Description: "List its books, including those of its subcategories, with GET /books/?category_id={id}.",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("category")},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Found category", openapi.Ref("Category")),
+						"404": errorResponse("Category not found"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+				Delete: &openapi.Operation{
+					OperationID: "deleteCategory",
+					Summary:     "Delete a category",
+					Description: "Its books stay in the catalogue, no longer filed under it.",
+					Tags:        []string{"books"},
+					Parameters:  []openapi.Parameter{idParam("category")},
+					Security:    []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Category deleted", openapi.Ref("Message")),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"404": errorResponse("Category not found"),
+						"409": errorResponse("Category has subcategories"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+			"/audit-log": {
+				Get: &openapi.Operation{
+					OperationID: "listAuditLog",
+					Summary:     "List audit log entries from newest to oldest",
+					Tags:        []string{"audit"},
+					Parameters: []openapi.Parameter{
+						queryParam("actor_id", "Only entries made by this user", idSchema()),
+						queryParam("action", "Only entries of this action", &openapi.Schema{
+							Type: "string",
+							Enum: []string{
+								audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete,
+								audit.ActionBorrow, audit.ActionReturn, audit.ActionReserve, audit.ActionCancel,
+								audit.ActionRestore, audit.ActionPurge, audit.ActionAnonymize,
+							},
+						}),
+						queryParam("entity", "Only entries about this kind of entity", &openapi.Schema{
+							Type: "string",
+							Enum: []string{"user", "book", "loan", "reservation", "api_key", "category"},
+						}),
+						queryParam("entity_id", "Only entries about the entity with this ID", idSchema()),
+						queryParam("since", "Only entries made at or after this time", &openapi.Schema{Type: "string", Format: "date-time"}),
+						queryParam("until", "Only entries made at or before this time", &openapi.Schema{Type: "string", Format: "date-time"}),
+						queryParam("before_id", "Only entries older than this one, for paging", idSchema()),
+						queryParam("limit", "How many entries to list at most", &openapi.Schema{
+							Type:    "integer",
+							Minimum: ptr(1.0),
+							Maximum: ptr(500.0),
+							Example: 50,
+						}),
+					},
+					Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+					Responses: map[string]openapi.Response{
+						"200": jsonResponse("Audit log entries", openapi.ArrayOf(openapi.Ref("AuditEntry"))),
+						"401": errorResponse("Authentication required"),
+						"403": errorResponse("Caller isn't an admin"),
+						"422": errorResponse("Validation failed"),
+					},
+				},
+			},
+		},
+		Security: []openapi.SecurityRequirement{{}, {"bearerAuth": {}}, {"apiKeyAuth": {}}},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Access token from /auth/login",
+				},
+				"apiKeyAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "API key",
+					Description:  "API key from /api-keys. Requests outside of its scopes are rejected with 403.",
+				},
+			},
+			Schemas: map[string]*openapi.Schema{
+				"Login": object(map[string]*openapi.Schema{
+					"email":    {Type: "string", Format: "email"},
+					"password": {Type: "string", Format: "password"},
+				}, "email", "password"),
+				"AccessToken": object(map[string]*openapi.Schema{
+					"access_token":  {Type: "string"},
+					"token_type":    {Type: "string", Enum: []string{"Bearer"}},
+					"expires_in":    {Type: "integer", Description: "Seconds until the access token expires"},
+					"refresh_token": {Type: "string", Description: "Single-use token for getting new tokens"},
+				}, "access_token", "token_type", "expires_in", "refresh_token"),
+				"RefreshToken": object(map[string]*openapi.Schema{
+					"refresh_token": {Type: "string"},
+				}, "refresh_token"),
+				"APIKey": apiKeySchema(),
+				"APIKeyCreate": object(map[string]*openapi.Schema{
+					"name":       {Type: "string", MinLength: ptr(1), MaxLength: ptr(100)},
+					"scopes":     openapi.ArrayOf(&openapi.Schema{Type: "string", Enum: auth.Scopes()}),
+					"expires_at": {Type: "string", Format: "date-time", Description: "Omit for keys that never expire."},
+				}, "name", "scopes"),
+				"CreatedAPIKey": func() *openapi.Schema {
+					schema := apiKeySchema()
+					schema.Properties["key"] = &openapi.Schema{Type: "string", Description: "Shown only once"}
+					schema.Required = append(schema.Required, "key")
+					return schema
+				}(),
+				"AuditEntry": object(map[string]*openapi.Schema{
+					"id":         {Type: "integer", Format: "int32"},
+					"actor_id":   {Type: "integer", Format: "int32", Nullable: true, Description: "Null for anonymous actions"},
+					"api_key_id": {Type: "integer", Format: "int32", Nullable: true},
+					"action":     {Type: "string"},
+					"entity":     {Type: "string"},
+					"entity_id":  {Type: "integer", Format: "int32"},
+					"before":     {Type: "object", Nullable: true, Description: "Columns of the entity before the action"},
+					"after":      {Type: "object", Nullable: true, Description: "Columns of the entity after the action"},
+					"ip":         {Type: "string"},
+					"request_id": {Type: "string"},
+					"created_at": {Type: "string", Format: "date-time"},
+				}, "id", "actor_id", "api_key_id", "action", "entity", "entity_id", "before", "after", "ip", "request_id", "created_at"),
+				"DateOnly": {
+					Type:        "string",
+					Format:      "date",
+					Description: "Calendar date without time of day.",
+					Example:     "2024-10-25",
+				},
+				"Error": object(map[string]*openapi.Schema{
+					"type":    {Type: "string", Enum: []string{"validation", "resource", "logic"}},
+					"message": {Type: "string"},
+				}, "type", "message"),
+				"InUseError": object(map[string]*openapi.Schema{
+					"type":         {Type: "string", Enum: []string{"logic"}},
+					"message":      {Type: "string"},
+					"loans":        openapi.ArrayOf(&openapi.Schema{Type: "integer", Format: "int32"}),
+					"reservations": openapi.ArrayOf(&openapi.Schema{Type: "integer", Format: "int32"}),
+				}, "type", "message", "loans", "reservations"),
+				"UserExport": object(map[string]*openapi.Schema{
+					"user": object(map[string]*openapi.Schema{
+						"id":         {Type: "integer", Format: "int32"},
+						"name":       {Type: "string"},
+						"email":      {Type: "string", Format: "email"},
+						"role":       {Type: "string"},
+						"deleted_at": {Type: "string", Format: "date-time", Nullable: true},
+					}, "id", "name", "email", "role", "deleted_at"),
+					"loans": openapi.ArrayOf(object(map[string]*openapi.Schema{
+						"id":          {Type: "integer", Format: "int32"},
+						"book_id":     {Type: "integer", Format: "int32"},
+						"loan_date":   {Type: "string", Format: "date-time"},
+						"due_date":    {Type: "string", Format: "date-time"},
+						"return_date": {Type: "string", Format: "date-time", Nullable: true},
+					}, "id", "book_id", "loan_date", "due_date", "return_date")),
+					"reservations": openapi.ArrayOf(object(map[string]*openapi.Schema{
+						"id":          {Type: "integer", Format: "int32"},
+						"book_id":     {Type: "integer", Format: "int32"},
+						"canceled_at": {Type: "string", Format: "date-time", Nullable: true},
+					}, "id", "book_id", "canceled_at")),
+					"api_keys": openapi.ArrayOf(openapi.Ref("APIKey")),
+					"login_attempts": openapi.ArrayOf(object(map[string]*openapi.Schema{
+						"email":      {Type: "string"},
+						"ip":         {Type: "string"},
+						"succeeded":  {Type: "boolean"},
+						"created_at": {Type: "string", Format: "date-time"},
+					}, "email", "ip", "succeeded", "created_at")),
+					"audit_log": openapi.ArrayOf(openapi.Ref("AuditEntry")),
+				}, "user", "loans", "reservations", "api_keys", "login_attempts", "audit_log"),
+				"Message": object(map[string]*openapi.Schema{
+					"message": {Type: "string"},
+				}, "message"),
+				"User": object(map[string]*openapi.Schema{
+					"id":    {Type: "integer", Format: "int32"},
+					"name":  {Type: "string"},
+					"email": {Type: "string", Format: "email"},
+					"role":  {Type: "string"},
+				}, "id", "name", "email", "role"),
+				"UserCreate": object(map[string]*openapi.Schema{
+					"name":     {Type: "string", MinLength: ptr(1)},
+					"email":    {Type: "string", Format: "email"},
+					"password": {Type: "string", Format: "password", MinLength: ptr(8), MaxLength: ptr(72)},
+					"role":     {Type: "string", Description: "Only admins may set it.", Default: "member"},
+				}, "name", "email", "password"),
+				"UserUpdate": object(map[string]*openapi.Schema{
+					"name":  {Type: "string", MinLength: ptr(1)},
+					"email": {Type: "string", Format: "email"},
+					"role":  {Type: "string", Description: "Only admins may set it. Leaving it out keeps that of the user."},
+				}, "name", "email"),
+				"UserPatch": object(map[string]*openapi.Schema{
+					"name":  {Type: "string", MinLength: ptr(1)},
+					"email": {Type: "string", Format: "email"},
+					"role":  {Type: "string", Nullable: true, Description: "Only admins may set it."},
+				}),
+				"PasswordChange": object(map[string]*openapi.Schema{
+					"current_password": {Type: "string", Format: "password"},
+					"new_password":     {Type: "string", Format: "password", MinLength: ptr(8), MaxLength: ptr(72)},
+				}, "current_password", "new_password"),
+				"PasswordResetRequest": object(map[string]*openapi.Schema{
+					"email": {Type: "string", Format: "email"},
+				}, "email"),
+				"PasswordReset": object(map[string]*openapi.Schema{
+					"token":    {Type: "string"},
+					"password": {Type: "string", Format: "password", MinLength: ptr(8), MaxLength: ptr(72)},
+				}, "token", "password"),
+				"Book": object(map[string]*openapi.Schema{
+					"id":                  {Type: "integer", Format: "int32"},
+					"title":               {Type: "string"},
+					"authors":             openapi.ArrayOf(openapi.Ref("Author")),
+					"publishers":          openapi.ArrayOf(openapi.Ref("Publisher")),
+					"subjects":            openapi.ArrayOf(openapi.Ref("Subject")),
+					"isbn":                {Type: "string"},
+					"publication_year":    {Type: "integer"},
+					"edition":             {Type: "string"},
+					"language":            languageSchema(),
+					"page_count":          {Type: "integer"},
+					"categories":          openapi.ArrayOf(openapi.Ref("CategoryRef")),
+					"tags":                openapi.ArrayOf(openapi.Ref("Tag")),
+					"classification":      classificationSchema(),
+					"call_number":         {Type: "string"},
+					"availability_status": {Type: "string"},
+				}, "id", "title", "authors", "publishers", "subjects", "categories", "tags", "isbn", "availability_status"),
+				"Author":    namedSchema(),
+				"Publisher": namedSchema(),
+				"Subject":   namedSchema(),
+				"Tag":       namedSchema(),
+				"CategoryRef": object(map[string]*openapi.Schema{
+					"id":        {Type: "integer", Format: "int32"},
+					"parent_id": {Type: "integer", Format: "int32", Description: "Absent for root categories"},
+					"name":      {Type: "string"},
+				}, "id", "name"),
+				"CategoryNode": object(map[string]*openapi.Schema{
+					"id":        {Type: "integer", Format: "int32"},
+					"parent_id": {Type: "integer", Format: "int32", Description: "Absent for root categories"},
+					"name":      {Type: "string"},
+					"children":  openapi.ArrayOf(openapi.Ref("CategoryNode")),
+				}, "id", "name", "children"),
+				"Category": object(map[string]*openapi.Schema{
+					"id":        {Type: "integer", Format: "int32"},
+					"parent_id": {Type: "integer", Format: "int32", Description: "Absent for root categories"},
+					"name":      {Type: "string"},
+					"ancestors": {
+						Type:        "array",
+						Description: "Categories above this one, from the root down",
+						Items:       openapi.Ref("CategoryRef"),
+					},
+					"children": openapi.ArrayOf(openapi.Ref("CategoryNode")),
+				}, "id", "name", "ancestors", "children"),
+				"CategoryCreate": object(map[string]*openapi.Schema{
+					"name": {Type: "string", MinLength: ptr(1), MaxLength: ptr(100)},
+					"parent_id": {
+						Type:        "integer",
+						Format:      "int32",
+						Description: "Category to create it under, or none to create it at the root",
+						Minimum:     ptr(1.0),
+					},
+				}, "name"),
+				"BookImportReport": object(map[string]*openapi.Schema{
+					"dry_run":   {Type: "boolean"},
+					"created":   {Type: "integer"},
+					"updated":   {Type: "integer"},
+					"unchanged": {Type: "integer"},
+					"errors": openapi.ArrayOf(object(map[string]*openapi.Schema{
+						"row":     {Type: "integer", Description: "Line the row starts on, counting the header as line 1"},
+						"message": {Type: "string"},
+					}, "row", "message")),
+				}, "dry_run", "created", "updated", "unchanged", "errors"),
+				"BookCreate": object(bookProperties(false), "title", "isbn"),
+				"BookUpdate": object(withProperty(bookProperties(false), "availability_status", &openapi.Schema{Type: "string"}),
+					"title", "isbn"),
+				"BookPatch": object(withProperty(bookProperties(true), "availability_status", &openapi.Schema{Type: "string", Nullable: true})),
+				"Loan": object(map[string]*openapi.Schema{
+					"id":          {Type: "integer", Format: "int32"},
+					"user_id":     {Type: "integer", Format: "int32"},
+					"book_id":     {Type: "integer", Format: "int32"},
+					"loan_date":   openapi.Ref("DateOnly"),
+					"due_date":    openapi.Ref("DateOnly"),
+					"return_date": openapi.Ref("DateOnly"),
+				}, "id", "user_id", "book_id", "loan_date", "due_date"),
+				"LoanCreate": object(map[string]*openapi.Schema{
+					"user_id": idSchema(),
+					"book_id": idSchema(),
+				}, "user_id", "book_id"),
+				"LoanReturn": object(map[string]*openapi.Schema{
+					"return_date": openapi.Ref("DateOnly"),
+				}, "return_date"),
+				"Reservation": object(map[string]*openapi.Schema{
+					"id":      {Type: "integer", Format: "int32"},
+					"user_id": {Type: "integer", Format: "int32"},
+					"book_id": {Type: "integer", Format: "int32"},
+				}, "id", "user_id", "book_id"),
+				"ReservationCreate": object(map[string]*openapi.Schema{
+					"user_id": idSchema(),
+					"book_id": idSchema(),
+				}, "user_id", "book_id"),
+				"PopularBook": object(map[string]*openapi.Schema{
+					"id":      {Type: "integer", Format: "int32"},
+					"title":   {Type: "string"},
+					"borrows": {Type: "integer"},
+				}, "id", "title", "borrows"),
+				"UserActivityLoan": object(map[string]*openapi.Schema{
+					"id":          {Type: "integer", Format: "int32"},
+					"book_id":     {Type: "integer", Format: "int32"},
+					"loan_date":   openapi.Ref("DateOnly"),
+					"due_date":    openapi.Ref("DateOnly"),
+					"return_date": openapi.Ref("DateOnly"),
+				}, "id", "book_id", "loan_date", "due_date"),
+			},
+		},
+	}
+
+	// Rate limits are configured per deployment, so any operation may be
+	// throttled. Likewise, any operation rejects invalid access tokens.
+	for _, item := range doc.Paths {
+		for _, op := range item.Operations() {
+			if _, ok := op.Responses["429"]; !ok {
+				op.Responses["429"] = rateLimitedResponse()
+			}
+			if _, ok := op.Responses["401"]; !ok {
+				op.Responses["401"] = errorResponse("Access token is invalid or expired")
+			}
+		}
+	}
+
+	return doc
+})
+
+func ServeOpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, OpenAPI())
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>LMS API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>
+`
+
+func ServeDocs(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+func apiKeySchema() *openapi.Schema {
+	return object(map[string]*openapi.Schema{
+		"id":           {Type: "integer", Format: "int32"},
+		"name":         {Type: "string"},
+		"prefix":       {Type: "string", Description: "Identifies the key", Example: "lms_1a2b3c4d"},
+		"scopes":       openapi.ArrayOf(&openapi.Schema{Type: "string", Enum: auth.Scopes()}),
+		"created_at":   {Type: "string", Format: "date-time"},
+		"expires_at":   {Type: "string", Format: "date-time", Nullable: true},
+		"last_used_at": {Type: "string", Format: "date-time", Nullable: true},
+	}, "id", "name", "prefix", "scopes", "created_at", "expires_at", "last_used_at")
+}
+
+func object(properties map[string]*openapi.Schema, required ...string) *openapi.Schema {
+	return &openapi.Schema{
+		Type:       "object",
+		Properties: properties,
+		Required:   required,
+	}
+}
+
+func idSchema() *openapi.Schema {
+	return &openapi.Schema{Type: "integer", Format: "int32", Minimum: ptr[float64](1)}
+}
+
+func queryParam(name, description string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      schema,
+	}
+}
+
+func idParam(resource string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "ID of the " + resource,
+		Required:    true,
+		Schema:      idSchema(),
+	}
+}
+
+func idempotencyKeyParam() openapi.Parameter {
+	return openapi.Parameter{
+		Name:        idempotency.HeaderIdempotencyKey,
+		In:          "header",
+		Description: "Unique key, among those of the caller, under which the response is stored and replayed for retries with the same body",
+		Schema:      &openapi.Schema{Type: "string", MaxLength: ptr(255)},
+	}
+}
+
+func ifMatchParam() openapi.Parameter {
+	return openapi.Parameter{
+		Name:        HeaderIfMatch,
+		In:          "header",
+		Description: "ETags of the versions the resource must be at for the request to go through",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+func ifNoneMatchParam() openapi.Parameter {
+	return openapi.Parameter{
+		Name:        HeaderIfNoneMatch,
+		In:          "header",
+		Description: "ETags of cached versions of the resource",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+func jsonBody(schema string) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  openapi.JSON(openapi.Ref(schema)),
+	}
+}
+
+func mergePatchBody(schema string) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content: map[string]openapi.MediaType{
+			MIMEApplicationMergePatchJSON: {Schema: openapi.Ref(schema)},
+		},
+	}
+}
+
+// bookProperties are the fields of books that can be written, which are
+// nullable in patches unless required.
+func bookProperties(patch bool) map[string]*openapi.Schema {
+	names := func(max int) *openapi.Schema {
+		return &openapi.Schema{
+			Type:     "array",
+			Items:    &openapi.Schema{Type: "string", MinLength: ptr(1), MaxLength: ptr(max)},
+			Nullable: patch,
+		}
+	}
+	authors := names(100)
+	authors.MinItems = ptr(1)
+	authors.Nullable = false
+	if !patch {
+		authors.Description = "Required, unless the deprecated author is given instead"
+	}
+	author := &openapi.Schema{
+		Type:        "string",
+		Description: "Sole author, as books had before they could have several. Ignored when authors is given.",
+		MinLength:   ptr(1),
+		MaxLength:   ptr(100),
+		Nullable:    patch,
+		Deprecated:  true,
+	}
+	language := languageSchema()
+	language.Nullable = patch
+	classification := classificationSchema()
+	classification.Nullable = patch
+
+	return map[string]*openapi.Schema{
+		"title":            {Type: "string", MinLength: ptr(1), MaxLength: ptr(100)},
+		"authors":          authors,
+		"author":           author,
+		"publishers":       names(100),
+		"subjects":         names(100),
+		"isbn":             {Type: "string", MinLength: ptr(1), MaxLength: ptr(13)},
+		"publication_year": {Type: "integer", Minimum: ptr(1.0), Nullable: patch},
+		"edition":          {Type: "string", MaxLength: ptr(50), Nullable: patch},
+		"language":         language,
+		"page_count":       {Type: "integer", Minimum: ptr(1.0), Nullable: patch},
+		"categories": {
+			Type:        "array",
+			Description: "IDs of the categories the book is filed under",
+			Items:       idSchema(),
+			Nullable:    patch,
+		},
+		"tags":           names(100),
+		"classification": classification,
+		"call_number": {
+			Type:        "string",
+			Description: "Call number in the classification, which is required along with it",
+			MaxLength:   ptr(50),
+			Example:     "QA76.73.G63 D66 2015",
+			Nullable:    patch,
+		},
+	}
+}
+
+func withProperty(properties map[string]*openapi.Schema, name string, schema *openapi.Schema) map[string]*openapi.Schema {
+	properties[name] = schema
+	return properties
+}
+
+func languageSchema() *openapi.Schema {
+	return &openapi.Schema{
+		Type:        "string",
+		Description: "ISO 639-1 or 639-2 code",
+		MinLength:   ptr(2),
+		MaxLength:   ptr(3),
+		Example:     "eng",
+	}
+}
+
+func classificationSchema() *openapi.Schema {
+	return &openapi.Schema{
+		Type:        "string",
+		Description: "Dewey Decimal or Library of Congress Classification",
+		Enum:        []string{string(callnumber.Dewey), string(callnumber.LCC)},
+	}
+}
+
+func namedSchema() *openapi.Schema {
+	return object(map[string]*openapi.Schema{
+		"id":   {Type: "integer", Format: "int32"},
+		"name": {Type: "string"},
+	}, "id", "name")
+}
+
+// namedListPath documents the endpoint listing authors, publishers, subjects
+// or tags.
+func namedListPath(plural, schema string) *openapi.PathItem {
+	return &openapi.PathItem{
+		Get: &openapi.Operation{
+			OperationID: "list" + schema + "s",
+			Summary:     "List " + plural + " by name",
+			Tags:        []string{"books"},
+			Parameters: []openapi.Parameter{
+				queryParam("prefix", "Only "+plural+" whose name starts with this, regardless of case", &openapi.Schema{Type: "string"}),
+				queryParam("after", "Only "+plural+" whose name sorts after this one, for paging", &openapi.Schema{Type: "string"}),
+				limitParam(plural),
+			},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse(schema+"s", openapi.ArrayOf(openapi.Ref(schema))),
+				"422": errorResponse("Validation failed"),
+			},
+		},
+	}
+}
+
+// namedPath documents the endpoint getting an author, publisher, subject or
+// tag, whose books are listed by filtering books by it.
+func namedPath(kind, schema string) *openapi.PathItem {
+	article := "a"
+	if strings.ContainsRune("aeiou", rune(kind[0])) {
+		article = "an"
+	}
+
+	return &openapi.PathItem{
+		Get: &openapi.Operation{
+			OperationID: "get" + schema,
+			Summary:     "Get " + article + " " + kind,
+			Description: "List its books with GET /books/?" + kind + "_id={id}.",
+			Tags:        []string{"books"},
+			Parameters:  []openapi.Parameter{idParam(kind)},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("Found "+kind, openapi.Ref(schema)),
+				"404": errorResponse(schema + " not found"),
+				"422": errorResponse("Validation failed"),
+			},
+		},
+	}
+}
+
+func limitParam(plural string) openapi.Parameter {
+	return queryParam("limit", "How many "+plural+" to list at most", &openapi.Schema{
+		Type:    "integer",
+		Minimum: ptr(1.0),
+		Maximum: ptr(500.0),
+		Example: 50,
+	})
+}
+
+func marcFormatParam() openapi.Parameter {
+	return queryParam("format", "Whether to export MARCXML or ISO 2709", &openapi.Schema{
+		Type:    "string",
+		Enum:    []string{"marcxml", "marc"},
+		Default: "marcxml",
+	})
+}
+
+func marcResponse(description string) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content: map[string]openapi.MediaType{
+			marc.XMLMIMEType: {Schema: &openapi.Schema{Type: "string"}},
+			marc.MIMEType:    {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		},
+	}
+}
+
+func jsonResponse(description string, schema *openapi.Schema) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content:     openapi.JSON(schema),
+	}
+}
+
+func withETag(response openapi.Response) openapi.Response {
+	response.Headers = map[string]openapi.Header{
+		HeaderETag: {
+			Description: "Version of the resource",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+	}
+
+	return response
+}
+
+func withRetryAfter(response openapi.Response) openapi.Response {
+	if response.Headers == nil {
+		response.Headers = make(map[string]openapi.Header)
+	}
+	response.Headers[echo.HeaderRetryAfter] = openapi.Header{
+		Description: "Seconds until the request may be retried",
+		Schema:      &openapi.Schema{Type: "integer"},
+	}
+
+	return response
+}
+
+func rateLimitedResponse() openapi.Response {
+	response := errorResponse("Rate limit exceeded")
+	response.Headers = map[string]openapi.Header{
+		ratelimit.HeaderRateLimitLimit: {
+			Description: "Number of requests allowed in the window",
+			Schema:      &openapi.Schema{Type: "integer"},
+		},
+		ratelimit.HeaderRateLimitRemaining: {
+			Description: "Number of requests left in the window",
+			Schema:      &openapi.Schema{Type: "integer"},
+		},
+		ratelimit.HeaderRateLimitReset: {
+			Description: "Seconds until the window frees up a request",
+			Schema:      &openapi.Schema{Type: "integer"},
+		},
+	}
+
+	return withRetryAfter(response)
+}
+
+func errorResponse(description string) openapi.Response {
+	return jsonResponse(description, openapi.Ref("Error"))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
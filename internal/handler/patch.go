@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+
+// bindMergePatch binds the path parameters of the request and its JSON Merge
+// Patch (RFC 7396) body to i. Fields of i that should tell absent members
+// apart from null ones are expected to be service.Optional.
+func bindMergePatch(c echo.Context, i any) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != MIMEApplicationMergePatchJSON {
+		return echo.ErrUnsupportedMediaType
+	}
+
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, i); err != nil {
+		return err
+	}
+	if err := c.Echo().JSONSerializer.Deserialize(c, i); err != nil {
+		if errors.Is(err, io.EOF) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Empty merge patch")
+		}
+
+		return err
+	}
+
+	return nil
+}
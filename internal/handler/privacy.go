@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/utilyre/lms/internal/service"
+)
+
+type exportUser struct {
+	ID        int32      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type exportLoan struct {
+	ID         int32      `json:"id"`
+	BookID     int32      `json:"book_id"`
+	LoanDate   time.Time  `json:"loan_date"`
+	DueDate    time.Time  `json:"due_date"`
+	ReturnDate *time.Time `json:"return_date"`
+}
+
+type exportReservation struct {
+	ID         int32      `json:"id"`
+	BookID     int32      `json:"book_id"`
+	CanceledAt *time.Time `json:"canceled_at"`
+}
+
+type exportLoginAttempt struct {
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	Succeeded bool      `json:"succeeded"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (uh UserHandler) Export(c echo.Context) error {
+	type Req struct {
+		ID     int32  `param:"id"`
+		Format string `query:"format"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if req.Format == "" {
+		req.Format = "json"
+	}
+	if req.Format != "json" && req.Format != "zip" {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"type":    "validation",
+			"message": "format: must be json or zip",
+		})
+	}
+
+	export, err := uh.UserSVC.Export(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+
+		return err
+	}
+
+	user := exportUser{
+		ID:    export.User.ID,
+		Name:  export.User.Name,
+		Email: export.User.Email,
+		Role:  export.User.Role,
+	}
+	if !export.User.DeletedAt.IsZero() {
+		user.DeletedAt = &export.User.DeletedAt
+	}
+	loans := make([]exportLoan, 0, len(export.Loans))
+	for _, loan := range export.Loans {
+		elem := exportLoan{
+			ID:       loan.ID,
+			BookID:   loan.BookID,
+			LoanDate: loan.LoanDate,
+			DueDate:  loan.DueDate,
+		}
+		if loan.ReturnDate.Valid {
+			elem.ReturnDate = &loan.ReturnDate.Time
+		}
+		loans = append(loans, elem)
+	}
+	reservations := make([]exportReservation, 0, len(export.Reservations))
+	for _, reservation := range export.Reservations {
+		elem := exportReservation{
+			ID:     reservation.ID,
+			BookID: reservation.BookID,
+		}
+		if reservation.CanceledAt.Valid {
+			elem.CanceledAt = &reservation.CanceledAt.Time
+		}
+		reservations = append(reservations, elem)
+	}
+	apiKeys := make([]apiKeyResp, 0, len(export.APIKeys))
+	for _, key := range export.APIKeys {
+		apiKeys = append(apiKeys, newAPIKeyResp(key))
+	}
+	loginAttempts := make([]exportLoginAttempt, 0, len(export.LoginAttempts))
+	for _, attempt := range export.LoginAttempts {
+		loginAttempts = append(loginAttempts, exportLoginAttempt{
+			Email:     attempt.Email,
+			IP:        attempt.IP,
+			Succeeded: attempt.Succeeded,
+			CreatedAt: attempt.CreatedAt,
+		})
+	}
+	auditLog := make([]auditEntryResp, 0, len(export.AuditLog))
+	for _, entry := range export.AuditLog {
+		auditLog = append(auditLog, newAuditEntryResp(entry))
+	}
+
+	filename := fmt.Sprintf("user-%d.%s", export.User.ID, req.Format)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	if req.Format == "json" {
+		type Resp struct {
+			User          exportUser           `json:"user"`
+			Loans         []exportLoan         `json:"loans"`
+			Reservations  []exportReservation  `json:"reservations"`
+			APIKeys       []apiKeyResp         `json:"api_keys"`
+			LoginAttempts []exportLoginAttempt `json:"login_attempts"`
+			AuditLog      []auditEntryResp     `json:"audit_log"`
+		}
+		return c.JSON(http.StatusOK, Resp{
+			User:          user,
+			Loans:         loans,
+			Reservations:  reservations,
+			APIKeys:       apiKeys,
+			LoginAttempts: loginAttempts,
+			AuditLog:      auditLog,
+		})
+	}
+
+	// The archive holds the same data as the JSON export, with a file for
+	// each part of it.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		v    any
+	}{
+		{name: "user.json", v: user},
+		{name: "loans.json", v: loans},
+		{name: "reservations.json", v: reservations},
+		{name: "api_keys.json", v: apiKeys},
+		{name: "login_attempts.json", v: loginAttempts},
+		{name: "audit_log.json", v: auditLog},
+	} {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.v); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func (uh UserHandler) Anonymize(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	user, err := uh.UserSVC.Anonymize(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+
+	type Resp struct {
+		ID    int32  `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	return c.JSON(http.StatusOK, Resp{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
@@ -19,12 +19,12 @@ func (rh ReportHandler) GetOverdueLoans(c echo.Context) error {
 	}
 
 	type RespElem struct {
-		ID         int32    `json:"id"`
-		UserID     int32    `json:"user_id"`
-		BookID     int32    `json:"book_id"`
-		LoanDate   DateOnly `json:"loan_date"`
-		DueDate    DateOnly `json:"due_date"`
-		ReturnDate DateOnly `json:"return_date,omitempty"`
+		ID         int32     `json:"id"`
+		UserID     int32     `json:"user_id"`
+		BookID     int32     `json:"book_id"`
+		LoanDate   DateOnly  `json:"loan_date"`
+		DueDate    DateOnly  `json:"due_date"`
+		ReturnDate *DateOnly `json:"return_date,omitempty"`
 	}
 	resp := make([]RespElem, len(loans))
 	for i, loan := range loans {
@@ -33,7 +33,9 @@ func (rh ReportHandler) GetOverdueLoans(c echo.Context) error {
 		resp[i].BookID = loan.BookID
 		resp[i].LoanDate = DateOnly{loan.LoanDate}
 		resp[i].DueDate = DateOnly{loan.DueDate}
-		resp[i].ReturnDate = DateOnly{loan.ReturnDate.Time}
+		if loan.ReturnDate.Valid {
+			resp[i].ReturnDate = &DateOnly{loan.ReturnDate.Time}
+		}
 	}
 	return c.JSON(http.StatusOK, resp)
 }
@@ -70,11 +72,11 @@ func (rh ReportHandler) GetUserActivity(c echo.Context) error {
 	}
 
 	type RespElem struct {
-		ID         int32    `json:"id"`
-		BookID     int32    `json:"book_id"`
-		LoanDate   DateOnly `json:"loan_date"`
-		DueDate    DateOnly `json:"due_date"`
-		ReturnDate DateOnly `json:"return_date,omitempty"`
+		ID         int32     `json:"id"`
+		BookID     int32     `json:"book_id"`
+		LoanDate   DateOnly  `json:"loan_date"`
+		DueDate    DateOnly  `json:"due_date"`
+		ReturnDate *DateOnly `json:"return_date,omitempty"`
 	}
 	resp := make([]RespElem, len(loans))
 	for i, loan := range loans {
@@ -82,7 +84,9 @@ func (rh ReportHandler) GetUserActivity(c echo.Context) error {
 		resp[i].BookID = loan.BookID
 		resp[i].LoanDate = DateOnly{loan.LoanDate}
 		resp[i].DueDate = DateOnly{loan.DueDate}
-		resp[i].ReturnDate = DateOnly{loan.ReturnDate.Time}
+		if loan.ReturnDate.Valid {
+			resp[i].ReturnDate = &DateOnly{loan.ReturnDate.Time}
+		}
 	}
 	return c.JSON(http.StatusOK, resp)
 }
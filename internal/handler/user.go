@@ -14,14 +14,31 @@ type UserHandler struct {
 
 func (uh UserHandler) Delete(c echo.Context) error {
 	type Req struct {
-		ID int32 `param:"id"`
+		ID    int32 `param:"id"`
+		Force bool  `query:"force"`
 	}
 	var req Req
 	if err := c.Bind(&req); err != nil {
 		return err
 	}
 
-	if err := uh.UserSVC.DeleteByID(c.Request().Context(), req.ID); err != nil {
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "user has been modified",
+		})
+	}
+
+	deleteByID := uh.UserSVC.DeleteByID
+	if req.Force {
+		if ok, err := authorize(c, "admin"); !ok {
+			return err
+		}
+		deleteByID = uh.UserSVC.ForceDeleteByID
+	}
+
+	if err := deleteByID(c.Request().Context(), req.ID, versions...); err != nil {
 		var validationErr service.ValidationError
 		if errors.As(err, &validationErr) {
 			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
@@ -29,6 +46,27 @@ func (uh UserHandler) Delete(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		var inUseErr service.InUseError
+		if errors.As(err, &inUseErr) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":         "logic",
+				"message":      "user has open loans or active reservations",
+				"loans":        inUseErr.LoanIDs,
+				"reservations": inUseErr.ReservationIDs,
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "user has been modified",
+			})
+		}
 
 		return err
 	}
@@ -50,10 +88,25 @@ func (uh UserHandler) Update(c echo.Context) error {
 		return err
 	}
 
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "user has been modified",
+		})
+	}
+
+	if req.Role != "" {
+		if ok, err := authorize(c, "admin"); !ok {
+			return err
+		}
+	}
+
 	user, err := uh.UserSVC.UpdateByID(c.Request().Context(), req.ID, service.UserUpdateByIDParams{
-		Name:  req.Name,
-		Email: req.Email,
-		Role:  req.Role,
+		Name:     req.Name,
+		Email:    req.Email,
+		Role:     req.Role,
+		Versions: versions,
 	})
 	if err != nil {
 		var validationErr service.ValidationError
@@ -63,17 +116,115 @@ func (uh UserHandler) Update(c echo.Context) error {
 				"message": validationErr.Error(),
 			})
 		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "user has been modified",
+			})
+		}
+		if errors.Is(err, service.ErrUserDup) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "user already exists",
+			})
+		}
 
 		return err
 	}
 
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+
 	type Resp struct {
 		ID    int32  `json:"id"`
 		Name  string `json:"name"`
 		Email string `json:"email"`
 		Role  string `json:"role"`
 	}
-	return c.JSON(http.StatusCreated, Resp{
+	return c.JSON(http.StatusOK, Resp{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
+
+func (uh UserHandler) Patch(c echo.Context) error {
+	type Req struct {
+		ID    int32                    `param:"id"`
+		Name  service.Optional[string] `json:"name"`
+		Email service.Optional[string] `json:"email"`
+		Role  service.Optional[string] `json:"role"`
+	}
+	var req Req
+	if err := bindMergePatch(c, &req); err != nil {
+		return err
+	}
+
+	versions, ok := ifMatch(c)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, map[string]any{
+			"type":    "logic",
+			"message": "user has been modified",
+		})
+	}
+
+	if req.Role.Set {
+		if ok, err := authorize(c, "admin"); !ok {
+			return err
+		}
+	}
+
+	user, err := uh.UserSVC.PatchByID(c.Request().Context(), req.ID, service.UserPatchByIDParams{
+		Name:     req.Name,
+		Email:    req.Email,
+		Role:     req.Role,
+		Versions: versions,
+	})
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrVersionMismatch) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]any{
+				"type":    "logic",
+				"message": "user has been modified",
+			})
+		}
+		if errors.Is(err, service.ErrUserDup) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "user already exists",
+			})
+		}
+
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+
+	type Resp struct {
+		ID    int32  `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	return c.JSON(http.StatusOK, Resp{
 		ID:    user.ID,
 		Name:  user.Name,
 		Email: user.Email,
@@ -109,13 +260,18 @@ func (uh UserHandler) Get(c echo.Context) error {
 		return err
 	}
 
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+	if notModified(c, user.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
 	type Resp struct {
 		ID    int32  `json:"id"`
 		Name  string `json:"name"`
 		Email string `json:"email"`
 		Role  string `json:"role"`
 	}
-	return c.JSON(http.StatusCreated, Resp{
+	return c.JSON(http.StatusOK, Resp{
 		ID:    user.ID,
 		Name:  user.Name,
 		Email: user.Email,
@@ -135,6 +291,12 @@ func (uh UserHandler) Create(c echo.Context) error {
 		return err
 	}
 
+	if req.Role != "" {
+		if ok, err := authorize(c, "admin"); !ok {
+			return err
+		}
+	}
+
 	user, err := uh.UserSVC.Create(c.Request().Context(), service.UserCreateParams{
 		Name:     req.Name,
 		Email:    req.Email,
@@ -159,6 +321,8 @@ func (uh UserHandler) Create(c echo.Context) error {
 		return err
 	}
 
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+
 	type Resp struct {
 		ID    int32  `json:"id"`
 		Name  string `json:"name"`
@@ -172,3 +336,190 @@ func (uh UserHandler) Create(c echo.Context) error {
 		Role:  user.Role,
 	})
 }
+
+func (uh UserHandler) ChangePassword(c echo.Context) error {
+	type Req struct {
+		ID              int32  `param:"id"`
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := uh.UserSVC.ChangePassword(c.Request().Context(), req.ID, service.UserChangePasswordParams{
+		Current: []byte(req.CurrentPassword),
+		New:     []byte(req.NewPassword),
+	}); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "Password changed successfully",
+	})
+}
+
+func (uh UserHandler) RequestPasswordReset(c echo.Context) error {
+	type Req struct {
+		Email string `json:"email"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := uh.UserSVC.RequestPasswordReset(c.Request().Context(), req.Email); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"message": "A reset token has been mailed if a user has this email",
+	})
+}
+
+func (uh UserHandler) ResetPassword(c echo.Context) error {
+	type Req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := uh.UserSVC.ResetPassword(c.Request().Context(), service.UserResetPasswordParams{
+		Token:    req.Token,
+		Password: []byte(req.Password),
+	}); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "Password reset successfully",
+	})
+}
+
+func (uh UserHandler) Restore(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	user, err := uh.UserSVC.RestoreByID(c.Request().Context(), req.ID)
+	if err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "user is not deleted",
+			})
+		}
+		if errors.Is(err, service.ErrUserDup) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "user already exists",
+			})
+		}
+
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, etag(user.Version))
+
+	type Resp struct {
+		ID    int32  `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	return c.JSON(http.StatusOK, Resp{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
+
+func (uh UserHandler) Purge(c echo.Context) error {
+	type Req struct {
+		ID int32 `param:"id"`
+	}
+	var req Req
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if err := uh.UserSVC.PurgeByID(c.Request().Context(), req.ID); err != nil {
+		var validationErr service.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+				"type":    "validation",
+				"message": validationErr.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"type":    "resource",
+				"message": "user not found",
+			})
+		}
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.JSON(http.StatusConflict, map[string]any{
+				"type":    "logic",
+				"message": "user is not deleted",
+			})
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "User purged successfully",
+	})
+}
@@ -0,0 +1,195 @@
+// Package idempotency lets clients safely retry unsafe requests by sending an
+// Idempotency-Key header: the first response for a key is stored in Redis and
+// replayed for retries instead of running the handler again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/auth"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// maxBodySize is the largest body kept to tell retries apart from other
+	// requests.
+	maxBodySize = 1 << 20
+	// pendingTTL bounds how long a key stays locked when the server dies
+	// before storing the response.
+	pendingTTL = time.Minute
+)
+
+type Config struct {
+	RDB *redis.Client
+	// TTL is how long responses are kept for replay. Zero means 24 hours.
+	TTL time.Duration
+}
+
+// record is what is stored under a key. Status is zero while the original
+// request is still being handled.
+type record struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Middleware makes the routes it's applied to idempotent for requests that
+// carry an Idempotency-Key header. Keys are kept apart for each client, as
+// auth.Client names them. Retries must have the same body as the original
+// request, and responses with a 5xx status aren't stored so that they can be
+// retried for real.
+func Middleware(config Config) echo.MiddlewareFunc {
+	if config.TTL == 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			idemKey := c.Request().Header.Get(HeaderIdempotencyKey)
+			if idemKey == "" {
+				return next(c)
+			}
+			if len(idemKey) > maxKeyLength {
+				return c.JSON(http.StatusBadRequest, map[string]any{
+					"type":    "validation",
+					"message": "idempotency key is too long",
+				})
+			}
+
+			ctx := c.Request().Context()
+			req := c.Request()
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxBodySize))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					return c.JSON(http.StatusRequestEntityTooLarge, map[string]any{
+						"type":    "validation",
+						"message": "request body is too large",
+					})
+				}
+
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := "idempotency:" + req.Method + " " + c.Path() + ":" + auth.Client(c) + ":" + idemKey
+			fingerprint := fingerprint(req, body)
+
+			pending, err := json.Marshal(record{Fingerprint: fingerprint})
+			if err != nil {
+				return err
+			}
+			acquired, err := config.RDB.SetNX(ctx, key, pending, pendingTTL).Result()
+			if err != nil {
+				return err
+			}
+			if !acquired {
+				return replay(c, config.RDB, key, fingerprint)
+			}
+
+			rec := &recorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = rec
+
+			// Errors are responded to here, so that their responses are stored
+			// like any other, and aren't handed on to be handled again.
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			// The response is already on its way, so storing it must not be
+			// cut short by the client going away.
+			ctx = context.WithoutCancel(ctx)
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				if delErr := config.RDB.Del(ctx, key).Err(); delErr != nil {
+					slog.ErrorContext(ctx, "Failed to release idempotency key", "error", delErr)
+				}
+
+				return nil
+			}
+
+			done, marshalErr := json.Marshal(record{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        rec.body.Bytes(),
+			})
+			if marshalErr == nil {
+				marshalErr = config.RDB.Set(ctx, key, done, config.TTL).Err()
+			}
+			if marshalErr != nil {
+				slog.ErrorContext(ctx, "Failed to store idempotent response", "error", marshalErr)
+			}
+
+			return nil
+		}
+	}
+}
+
+func replay(c echo.Context, rdb *redis.Client, key, fingerprint string) error {
+	data, err := rdb.Get(c.Request().Context(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The original request failed and released the key in the meantime.
+		return c.JSON(http.StatusConflict, map[string]any{
+			"type":    "logic",
+			"message": "request with this idempotency key is in progress",
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored record
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"type":    "validation",
+			"message": "idempotency key was used for a different request",
+		})
+	}
+	if stored.Status == 0 {
+		return c.JSON(http.StatusConflict, map[string]any{
+			"type":    "logic",
+			"message": "request with this idempotency key is in progress",
+		})
+	}
+
+	c.Response().Header().Set(HeaderReplayed, "true")
+	return c.Blob(stored.Status, stored.ContentType, stored.Body)
+}
+
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder keeps a copy of the response body as it's written.
+type recorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,186 @@
+package idempotency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/idempotency"
+)
+
+type server struct {
+	e     *echo.Echo
+	calls int
+	// status is what the handler responds with.
+	status int
+	// errs are the errors the middleware returned.
+	errs []error
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	s := &server{e: echo.New(), status: http.StatusCreated}
+	s.e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			if err != nil {
+				s.errs = append(s.errs, err)
+			}
+			return err
+		}
+	})
+	s.e.POST("/loans", func(c echo.Context) error {
+		s.calls++
+		if s.status >= http.StatusInternalServerError {
+			return echo.NewHTTPError(s.status)
+		}
+
+		return c.JSON(s.status, map[string]any{"id": s.calls})
+	}, idempotency.Middleware(idempotency.Config{RDB: rdb}))
+
+	return s
+}
+
+func (s *server) post(key, body string) *httptest.ResponseRecorder {
+	return s.postAs(nil, key, body)
+}
+
+// postAs posts on behalf of id, or anonymously when it's nil.
+func (s *server) postAs(id *auth.Identity, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(idempotency.HeaderIdempotencyKey, key)
+	}
+	if id != nil {
+		req = req.WithContext(auth.WithIdentity(req.Context(), *id))
+	}
+
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReplaysResponse(t *testing.T) {
+	s := newServer(t)
+
+	first := s.post("key", `{"book_id":1}`)
+	second := s.post("key", `{"book_id":1}`)
+
+	if s.calls != 1 {
+		t.Fatalf("handler called %d times; want 1", s.calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s; want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if got := second.Header().Get(idempotency.HeaderReplayed); got != "true" {
+		t.Errorf("%s = %q; want %q", idempotency.HeaderReplayed, got, "true")
+	}
+	if got := first.Header().Get(idempotency.HeaderReplayed); got != "" {
+		t.Errorf("original response has %s = %q", idempotency.HeaderReplayed, got)
+	}
+}
+
+func TestRejectsReuseWithDifferentBody(t *testing.T) {
+	s := newServer(t)
+
+	s.post("key", `{"book_id":1}`)
+	rec := s.post("key", `{"book_id":2}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if s.calls != 1 {
+		t.Fatalf("handler called %d times; want 1", s.calls)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	s := newServer(t)
+
+	s.status = http.StatusServiceUnavailable
+	if rec := s.post("key", `{"book_id":1}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	s.status = http.StatusCreated
+	rec := s.post("key", `{"book_id":1}`)
+	if rec.Code != http.StatusCreated || s.calls != 2 {
+		t.Fatalf("status = %d after %d calls; want %d after 2", rec.Code, s.calls, http.StatusCreated)
+	}
+	if len(s.errs) != 0 {
+		t.Errorf("errors handed on after being handled: %v", s.errs)
+	}
+}
+
+func TestStoresClientErrors(t *testing.T) {
+	s := newServer(t)
+
+	s.status = http.StatusConflict
+	s.post("key", `{"book_id":1}`)
+	s.status = http.StatusCreated
+	rec := s.post("key", `{"book_id":1}`)
+
+	if rec.Code != http.StatusConflict || s.calls != 1 {
+		t.Fatalf("status = %d after %d calls; want %d after 1", rec.Code, s.calls, http.StatusConflict)
+	}
+}
+
+func TestWithoutKey(t *testing.T) {
+	s := newServer(t)
+
+	for i := range 2 {
+		rec := s.post("", `{"book_id":1}`)
+		if want := `{"id":` + strconv.Itoa(i+1) + `}`; strings.TrimSpace(rec.Body.String()) != want {
+			t.Fatalf("body = %s; want %s", rec.Body, want)
+		}
+	}
+}
+
+func TestKeyTooLong(t *testing.T) {
+	s := newServer(t)
+
+	rec := s.post(strings.Repeat("k", 256), `{"book_id":1}`)
+	if rec.Code != http.StatusBadRequest || s.calls != 0 {
+		t.Fatalf("status = %d after %d calls; want %d after 0", rec.Code, s.calls, http.StatusBadRequest)
+	}
+}
+
+func TestKeysAreScopedToCaller(t *testing.T) {
+	s := newServer(t)
+
+	callers := []*auth.Identity{
+		nil,
+		{UserID: 1, Role: "member"},
+		{UserID: 2, Role: "member"},
+		{UserID: 1, Role: "member", APIKeyID: 1},
+	}
+	for i, id := range callers {
+		rec := s.postAs(id, "key", `{"book_id":1}`)
+		if got := rec.Header().Get(idempotency.HeaderReplayed); got != "" {
+			t.Fatalf("caller %d got a replay of another's response", i)
+		}
+	}
+	if s.calls != len(callers) {
+		t.Fatalf("handler called %d times; want %d", s.calls, len(callers))
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	s := newServer(t)
+
+	rec := s.post("key", `{"title":"`+strings.Repeat("x", 1<<20)+`"}`)
+	if rec.Code != http.StatusRequestEntityTooLarge || s.calls != 0 {
+		t.Fatalf("status = %d after %d calls; want %d after 0", rec.Code, s.calls, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// QueryHook logs every executed SQL statement at debug level and failed ones
+// at error level.
+type QueryHook struct {
+	Logger *slog.Logger
+}
+
+var _ bun.QueryHook = QueryHook{}
+
+func (QueryHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (qh QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	attrs := []slog.Attr{
+		slog.String("operation", event.Operation()),
+		slog.String("query", event.Query),
+		slog.Duration("duration", time.Since(event.StartTime)),
+	}
+
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		attrs = append(attrs, slog.Any("error", event.Err))
+		qh.Logger.LogAttrs(ctx, slog.LevelError, "Query failed", attrs...)
+		return
+	}
+
+	qh.Logger.LogAttrs(ctx, slog.LevelDebug, "Query executed", attrs...)
+}
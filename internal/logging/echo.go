@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDMiddleware reuses the X-Request-ID header sent by the client or
+// generates a new one, and stores it in the request context so that services
+// and query hooks can log it.
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			req := c.Request()
+			trace.SpanFromContext(req.Context()).SetAttributes(attribute.String("lms.request_id", id))
+			c.SetRequest(req.WithContext(WithRequestID(req.Context(), id)))
+		},
+	})
+}
+
+func RequestLoggerMiddleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogLatency:   true,
+		LogRemoteIP:  true,
+		LogMethod:    true,
+		LogURIPath:   true,
+		LogRoutePath: true,
+		LogStatus:    true,
+		LogError:     true,
+		HandleError:  true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			attrs := []slog.Attr{
+				slog.String("method", v.Method),
+				slog.String("path", v.URIPath),
+				slog.String("route", v.RoutePath),
+				slog.Int("status", v.Status),
+				slog.Duration("latency", v.Latency),
+				slog.String("remote_ip", v.RemoteIP),
+			}
+
+			level := slog.LevelInfo
+			if v.Error != nil {
+				attrs = append(attrs, slog.Any("error", v.Error))
+				if v.Status >= 500 {
+					level = slog.LevelError
+				}
+			}
+
+			logger.LogAttrs(c.Request().Context(), level, "Request handled", attrs...)
+			return nil
+		},
+	})
+}
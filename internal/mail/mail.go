@@ -0,0 +1,65 @@
+// Package mail sends emails to users. The implementations here are meant for
+// local development: they log messages or write them to files instead of
+// delivering them.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer logs every message it's given.
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+func (lm LogMailer) Send(ctx context.Context, msg Message) error {
+	lm.Logger.InfoContext(ctx, "Sending mail",
+		"to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer writes every message it's given to a .eml file in Dir, which
+// most mail clients can open.
+type FileMailer struct {
+	Dir string
+}
+
+func (fm FileMailer) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(fm.Dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(fm.Dir, time.Now().UTC().Format("20060102T150405")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
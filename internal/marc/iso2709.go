@@ -0,0 +1,246 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf8"
+)
+
+const (
+	leaderLen        = 24
+	directoryLen     = 12
+	subfieldDelim    = 0x1F
+	fieldTerminator  = 0x1E
+	recordTerminator = 0x1D
+)
+
+// Reader reads records in ISO 2709.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are none left. Records
+// in MARC-8 are rejected unless they're plain ASCII.
+func (rr *Reader) Read() (*Record, error) {
+	// Some systems put line breaks between records.
+	for {
+		b, err := rr.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' && b[0] != '\r' {
+			break
+		}
+		if _, err := rr.r.Discard(1); err != nil {
+			return nil, err
+		}
+	}
+
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(rr.r, head); err != nil {
+		return nil, truncated(err)
+	}
+	n, ok := parseNumber(head)
+	if !ok || n <= leaderLen {
+		return nil, fmt.Errorf("%w: invalid record length %q", ErrMalformed, head)
+	}
+
+	data := make([]byte, n)
+	copy(data, head)
+	if _, err := io.ReadFull(rr.r, data[len(head):]); err != nil {
+		return nil, truncated(err)
+	}
+
+	return Unmarshal(data)
+}
+
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated record", ErrMalformed)
+	}
+
+	return err
+}
+
+// Unmarshal parses a single record in ISO 2709.
+func Unmarshal(data []byte) (*Record, error) {
+	if len(data) <= leaderLen || data[len(data)-1] != recordTerminator {
+		return nil, fmt.Errorf("%w: missing record terminator", ErrMalformed)
+	}
+
+	leader := data[:leaderLen]
+	if leader[9] != 'a' {
+		if slices.ContainsFunc(data, func(b byte) bool { return b >= utf8.RuneSelf }) {
+			return nil, ErrMARC8
+		}
+	} else if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%w: invalid UTF-8", ErrMalformed)
+	}
+
+	base, ok := parseNumber(leader[12:17])
+	if !ok || base <= leaderLen || base > len(data) || data[base-1] != fieldTerminator {
+		return nil, fmt.Errorf("%w: invalid base address", ErrMalformed)
+	}
+	directory := data[leaderLen : base-1]
+	if len(directory)%directoryLen != 0 {
+		return nil, fmt.Errorf("%w: invalid directory", ErrMalformed)
+	}
+
+	rec := Record{Leader: string(leader)}
+	for entry := range slices.Chunk(directory, directoryLen) {
+		tag := string(entry[:3])
+		length, ok := parseNumber(entry[3:7])
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid length of field %s", ErrMalformed, tag)
+		}
+		start, ok := parseNumber(entry[7:12])
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid start of field %s", ErrMalformed, tag)
+		}
+		end := base + start + length
+		if length < 1 || end > len(data)-1 || data[end-1] != fieldTerminator {
+			return nil, fmt.Errorf("%w: field %s out of bounds", ErrMalformed, tag)
+		}
+		field := data[base+start : end-1]
+
+		if isControlTag(tag) {
+			rec.ControlFields = append(rec.ControlFields, ControlField{Tag: tag, Value: string(field)})
+			continue
+		}
+
+		if len(field) < 2 {
+			return nil, fmt.Errorf("%w: missing indicators of field %s", ErrMalformed, tag)
+		}
+		df := DataField{Tag: tag, Ind1: field[0], Ind2: field[1]}
+		// Anything before the first delimiter isn't part of a subfield.
+		for i, subfield := range bytes.Split(field[2:], []byte{subfieldDelim}) {
+			if i == 0 || len(subfield) == 0 {
+				continue
+			}
+			df.Subfields = append(df.Subfields, Subfield{Code: subfield[0], Value: string(subfield[1:])})
+		}
+		rec.DataFields = append(rec.DataFields, df)
+	}
+
+	return &rec, nil
+}
+
+// parseNumber parses the digits of a number in the leader or directory.
+// Unlike strconv.Atoi, it rejects signs, so the numbers are never negative.
+func parseNumber(digits []byte) (int, bool) {
+	if len(digits) == 0 {
+		return 0, false
+	}
+
+	n := 0
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return 0, false
+		}
+		n = n*10 + int(d-'0')
+	}
+
+	return n, true
+}
+
+// Writer writes records in ISO 2709.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (rw *Writer) Write(rec *Record) error {
+	data, err := Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = rw.w.Write(data)
+	return err
+}
+
+// Close does nothing, as records are written as they come. It's there so
+// that Writer can be used in place of XMLWriter.
+func (rw *Writer) Close() error {
+	return nil
+}
+
+// Marshal encodes rec in ISO 2709, in Unicode. The lengths and addresses of
+// its leader are filled in, and DefaultLeader is used when it has none.
+func Marshal(rec *Record) ([]byte, error) {
+	leader := []byte(DefaultLeader)
+	if len(rec.Leader) == leaderLen {
+		leader = []byte(rec.Leader)
+	}
+
+	var directory, fields bytes.Buffer
+	addField := func(tag string, field []byte) error {
+		if len(tag) != 3 {
+			return fmt.Errorf("%w: invalid tag %q", ErrMalformed, tag)
+		}
+		if len(field) > 9999 {
+			return fmt.Errorf("%w: field %s is too long", ErrMalformed, tag)
+		}
+
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(field), fields.Len())
+		fields.Write(field)
+		return nil
+	}
+
+	for _, cf := range rec.ControlFields {
+		if err := addField(cf.Tag, append([]byte(cf.Value), fieldTerminator)); err != nil {
+			return nil, err
+		}
+	}
+	for _, df := range rec.DataFields {
+		field := []byte{indicator(df.Ind1), indicator(df.Ind2)}
+		for _, subfield := range df.Subfields {
+			field = append(field, subfieldDelim, subfield.Code)
+			field = append(field, subfield.Value...)
+		}
+		field = append(field, fieldTerminator)
+
+		if err := addField(df.Tag, field); err != nil {
+			return nil, err
+		}
+	}
+	directory.WriteByte(fieldTerminator)
+
+	base := leaderLen + directory.Len()
+	length := base + fields.Len() + 1
+	if length > 99999 {
+		return nil, fmt.Errorf("%w: record is too long", ErrMalformed)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], "4500")
+
+	data := make([]byte, 0, length)
+	data = append(data, leader...)
+	data = append(data, directory.Bytes()...)
+	data = append(data, fields.Bytes()...)
+	data = append(data, recordTerminator)
+	return data, nil
+}
+
+// indicator writes unset indicators as blanks.
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+
+	return b
+}
@@ -0,0 +1,90 @@
+// Package marc reads and writes bibliographic records in MARC 21, both in
+// its ISO 2709 exchange format and as MARCXML.
+package marc
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// MIMEType is the media type of ISO 2709 records (RFC 2220).
+	MIMEType = "application/marc"
+	// XMLMIMEType is the media type of MARCXML records (RFC 6207).
+	XMLMIMEType = "application/marcxml+xml"
+)
+
+var (
+	ErrMalformed = errors.New("malformed record")
+	ErrMARC8     = errors.New("MARC-8 encoded records aren't supported")
+)
+
+// DefaultLeader is the leader of a new record of a book: a language material
+// monograph encoded in Unicode, with its lengths and addresses zeroed.
+const DefaultLeader = "00000nam a2200000   4500"
+
+type Record struct {
+	Leader        string
+	ControlFields []ControlField
+	DataFields    []DataField
+}
+
+// ControlField holds the value of one of the 00X fields, such as the control
+// number in 001.
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+type DataField struct {
+	Tag       string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// ControlField returns the value of the first control field with tag.
+func (r Record) ControlField(tag string) string {
+	for _, field := range r.ControlFields {
+		if field.Tag == tag {
+			return field.Value
+		}
+	}
+
+	return ""
+}
+
+// Subfield returns the first non-empty value of the subfields with code of
+// the data fields with tag.
+func (r Record) Subfield(tag string, code byte) string {
+	for _, field := range r.DataFields {
+		if field.Tag != tag {
+			continue
+		}
+		if value := field.Subfield(code); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Subfield returns the value of the first subfield with code.
+func (f DataField) Subfield(code byte) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+
+	return ""
+}
+
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
@@ -0,0 +1,168 @@
+package marc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/utilyre/lms/internal/marc"
+)
+
+// dune is a record as another system would export it.
+const dune = "00061nam a2200049   4500" +
+	"001000200000" + "245000900002" + "\x1e" +
+	"1\x1e" + "10\x1faDune\x1e" +
+	"\x1d"
+
+var gopl = marc.Record{
+	Leader: marc.DefaultLeader,
+	ControlFields: []marc.ControlField{
+		{Tag: "001", Value: "7"},
+	},
+	DataFields: []marc.DataField{
+		{Tag: "020", Ind1: ' ', Ind2: ' ', Subfields: []marc.Subfield{
+			{Code: 'a', Value: "9780134190440"},
+		}},
+		{Tag: "100", Ind1: '1', Ind2: ' ', Subfields: []marc.Subfield{
+			{Code: 'a', Value: "Donovan, Alan A. A."},
+		}},
+		{Tag: "245", Ind1: '1', Ind2: '4', Subfields: []marc.Subfield{
+			{Code: 'a', Value: "The Go programming language /"},
+			{Code: 'c', Value: "Alan A. A. Donovan, Brian W. Kernighan — 1st ed."},
+		}},
+	},
+}
+
+func TestReader(t *testing.T) {
+	r := marc.NewReader(strings.NewReader(dune + "\n" + dune))
+	for range 2 {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ControlField("001") != "1" || rec.Subfield("245", 'a') != "Dune" {
+			t.Errorf("record = %+v; want dune", rec)
+		}
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v; want %v", err, io.EOF)
+	}
+}
+
+func TestReaderMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated":     dune[:40],
+		"bad length":    "0006x" + dune[5:],
+		"no terminator": dune[:60] + "\x1e",
+		"bad directory": dune[:27] + "9" + dune[28:],
+		"signed start":  dune[:31] + "-9999" + dune[36:],
+		"signed length": dune[:27] + "+002" + dune[31:],
+		"signed base":   dune[:12] + "+0049" + dune[17:],
+		"marc-8":        strings.Replace(dune[:9]+" "+dune[10:], "Dune", "D\xe8ne", 1),
+		"invalid utf-8": strings.Replace(dune, "Dune", "D\xe8ne", 1),
+	} {
+		if _, err := marc.NewReader(strings.NewReader(data)).Read(); err == nil {
+			t.Errorf("%s: err = nil; want one", name)
+		}
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	data, err := marc.Marshal(&gopl)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add([]byte(dune))
+	f.Add([]byte(dune[:31] + "-9999" + dune[36:]))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rec, err := marc.Unmarshal(data)
+		if err != nil {
+			return
+		}
+
+		data, err = marc.Marshal(rec)
+		if err != nil {
+			return
+		}
+		if _, err := marc.Unmarshal(data); err != nil {
+			t.Errorf("unmarshal of marshaled record: %v", err)
+		}
+	})
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := marc.NewWriter(&buf)
+	if err := w.Write(&gopl); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := marc.NewReader(&buf).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gopl
+	want.Leader = rec.Leader
+	if !reflect.DeepEqual(*rec, want) {
+		t.Errorf("record = %+v; want %+v", *rec, want)
+	}
+	if !strings.HasPrefix(rec.Leader, "00") || rec.Leader[9] != 'a' || !strings.HasSuffix(rec.Leader, "4500") {
+		t.Errorf("leader = %q; want its lengths filled in", rec.Leader)
+	}
+}
+
+func TestXMLWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := marc.NewXMLWriter(&buf)
+	for range 2 {
+		if err := w.Write(&gopl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<collection xmlns="`+marc.Namespace+`">`) {
+		t.Errorf("xml = %s; want a MARCXML collection", buf.String())
+	}
+
+	r := marc.NewXMLReader(&buf)
+	for range 2 {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*rec, gopl) {
+			t.Errorf("record = %+v; want %+v", *rec, gopl)
+		}
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v; want %v", err, io.EOF)
+	}
+}
+
+func TestXMLReaderStandalone(t *testing.T) {
+	const record = `<?xml version="1.0"?>
+<marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:leader>00000nam a2200000   4500</marc:leader>
+  <marc:datafield tag="245" ind1="1" ind2="0">
+    <marc:subfield code="a">Dune</marc:subfield>
+  </marc:datafield>
+</marc:record>`
+
+	rec, err := marc.NewXMLReader(strings.NewReader(record)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Subfield("245", 'a'); got != "Dune" {
+		t.Errorf("title = %q; want Dune", got)
+	}
+}
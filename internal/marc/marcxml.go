@@ -0,0 +1,172 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Namespace is the XML namespace of MARCXML.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func newXMLRecord(rec *Record) xmlRecord {
+	xr := xmlRecord{Leader: rec.Leader}
+	if len(xr.Leader) != leaderLen {
+		xr.Leader = DefaultLeader
+	}
+	for _, cf := range rec.ControlFields {
+		xr.ControlFields = append(xr.ControlFields, xmlControlField(cf))
+	}
+	for _, df := range rec.DataFields {
+		xdf := xmlDataField{
+			Tag:  df.Tag,
+			Ind1: string(indicator(df.Ind1)),
+			Ind2: string(indicator(df.Ind2)),
+		}
+		for _, subfield := range df.Subfields {
+			xdf.Subfields = append(xdf.Subfields, xmlSubfield{
+				Code:  string(subfield.Code),
+				Value: subfield.Value,
+			})
+		}
+		xr.DataFields = append(xr.DataFields, xdf)
+	}
+
+	return xr
+}
+
+func (xr xmlRecord) record() (*Record, error) {
+	rec := Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		rec.ControlFields = append(rec.ControlFields, ControlField(cf))
+	}
+	for _, xdf := range xr.DataFields {
+		df := DataField{Tag: xdf.Tag, Ind1: ' ', Ind2: ' '}
+		if len(xdf.Ind1) == 1 {
+			df.Ind1 = xdf.Ind1[0]
+		}
+		if len(xdf.Ind2) == 1 {
+			df.Ind2 = xdf.Ind2[0]
+		}
+		for _, subfield := range xdf.Subfields {
+			if len(subfield.Code) != 1 {
+				return nil, fmt.Errorf("%w: invalid subfield code %q in field %s", ErrMalformed, subfield.Code, xdf.Tag)
+			}
+			df.Subfields = append(df.Subfields, Subfield{Code: subfield.Code[0], Value: subfield.Value})
+		}
+		rec.DataFields = append(rec.DataFields, df)
+	}
+
+	return &rec, nil
+}
+
+// XMLReader reads records in MARCXML, whether they're in a collection or
+// stand alone.
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Read returns the next record, or io.EOF when there are none left.
+func (xr *XMLReader) Read() (*Record, error) {
+	for {
+		tok, err := xr.d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var rec xmlRecord
+		if err := xr.d.DecodeElement(&rec, &start); err != nil {
+			return nil, err
+		}
+
+		return rec.record()
+	}
+}
+
+// XMLWriter writes records in MARCXML, as a collection.
+type XMLWriter struct {
+	w       io.Writer
+	e       *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &XMLWriter{w: w, e: e}
+}
+
+var collection = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+}
+
+func (xw *XMLWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+
+	if _, err := io.WriteString(xw.w, xml.Header); err != nil {
+		return err
+	}
+
+	return xw.e.EncodeToken(collection)
+}
+
+func (xw *XMLWriter) Write(rec *Record) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+
+	return xw.e.Encode(newXMLRecord(rec))
+}
+
+// Close ends the collection, which is empty if no record was written.
+func (xw *XMLWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if err := xw.e.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	if err := xw.e.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(xw.w, "\n")
+	return err
+}
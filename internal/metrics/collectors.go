@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+)
+
+func NewDBStatsCollector(db *bun.DB) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db.DB, "lms")
+}
+
+type redisPoolCollector struct {
+	rdb *redis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func NewRedisPoolCollector(rdb *redis.Client) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", name), help, nil, nil)
+	}
+
+	return redisPoolCollector{
+		rdb:        rdb,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait timeout occurred."),
+		totalConns: desc("connections", "Number of total connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+func (rc redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rc.hits
+	ch <- rc.misses
+	ch <- rc.timeouts
+	ch <- rc.totalConns
+	ch <- rc.idleConns
+	ch <- rc.staleConns
+}
+
+func (rc redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := rc.rdb.PoolStats()
+	ch <- prometheus.MustNewConstMetric(rc.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(rc.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(rc.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(rc.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(rc.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(rc.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+// circulationCollector queries the database on every scrape, so its numbers
+// are always current but a slow database makes scrapes slow too.
+type circulationCollector struct {
+	db bun.IDB
+
+	openLoans              *prometheus.Desc
+	overdueLoans           *prometheus.Desc
+	reservationQueueLength *prometheus.Desc
+}
+
+func NewCirculationCollector(db bun.IDB) prometheus.Collector {
+	return circulationCollector{
+		db: db,
+		openLoans: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "circulation", "open_loans"),
+			"Number of loans that have not been returned yet.",
+			nil, nil,
+		),
+		overdueLoans: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "circulation", "overdue_loans"),
+			"Number of unreturned loans past their due date.",
+			nil, nil,
+		),
+		reservationQueueLength: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "circulation", "reservation_queue_length"),
+			"Number of pending reservations per book, for books that have any.",
+			[]string{"book_id"}, nil,
+		),
+	}
+}
+
+func (cc circulationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.openLoans
+	ch <- cc.overdueLoans
+	ch <- cc.reservationQueueLength
+}
+
+func (cc circulationCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	openLoans, err := cc.db.
+		NewSelect().
+		Table("loans").
+		Where("return_date IS NULL").
+		Count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(cc.openLoans, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(cc.openLoans, prometheus.GaugeValue, float64(openLoans))
+	}
+
+	overdueLoans, err := cc.db.
+		NewSelect().
+		Table("loans").
+		Where("return_date IS NULL").
+		Where("NOW() > due_date").
+		Count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(cc.overdueLoans, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(cc.overdueLoans, prometheus.GaugeValue, float64(overdueLoans))
+	}
+
+	var queues []struct {
+		BookID int32
+		Length int
+	}
+	if err := cc.db.
+		NewSelect().
+		Table("reservations").
+		ColumnExpr("book_id, COUNT(*) length").
+		Where("canceled_at IS NULL").
+		Group("book_id").
+		Scan(ctx, &queues); err != nil {
+		ch <- prometheus.NewInvalidMetric(cc.reservationQueueLength, err)
+		return
+	}
+	for _, queue := range queues {
+		ch <- prometheus.MustNewConstMetric(
+			cc.reservationQueueLength,
+			prometheus.GaugeValue,
+			float64(queue.Length),
+			strconv.Itoa(int(queue.BookID)),
+		)
+	}
+}
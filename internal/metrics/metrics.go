@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "lms"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of SQL queries by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of cache lookups by key and result.",
+	}, []string{"key", "result"})
+
+	rateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Number of requests rejected by the rate limiter by route.",
+	}, []string{"route"})
+)
+
+func CacheHit(key string) {
+	cacheRequests.WithLabelValues(key, "hit").Inc()
+}
+
+func CacheMiss(key string) {
+	cacheRequests.WithLabelValues(key, "miss").Inc()
+}
+
+func RateLimited(route string) {
+	rateLimitedRequests.WithLabelValues(route).Inc()
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -10,21 +11,130 @@ import (
 type User struct {
 	bun.BaseModel
 
-	ID       int32 `bun:",pk,autoincrement"`
-	Name     string
-	Email    string `bun:",unique"`
-	Password []byte
+	ID int32 `bun:",pk,autoincrement"`
+	// Name and Email are left out of the audit log so that anonymizing the
+	// user leaves no trace of them.
+	Name     string `audit:"-"`
+	Email    string `bun:",unique" audit:"-"`
+	Password []byte `audit:"-"`
 	Role     string
+	Version  int32
+	// DeletedAt is when the user was deleted. Deleted users are left out of
+	// every query unless asked for, so that their loans stay intact.
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
 }
 
 type Book struct {
 	bun.BaseModel
 
-	ID                 int32 `bun:",pk,autoincrement"`
-	Title              string
-	Author             string
-	ISBN               string
+	ID              int32 `bun:",pk,autoincrement"`
+	Title           string
+	ISBN            string
+	PublicationYear int32  `bun:",nullzero"`
+	Edition         string `bun:",nullzero"`
+	// Language is the ISO 639 code of the language the book is written in.
+	Language  string `bun:",nullzero"`
+	PageCount int32  `bun:",nullzero"`
+	// Classification is the scheme CallNumber is written in, either "dewey"
+	// or "lcc", and CallNumberKey sorts the call number in shelf order.
+	Classification     string `bun:",nullzero"`
+	CallNumber         string `bun:",nullzero"`
+	CallNumberKey      string `bun:",nullzero"`
 	AvailabilityStatus string
+	Version            int32
+	// DeletedAt is when the book was deleted, just like that of users.
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
+
+	// Authors, Publishers, Subjects, Categories and Tags are linked to the
+	// book through join tables, in the order they were given.
+	Authors    []Author    `bun:"-"`
+	Publishers []Publisher `bun:"-"`
+	Subjects   []Subject   `bun:"-"`
+	Categories []Category  `bun:"-"`
+	Tags       []Tag       `bun:"-"`
+}
+
+// Author, Publisher, Subject, Category and Tag are tagged for JSON since
+// that's how they appear in responses and in the audit log snapshots of
+// books.
+type Author struct {
+	bun.BaseModel
+
+	ID   int32  `bun:",pk,autoincrement" json:"id"`
+	Name string `bun:",unique" json:"name"`
+}
+
+type Publisher struct {
+	bun.BaseModel
+
+	ID   int32  `bun:",pk,autoincrement" json:"id"`
+	Name string `bun:",unique" json:"name"`
+}
+
+type Subject struct {
+	bun.BaseModel
+
+	ID   int32  `bun:",pk,autoincrement" json:"id"`
+	Name string `bun:",unique" json:"name"`
+}
+
+// Category is a node of the tree of categories, at its root when ParentID
+// is zero.
+type Category struct {
+	bun.BaseModel
+
+	ID       int32  `bun:",pk,autoincrement" json:"id"`
+	ParentID int32  `bun:",nullzero" json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+}
+
+type Tag struct {
+	bun.BaseModel
+
+	ID   int32  `bun:",pk,autoincrement" json:"id"`
+	Name string `bun:",unique" json:"name"`
+}
+
+// BookAuthor links a book to one of its authors, Position being the place
+// of the author among those of the book, counting from 0.
+type BookAuthor struct {
+	bun.BaseModel
+
+	BookID   int32 `bun:",pk"`
+	AuthorID int32 `bun:",pk"`
+	Position int16
+}
+
+type BookPublisher struct {
+	bun.BaseModel
+
+	BookID      int32 `bun:",pk"`
+	PublisherID int32 `bun:",pk"`
+	Position    int16
+}
+
+type BookSubject struct {
+	bun.BaseModel
+
+	BookID    int32 `bun:",pk"`
+	SubjectID int32 `bun:",pk"`
+	Position  int16
+}
+
+type BookCategory struct {
+	bun.BaseModel
+
+	BookID     int32 `bun:",pk"`
+	CategoryID int32 `bun:",pk"`
+	Position   int16
+}
+
+type BookTag struct {
+	bun.BaseModel
+
+	BookID   int32 `bun:",pk"`
+	TagID    int32 `bun:",pk"`
+	Position int16
 }
 
 type Loan struct {
@@ -41,7 +151,82 @@ type Loan struct {
 type Reservation struct {
 	bun.BaseModel
 
+	ID         int32 `bun:",pk,autoincrement"`
+	UserID     int32
+	BookID     int32
+	CanceledAt sql.NullTime
+}
+
+type PasswordReset struct {
+	bun.BaseModel
+
+	ID        int32 `bun:",pk,autoincrement"`
+	UserID    int32
+	TokenHash []byte `bun:",unique"`
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type LoginAttempt struct {
+	bun.BaseModel
+
+	ID        int32 `bun:",pk,autoincrement"`
+	UserID    sql.NullInt32
+	Email     string
+	IP        string
+	Succeeded bool
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	bun.BaseModel
+
 	ID     int32 `bun:",pk,autoincrement"`
 	UserID int32
-	BookID int32
+	// FamilyID is shared by every token rotated from the same login.
+	FamilyID  []byte
+	TokenHash []byte `bun:",unique"`
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// UsedAt is when the token was exchanged for its successor.
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+type APIKey struct {
+	bun.BaseModel `bun:"table:api_keys"`
+
+	ID     int32 `bun:",pk,autoincrement"`
+	UserID int32
+	Name   string
+	// Prefix identifies the key, and is the only part of it kept in the
+	// clear.
+	Prefix     string   `bun:",unique"`
+	KeyHash    []byte   `audit:"-"`
+	Scopes     []string `bun:",array"`
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+}
+
+// AuditEntry records an action taken on an entity. Entries are never
+// deleted, nor updated except to clear the IP of those of anonymized users.
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	ID int32 `bun:",pk,autoincrement"`
+	// ActorID is the user who took the action, unless it was anonymous, and
+	// APIKeyID the key they took it with, if any.
+	ActorID  sql.NullInt32
+	APIKeyID sql.NullInt32 `bun:"api_key_id"`
+	Action   string
+	Entity   string
+	EntityID int32
+	// Before and After are the states of the entity around the action, as
+	// JSON objects keyed by column.
+	Before    json.RawMessage `bun:"type:jsonb,nullzero"`
+	After     json.RawMessage `bun:"type:jsonb,nullzero"`
+	IP        string          `bun:"ip,nullzero"`
+	RequestID string          `bun:",nullzero"`
+	CreatedAt time.Time
 }
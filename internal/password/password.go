@@ -0,0 +1,174 @@
+// Package password hashes passwords with bcrypt or argon2id and tells when a
+// stored hash was made with other settings than the current ones, so that it
+// can be upgraded the next time the plain password is at hand.
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match hash")
+	ErrInvalidHash = errors.New("invalid password hash")
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// Hasher hashes new passwords with Algorithm. The zero value hashes with
+// bcrypt at bcrypt.DefaultCost.
+type Hasher struct {
+	// Algorithm is either Bcrypt or Argon2id. Empty means Bcrypt.
+	Algorithm string
+	// BcryptCost is the cost of bcrypt hashes. Zero means
+	// bcrypt.DefaultCost.
+	BcryptCost int
+	// Argon2Time, Argon2Memory (in KiB) and Argon2Threads parameterize
+	// argon2id hashes. Zero values mean the second recommended option of
+	// RFC 9106: 3 passes over 64 MiB with 4 lanes.
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// Validate reports whether the algorithm is known.
+func (h Hasher) Validate() error {
+	switch h.Algorithm {
+	case "", Bcrypt, Argon2id:
+		return nil
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q", h.Algorithm)
+	}
+}
+
+func (h Hasher) Hash(password []byte) ([]byte, error) {
+	if h.Algorithm == Argon2id {
+		return h.argon2id(password)
+	}
+
+	return bcrypt.GenerateFromPassword(password, h.bcryptCost())
+}
+
+// Verify returns ErrMismatch unless password hashes to hash, which may have
+// been made with any supported algorithm and settings.
+func (h Hasher) Verify(hash, password []byte) error {
+	if bytes.HasPrefix(hash, []byte("$argon2id$")) {
+		p, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return err
+		}
+
+		got := argon2.IDKey(password, salt, p.time, p.memory, p.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return ErrMismatch
+		}
+
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// settings than h would use now.
+func (h Hasher) NeedsRehash(hash []byte) bool {
+	if h.Algorithm == Argon2id {
+		p, _, _, err := parseArgon2id(hash)
+		return err != nil || p != h.argon2Params()
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != h.bcryptCost()
+}
+
+func (h Hasher) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	return h.BcryptCost
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func (h Hasher) argon2Params() argon2Params {
+	p := argon2Params{time: h.Argon2Time, memory: h.Argon2Memory, threads: h.Argon2Threads}
+	if p.time == 0 {
+		p.time = 3
+	}
+	if p.memory == 0 {
+		p.memory = 64 * 1024
+	}
+	if p.threads == 0 {
+		p.threads = 4
+	}
+
+	return p
+}
+
+// argon2id hashes password into the PHC string format.
+func (h Hasher) argon2id(password []byte) ([]byte, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	p := h.argon2Params()
+	key := argon2.IDKey(password, salt, p.time, p.memory, p.threads, argon2KeyLen)
+
+	return fmt.Appendf(nil, "$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func parseArgon2id(hash []byte) (p argon2Params, salt, key []byte, err error) {
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 6 || string(parts[1]) != Argon2id {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(string(parts[2]), "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(string(parts[4]))
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(string(parts[5]))
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,83 @@
+package password_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/utilyre/lms/internal/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	cheapBcrypt   = password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+	cheapArgon2id = password.Hasher{Algorithm: password.Argon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+)
+
+func TestHashAndVerify(t *testing.T) {
+	for _, h := range []password.Hasher{cheapBcrypt, cheapArgon2id} {
+		t.Run(h.Algorithm, func(t *testing.T) {
+			hash, err := h.Hash([]byte("correct horse"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := h.Verify(hash, []byte("correct horse")); err != nil {
+				t.Errorf("verify right password: %v", err)
+			}
+			if err := h.Verify(hash, []byte("wrong horse")); !errors.Is(err, password.ErrMismatch) {
+				t.Errorf("verify wrong password: err = %v; want %v", err, password.ErrMismatch)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("fresh hash needs rehash")
+			}
+		})
+	}
+}
+
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	hash, err := cheapBcrypt.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cheapArgon2id.Verify(hash, []byte("correct horse")); err != nil {
+		t.Errorf("argon2id hasher can't verify bcrypt hash: %v", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := cheapBcrypt.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2idHash, err := cheapArgon2id.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	costlier := cheapBcrypt
+	costlier.BcryptCost++
+	stronger := cheapArgon2id
+	stronger.Argon2Time++
+
+	tests := []struct {
+		name   string
+		hasher password.Hasher
+		hash   []byte
+		want   bool
+	}{
+		{name: "bcrypt cost changed", hasher: costlier, hash: bcryptHash, want: true},
+		{name: "bcrypt to argon2id", hasher: cheapArgon2id, hash: bcryptHash, want: true},
+		{name: "argon2id to bcrypt", hasher: cheapBcrypt, hash: argon2idHash, want: true},
+		{name: "argon2id params changed", hasher: stronger, hash: argon2idHash, want: true},
+		{name: "argon2id unchanged", hasher: cheapArgon2id, hash: argon2idHash, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package ratelimit throttles clients with a sliding window kept in Redis:
+// every allowed request is recorded in a sorted set scored by its time, and a
+// request is rejected while the set already holds Limit entries younger than
+// the window.
+package ratelimit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/metrics"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+type Config struct {
+	RDB *redis.Client
+	// Rules are tried in order and the first one matching a request's route
+	// applies. Requests matching no rule aren't limited.
+	Rules []Rule
+	// Identify returns the client a request counts against. It defaults to
+	// the API key or authenticated user or, for anonymous requests, the
+	// client's IP address.
+	Identify func(c echo.Context) string
+}
+
+// slide drops the entries that fell out of the window, records the request
+// if there's room for it and reports whether it was allowed, how many
+// requests the window holds and how many milliseconds until the oldest one
+// expires.
+var slide = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", key, window)
+
+local reset = window
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, count, reset}
+`)
+
+// Middleware limits requests according to config.Rules and describes the
+// applied limit in RateLimit-* headers. Clients over the limit get 429 Too
+// Many Requests with a Retry-After header. Requests are let through when
+// Redis is unavailable.
+func Middleware(config Config) echo.MiddlewareFunc {
+	if config.Identify == nil {
+		config.Identify = auth.Client
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rule, ok := match(config.Rules, c.Request().Method, c.Path())
+			if !ok {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			key := "ratelimit:" + rule.Method + " " + rule.Path + ":" + config.Identify(c)
+			now := time.Now().UnixMilli()
+			res, err := slide.Run(ctx, config.RDB, []string{key},
+				now, rule.Window.Milliseconds(), rule.Limit, member(now),
+			).Int64Slice()
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to check rate limit", "error", err)
+				return next(c)
+			}
+			allowed, count, reset := res[0] == 1, int(res[1]), time.Duration(res[2])*time.Millisecond
+
+			resetSecs := seconds(reset)
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(rule.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(max(rule.Limit-count, 0)))
+			header.Set(HeaderRateLimitReset, resetSecs)
+			header.Set(HeaderRateLimitPolicy, strconv.Itoa(rule.Limit)+";w="+seconds(rule.Window))
+
+			if !allowed {
+				metrics.RateLimited(c.Path())
+				header.Set(echo.HeaderRetryAfter, resetSecs)
+				return c.JSON(http.StatusTooManyRequests, map[string]any{
+					"type":    "logic",
+					"message": "too many requests",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func match(rules []Rule, method, path string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.matches(method, path) {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// member makes a unique sorted set member for a request made at now so that
+// concurrent requests in the same millisecond are all counted.
+func member(now int64) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return strconv.FormatInt(now, 10) + "-" + hex.EncodeToString(b)
+}
+
+// seconds formats d as a whole number of seconds, rounding up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
@@ -0,0 +1,194 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/ratelimit"
+)
+
+func newServer(t *testing.T, rules ...ratelimit.Rule) (*echo.Echo, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	e := echo.New()
+	e.Use(ratelimit.Middleware(ratelimit.Config{RDB: rdb, Rules: rules}))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.POST("/loans", ok)
+	e.GET("/reports/:name", ok)
+
+	return e, mr
+}
+
+func request(e *echo.Echo, method, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = ip + ":1234"
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRejectsOverLimit(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: http.MethodPost, Path: "/loans", Limit: 2, Window: time.Minute})
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := request(e, http.MethodPost, "/loans", "10.0.0.1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d; want %d", i, rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get(ratelimit.HeaderRateLimitLimit); got != "2" {
+			t.Errorf("request %d: %s = %q; want %q", i, ratelimit.HeaderRateLimitLimit, got, "2")
+		}
+		if got := rec.Header().Get(ratelimit.HeaderRateLimitRemaining); got != remaining {
+			t.Errorf("request %d: %s = %q; want %q", i, ratelimit.HeaderRateLimitRemaining, got, remaining)
+		}
+	}
+
+	rec := request(e, http.MethodPost, "/loans", "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get(echo.HeaderRetryAfter); got != "60" {
+		t.Errorf("%s = %q; want %q", echo.HeaderRetryAfter, got, "60")
+	}
+	if got := rec.Header().Get(ratelimit.HeaderRateLimitRemaining); got != "0" {
+		t.Errorf("%s = %q; want %q", ratelimit.HeaderRateLimitRemaining, got, "0")
+	}
+}
+
+func TestLimitsEachClientSeparately(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: http.MethodPost, Path: "/loans", Limit: 1, Window: time.Minute})
+
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first client: status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.2"); rec.Code != http.StatusOK {
+		t.Fatalf("second client: status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("first client again: status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestLimitsUsersAcrossAddresses(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: http.MethodPost, Path: "/loans", Limit: 1, Window: time.Minute})
+	jane := auth.WithIdentity(context.Background(), auth.Identity{UserID: 1})
+
+	req := httptest.NewRequestWithContext(jane, http.MethodPost, "/loans", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequestWithContext(jane, http.MethodPost, "/loans", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("same user from another address: status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("anonymous: status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestWindowSlides(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: http.MethodPost, Path: "/loans", Limit: 1, Window: 100 * time.Millisecond})
+
+	request(e, http.MethodPost, "/loans", "10.0.0.1")
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("status after window = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestPrefixRuleSharesLimitAcrossRoutes(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: "*", Path: "/reports/*", Limit: 1, Window: time.Minute})
+
+	request(e, http.MethodGet, "/reports/overdue-loans", "10.0.0.1")
+	if rec := request(e, http.MethodGet, "/reports/popular-books", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("unmatched route: status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestUnmatchedRouteHasNoHeaders(t *testing.T) {
+	e, _ := newServer(t, ratelimit.Rule{Method: http.MethodGet, Path: "/loans", Limit: 1, Window: time.Minute})
+
+	rec := request(e, http.MethodPost, "/loans", "10.0.0.1")
+	if got := rec.Header().Get(ratelimit.HeaderRateLimitLimit); got != "" {
+		t.Errorf("%s = %q; want none", ratelimit.HeaderRateLimitLimit, got)
+	}
+}
+
+func TestAllowsWhenCacheDown(t *testing.T) {
+	e, mr := newServer(t, ratelimit.Rule{Method: http.MethodPost, Path: "/loans", Limit: 1, Window: time.Minute})
+	mr.Close()
+
+	for range 2 {
+		if rec := request(e, http.MethodPost, "/loans", "10.0.0.1"); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []ratelimit.Rule
+		wantErr bool
+	}{
+		{name: "empty", in: ""},
+		{
+			name: "several",
+			in:   "POST /api/v1/loans/=30/1m, get /api/v1/reports/*=20/30s",
+			want: []ratelimit.Rule{
+				{Method: http.MethodPost, Path: "/api/v1/loans/", Limit: 30, Window: time.Minute},
+				{Method: http.MethodGet, Path: "/api/v1/reports/*", Limit: 20, Window: 30 * time.Second},
+			},
+		},
+		{
+			name: "any method",
+			in:   "* /api/v1/*=300/1m",
+			want: []ratelimit.Rule{{Method: "*", Path: "/api/v1/*", Limit: 300, Window: time.Minute}},
+		},
+		{name: "missing quota", in: "POST /loans", wantErr: true},
+		{name: "missing path", in: "POST=1/1m", wantErr: true},
+		{name: "unknown method", in: "FETCH /loans=1/1m", wantErr: true},
+		{name: "zero limit", in: "POST /loans=0/1m", wantErr: true},
+		{name: "bad window", in: "POST /loans=1/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ratelimit.ParseRules(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v; want error: %t", err, tt.wantErr)
+			}
+			if len(rules) != len(tt.want) {
+				t.Fatalf("rules = %v; want %v", rules, tt.want)
+			}
+			for i := range rules {
+				if rules[i] != tt.want[i] {
+					t.Errorf("rules[%d] = %v; want %v", i, rules[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
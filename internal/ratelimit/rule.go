@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule limits requests to the routes it matches to Limit per Window for each
+// client.
+type Rule struct {
+	// Method is an HTTP method or "*" for any method.
+	Method string
+	// Path is an Echo route path such as /api/v1/loans/. A trailing "*"
+	// matches every route with the preceding prefix.
+	Path   string
+	Limit  int
+	Window time.Duration
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s=%d/%s", r.Method, r.Path, r.Limit, r.Window)
+}
+
+func (r Rule) matches(method, path string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+
+	return r.Path == path
+}
+
+// ParseRules parses a comma-separated list of rules, each written as
+//
+//	<method> <path>=<limit>/<window>
+//
+// e.g. "POST /api/v1/loans/=30/1m, GET /api/v1/reports/*=60/1m". The window is
+// a Go duration.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		rule, err := parseRule(field)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %w", field, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseRule(s string) (Rule, error) {
+	route, quota, ok := strings.Cut(s, "=")
+	if !ok {
+		return Rule{}, errors.New("missing quota")
+	}
+	method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+	if !ok {
+		return Rule{}, errors.New("route must be a method and a path")
+	}
+	method = strings.ToUpper(method)
+	path = strings.TrimSpace(path)
+	if method != "*" && !validMethod(method) {
+		return Rule{}, fmt.Errorf("unknown method %s", method)
+	}
+	if !strings.HasPrefix(path, "/") && path != "*" {
+		return Rule{}, errors.New("path must start with a slash")
+	}
+
+	limitStr, windowStr, ok := strings.Cut(quota, "/")
+	if !ok {
+		return Rule{}, errors.New("quota must be a limit and a window")
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit < 1 {
+		return Rule{}, errors.New("limit must be a positive integer")
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil {
+		return Rule{}, err
+	}
+	if window < time.Millisecond {
+		return Rule{}, errors.New("window must be at least 1ms")
+	}
+
+	return Rule{Method: method, Path: path, Limit: limit, Window: window}, nil
+}
+
+func validMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
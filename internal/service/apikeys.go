@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrTooLong        = errors.New("too long")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrInPast         = errors.New("in the past")
+)
+
+// apiKeyPrefixLen is the length of the part of API keys that identifies
+// them, e.g. "lms_1a2b3c4d", which is followed by an underscore and the
+// secret.
+const apiKeyPrefixLen = len(auth.APIKeyPrefix) + 8
+
+// APIKeyService manages API keys, which let integrations act on behalf of
+// the users who created them, limited to the scopes of each key.
+type APIKeyService struct {
+	Store store.Store
+}
+
+var _ auth.KeyVerifier = APIKeyService{}
+
+type APIKeyCreateParams struct {
+	Name   string
+	Scopes []string
+	// ExpiresAt is when the key stops working. The zero value means never.
+	ExpiresAt time.Time
+}
+
+// Create makes a new API key for the user and returns it along with the key
+// itself, which can't be recovered later.
+func (aks APIKeyService) Create(ctx context.Context, userID int32, params APIKeyCreateParams) (*model.APIKey, string, error) {
+	tracing.SetUserID(ctx, userID)
+	if len(params.Name) == 0 {
+		return nil, "", ValidationError{
+			Field: "name",
+			Err:   ErrRequired,
+		}
+	}
+	if utf8.RuneCountInString(params.Name) > 100 {
+		return nil, "", ValidationError{
+			Field: "name",
+			Err:   ErrTooLong,
+		}
+	}
+	if len(params.Scopes) == 0 {
+		return nil, "", ValidationError{
+			Field: "scopes",
+			Err:   ErrRequired,
+		}
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			return nil, "", ValidationError{
+				Field: "scopes",
+				Err:   ErrInvalidScope,
+			}
+		}
+	}
+	now := time.Now()
+	if !params.ExpiresAt.IsZero() && !params.ExpiresAt.After(now) {
+		return nil, "", ValidationError{
+			Field: "expires_at",
+			Err:   ErrInPast,
+		}
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	secret, _, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	prefix := auth.APIKeyPrefix + hex.EncodeToString(id)
+	key := prefix + "_" + secret
+
+	apiKey := model.APIKey{
+		UserID:    userID,
+		Name:      params.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(params.Scopes))),
+		CreatedAt: now,
+	}
+	if !params.ExpiresAt.IsZero() {
+		apiKey.ExpiresAt = sql.NullTime{Time: params.ExpiresAt, Valid: true}
+	}
+	if err := aks.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := tx.APIKeys().Create(ctx, &apiKey); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionCreate, "api_key", apiKey.ID, nil, &apiKey)
+	}); err != nil {
+		if errors.Is(err, store.ErrInvalidReference) {
+			return nil, "", ErrUserNotFound
+		}
+
+		return nil, "", err
+	}
+
+	return &apiKey, key, nil
+}
+
+// List lists the API keys of the user.
+func (aks APIKeyService) List(ctx context.Context, userID int32) ([]model.APIKey, error) {
+	tracing.SetUserID(ctx, userID)
+	return aks.Store.APIKeys().ListByUserID(ctx, userID)
+}
+
+// DeleteByID revokes one of the user's API keys. Keys of other users are
+// reported as not found.
+func (aks APIKeyService) DeleteByID(ctx context.Context, userID, id int32) error {
+	tracing.SetUserID(ctx, userID)
+	if id < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	return aks.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		key, err := tx.APIKeys().GetByID(ctx, id)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && key.UserID != userID) {
+			return ErrAPIKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.APIKeys().DeleteByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionDelete, "api_key", id, key, nil)
+	})
+}
+
+// VerifyKey returns the identity of the user who created key, limited to its
+// scopes, or auth.ErrInvalidToken when key is unknown or expired.
+func (aks APIKeyService) VerifyKey(ctx context.Context, key string) (auth.Identity, error) {
+	if len(key) <= apiKeyPrefixLen || key[apiKeyPrefixLen] != '_' {
+		return auth.Identity{}, auth.ErrInvalidToken
+	}
+
+	apiKey, err := aks.Store.APIKeys().GetByPrefix(ctx, key[:apiKeyPrefixLen])
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return auth.Identity{}, auth.ErrInvalidToken
+		}
+
+		return auth.Identity{}, err
+	}
+	if subtle.ConstantTimeCompare(apiKey.KeyHash, hashToken(key)) != 1 {
+		return auth.Identity{}, auth.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt.Valid && !now.Before(apiKey.ExpiresAt.Time) {
+		return auth.Identity{}, auth.ErrInvalidToken
+	}
+	tracing.SetUserID(ctx, apiKey.UserID)
+
+	user, err := aks.Store.Users().GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return auth.Identity{}, auth.ErrInvalidToken
+		}
+
+		return auth.Identity{}, err
+	}
+
+	// Recording every use would turn each request into a write.
+	if !apiKey.LastUsedAt.Valid || now.Sub(apiKey.LastUsedAt.Time) >= time.Minute {
+		used := model.APIKey{ID: apiKey.ID, LastUsedAt: sql.NullTime{Time: now, Valid: true}}
+		if err := aks.Store.APIKeys().Update(ctx, &used); err != nil {
+			slog.WarnContext(ctx, "Failed to record API key use", "error", err)
+		}
+	}
+
+	return auth.Identity{
+		UserID:   user.ID,
+		Role:     user.Role,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func TestAPIKeyServiceCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  service.APIKeyCreateParams
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			params: service.APIKeyCreateParams{Name: "Kiosk", Scopes: []string{"loans:write", "books:read"}},
+		},
+		{
+			name:    "missing name",
+			params:  service.APIKeyCreateParams{Scopes: []string{"books:read"}},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "long name",
+			params:  service.APIKeyCreateParams{Name: strings.Repeat("a", 101), Scopes: []string{"books:read"}},
+			wantErr: service.ErrTooLong,
+		},
+		{
+			name:    "missing scopes",
+			params:  service.APIKeyCreateParams{Name: "Kiosk"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "invalid scope",
+			params:  service.APIKeyCreateParams{Name: "Kiosk", Scopes: []string{"books:delete"}},
+			wantErr: service.ErrInvalidScope,
+		},
+		{
+			name: "expired",
+			params: service.APIKeyCreateParams{
+				Name:      "Kiosk",
+				Scopes:    []string{"books:read"},
+				ExpiresAt: time.Now().Add(-time.Hour),
+			},
+			wantErr: service.ErrInPast,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newUserService()
+			jane := mustCreateUser(t, us, "jane@example.com")
+			aks := service.APIKeyService{Store: us.Store}
+
+			apiKey, key, err := aks.Create(context.Background(), jane.ID, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !strings.HasPrefix(key, apiKey.Prefix+"_") {
+				t.Errorf("key = %q; want it to start with %q", key, apiKey.Prefix+"_")
+			}
+			if want := []string{"books:read", "loans:write"}; !slices.Equal(apiKey.Scopes, want) {
+				t.Errorf("scopes = %v; want %v", apiKey.Scopes, want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyServiceVerifyKey(t *testing.T) {
+	us := newUserService()
+	jane := mustCreateUser(t, us, "jane@example.com")
+	aks := service.APIKeyService{Store: us.Store}
+
+	apiKey, key, err := aks.Create(context.Background(), jane.ID, service.APIKeyCreateParams{
+		Name:   "Kiosk",
+		Scopes: []string{"loans:write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := aks.VerifyKey(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UserID != jane.ID || id.Role != jane.Role || id.APIKeyID != apiKey.ID {
+		t.Errorf("identity = %+v; want key %d of user %d", id, apiKey.ID, jane.ID)
+	}
+	if !id.Allows("loans", "write") || id.Allows("books", "write") {
+		t.Errorf("identity = %+v; want it limited to loans:write", id)
+	}
+
+	keys, err := aks.List(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].LastUsedAt.Valid {
+		t.Errorf("keys = %+v; want one that was used", keys)
+	}
+
+	for name, bogus := range map[string]string{
+		"wrong secret": apiKey.Prefix + "_bogus",
+		"unknown":      "lms_00000000_bogus",
+		"malformed":    "lms_bogus",
+	} {
+		if _, err := aks.VerifyKey(context.Background(), bogus); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("%s: err = %v; want %v", name, err, auth.ErrInvalidToken)
+		}
+	}
+}
+
+func TestAPIKeyServiceVerifyKeyExpired(t *testing.T) {
+	us := newUserService()
+	jane := mustCreateUser(t, us, "jane@example.com")
+	aks := service.APIKeyService{Store: us.Store}
+
+	_, key, err := aks.Create(context.Background(), jane.ID, service.APIKeyCreateParams{
+		Name:      "Kiosk",
+		Scopes:    []string{"loans:write"},
+		ExpiresAt: time.Now().Add(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if _, err := aks.VerifyKey(context.Background(), key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestAPIKeyServiceDeleteByID(t *testing.T) {
+	us := newUserService()
+	jane := mustCreateUser(t, us, "jane@example.com")
+	john := mustCreateUser(t, us, "john@example.com")
+	aks := service.APIKeyService{Store: us.Store}
+
+	apiKey, key, err := aks.Create(context.Background(), jane.ID, service.APIKeyCreateParams{
+		Name:   "Kiosk",
+		Scopes: []string{"loans:write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := aks.DeleteByID(context.Background(), john.ID, apiKey.ID); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Fatalf("another user's key: err = %v; want %v", err, service.ErrAPIKeyNotFound)
+	}
+	if err := aks.DeleteByID(context.Background(), jane.ID, apiKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aks.VerifyKey(context.Background(), key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("deleted key: err = %v; want %v", err, auth.ErrInvalidToken)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+var ErrOutOfRange = errors.New("out of range")
+
+// AuditService queries the audit log, which the other services append to as
+// they make changes.
+type AuditService struct {
+	Store store.Store
+}
+
+type AuditListParams struct {
+	ActorID  int32
+	Action   string
+	Entity   string
+	EntityID int32
+	Since    time.Time
+	Until    time.Time
+	BeforeID int32
+	// Limit is how many entries to list at most, up to 500. Zero means 50.
+	Limit int
+}
+
+// List lists the audit log entries matching params from newest to oldest.
+func (as AuditService) List(ctx context.Context, params AuditListParams) ([]model.AuditEntry, error) {
+	ids := []struct {
+		field string
+		id    int32
+	}{
+		{field: "actor_id", id: params.ActorID},
+		{field: "entity_id", id: params.EntityID},
+		{field: "before_id", id: params.BeforeID},
+	}
+	for _, id := range ids {
+		if id.id < 0 {
+			return nil, ValidationError{
+				Field: id.field,
+				Err:   ErrInvalidID,
+			}
+		}
+	}
+	if params.Limit < 0 || params.Limit > 500 {
+		return nil, ValidationError{
+			Field: "limit",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if params.Limit == 0 {
+		params.Limit = 50
+	}
+
+	return as.Store.AuditLog().List(ctx, store.AuditFilter(params))
+}
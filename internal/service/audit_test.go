@@ -0,0 +1,153 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/logging"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func TestAuditTrail(t *testing.T) {
+	lib := newLibrary(t)
+	as := service.AuditService{Store: lib.st}
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: lib.john.ID, Role: "librarian"})
+	ctx = audit.WithClientIP(ctx, "10.0.0.1")
+	ctx = logging.WithRequestID(ctx, "req-1")
+
+	loan, err := lib.books.Borrow(ctx, service.BookBorrowParams{UserID: lib.jane.ID, BookID: lib.book.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.books.ReturnLoan(ctx, service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.books.ReturnLoan(ctx, service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: time.Now()}); !errors.Is(err, service.ErrLoanReturned) {
+		t.Fatalf("err = %v; want %v", err, service.ErrLoanReturned)
+	}
+	if err := lib.books.DeleteByID(ctx, lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := as.List(context.Background(), service.AuditListParams{ActorID: lib.john.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action, entity string
+		id             int32
+	}{
+		{action: audit.ActionDelete, entity: "book", id: lib.book.ID},
+		{action: audit.ActionReturn, entity: "loan", id: loan.ID},
+		{action: audit.ActionBorrow, entity: "loan", id: loan.ID},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v; want %d", entries, len(want))
+	}
+	for i, entry := range entries {
+		if entry.Action != want[i].action || entry.Entity != want[i].entity || entry.EntityID != want[i].id {
+			t.Errorf("entries[%d] = %s %s %d; want %s %s %d", i,
+				entry.Action, entry.Entity, entry.EntityID, want[i].action, want[i].entity, want[i].id)
+		}
+		if entry.IP != "10.0.0.1" || entry.RequestID != "req-1" {
+			t.Errorf("entries[%d] came from %q in %q; want 10.0.0.1 in req-1", i, entry.IP, entry.RequestID)
+		}
+	}
+
+	var before, after map[string]any
+	if err := json.Unmarshal(entries[1].Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(entries[1].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if before["return_date"] != nil || after["return_date"] == nil {
+		t.Errorf("return recorded %v -> %v; want return_date set", before, after)
+	}
+	if entries[0].After != nil {
+		t.Errorf("after of delete = %s; want none", entries[0].After)
+	}
+}
+
+func TestAuditTrailOmitsPersonalData(t *testing.T) {
+	us := newUserService()
+	mustCreateUser(t, us, "jane@example.com")
+
+	entries, err := service.AuditService{Store: us.Store}.List(context.Background(), service.AuditListParams{
+		Entity: "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != audit.ActionCreate {
+		t.Fatalf("entries = %+v; want the creation of jane", entries)
+	}
+	if entries[0].ActorID.Valid {
+		t.Errorf("actor = %d; want anonymous", entries[0].ActorID.Int32)
+	}
+	after := string(entries[0].After)
+	if !strings.Contains(after, `"role":"member"`) || strings.Contains(after, "password") || strings.Contains(after, "Jane") || strings.Contains(after, "jane@") {
+		t.Errorf("after = %s; want the user without their password, name or email", after)
+	}
+}
+
+func TestAuditTrailRollsBack(t *testing.T) {
+	lib := newLibrary(t)
+
+	if _, err := lib.books.UpdateByID(context.Background(), lib.book.ID, service.BookUpdateByIDParams{
+		Title:    "Dune",
+		Authors:  []string{"Frank Herbert"},
+		ISBN:     "9780441013593",
+		Versions: []int32{7},
+	}); !errors.Is(err, service.ErrVersionMismatch) {
+		t.Fatalf("err = %v; want %v", err, service.ErrVersionMismatch)
+	}
+
+	entries, err := service.AuditService{Store: lib.st}.List(context.Background(), service.AuditListParams{
+		Action: audit.ActionUpdate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries = %+v; want none", entries)
+	}
+}
+
+func TestAuditServiceList(t *testing.T) {
+	lib := newLibrary(t)
+	as := service.AuditService{Store: lib.st}
+
+	all, err := as.List(context.Background(), service.AuditListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("entries = %d; want 3", len(all))
+	}
+
+	page, err := as.List(context.Background(), service.AuditListParams{BeforeID: all[0].ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != all[1].ID {
+		t.Errorf("page = %+v; want entry %d", page, all[1].ID)
+	}
+
+	for name, params := range map[string]service.AuditListParams{
+		"negative id": {ActorID: -1},
+		"huge limit":  {Limit: 501},
+	} {
+		if _, err := as.List(context.Background(), params); !errors.As(err, new(service.ValidationError)) {
+			t.Errorf("%s: err = %v; want ValidationError", name, err)
+		}
+	}
+}
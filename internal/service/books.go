@@ -4,63 +4,293 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/callnumber"
 	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
 )
 
 var (
-	ErrBookNotFound = errors.New("book not found")
-	ErrBookReserved = errors.New("book reserved")
-	ErrBookBorrowed = errors.New("book borrowed")
+	ErrBookNotFound        = errors.New("book not found")
+	ErrBookReserved        = errors.New("book reserved")
+	ErrBookBorrowed        = errors.New("book borrowed")
+	ErrLoanNotFound        = errors.New("loan not found")
+	ErrLoanReturned        = errors.New("loan returned")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationCanceled = errors.New("reservation canceled")
+	ErrBeforeLoanDate      = errors.New("before loan date")
+	ErrInvalidLanguage     = errors.New("invalid language")
 )
 
 type BookService struct {
-	DB bun.IDB
+	Store store.Store
 }
 
 type BookCreateParams struct {
-	Title  string
-	Author string
-	ISBN   string
+	Title string
+	// Authors, of which there must be at least one, Publishers, Subjects and
+	// Tags are the names of the entities to link the book to, which are
+	// created as needed.
+	Authors         []string
+	Publishers      []string
+	Subjects        []string
+	ISBN            string
+	PublicationYear int32
+	Edition         string
+	Language        string
+	PageCount       int32
+	// Categories are the IDs of the categories the book is filed under,
+	// which must exist.
+	Categories []int32
+	Tags       []string
+	// Classification is the scheme CallNumber is written in, either "dewey"
+	// or "lcc". Both are required if either is given.
+	Classification string
+	CallNumber     string
 }
 
 func (bs BookService) Create(ctx context.Context, params BookCreateParams) (*model.Book, error) {
-	if len(params.Title) == 0 {
-		return nil, ValidationError{
-			Field: "title",
-			Err:   ErrRequired,
+	book := model.Book{
+		Title:              params.Title,
+		Authors:            newAuthors(params.Authors),
+		Publishers:         newPublishers(params.Publishers),
+		Subjects:           newSubjects(params.Subjects),
+		ISBN:               params.ISBN,
+		PublicationYear:    params.PublicationYear,
+		Edition:            params.Edition,
+		Language:           params.Language,
+		PageCount:          params.PageCount,
+		Categories:         newCategories(params.Categories),
+		Tags:               newTags(params.Tags),
+		Classification:     params.Classification,
+		CallNumber:         params.CallNumber,
+		AvailabilityStatus: "available",
+	}
+	if err := validateBook(&book); err != nil {
+		return nil, err
+	}
+
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := linkBooks(ctx, tx, &book); err != nil {
+			return err
 		}
+		if err := tx.Books().Create(ctx, &book); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionCreate, "book", book.ID, nil, &book)
+	}); err != nil {
+		return nil, err
 	}
-	if len(params.Author) == 0 {
-		return nil, ValidationError{
-			Field: "author",
+
+	return &book, nil
+}
+
+// validateBook checks the fields of a book, whether it's created, updated
+// or imported, and fills in the key of its call number.
+func validateBook(book *model.Book) error {
+	if err := validateBookField("title", book.Title); err != nil {
+		return err
+	}
+	if err := validateBookNames("authors", authorNames(book.Authors)); err != nil {
+		return err
+	}
+	if len(book.Authors) == 0 {
+		return ValidationError{
+			Field: "authors",
 			Err:   ErrRequired,
 		}
 	}
-	if len(params.ISBN) == 0 {
-		return nil, ValidationError{
-			Field: "isbn",
+	if err := validateBookField("isbn", book.ISBN); err != nil {
+		return err
+	}
+	if err := validateBookNames("publishers", publisherNames(book.Publishers)); err != nil {
+		return err
+	}
+	if err := validateBookNames("subjects", subjectNames(book.Subjects)); err != nil {
+		return err
+	}
+	if err := validatePublicationYear(book.PublicationYear); err != nil {
+		return err
+	}
+	if book.Edition != "" {
+		if err := validateBookField("edition", book.Edition); err != nil {
+			return err
+		}
+	}
+	if err := validateLanguage(book.Language); err != nil {
+		return err
+	}
+	if err := validatePageCount(book.PageCount); err != nil {
+		return err
+	}
+	if err := validateCategories(book.Categories); err != nil {
+		return err
+	}
+	if err := validateBookNames("tags", tagNames(book.Tags)); err != nil {
+		return err
+	}
+
+	key, err := validateCallNumber(book.Classification, book.CallNumber)
+	if err != nil {
+		return err
+	}
+	book.CallNumberKey = key
+
+	return nil
+}
+
+// bookFieldMaxLens are the lengths of the columns the fields of books, and
+// the names of the entities they're linked to and of categories, are stored
+// in.
+var bookFieldMaxLens = map[string]int{
+	"title":       100,
+	"isbn":        13,
+	"edition":     50,
+	"authors":     100,
+	"publishers":  100,
+	"subjects":    100,
+	"tags":        100,
+	"call_number": 50,
+	"name":        100,
+}
+
+func validateBookField(name, value string) error {
+	if len(value) == 0 {
+		return ValidationError{
+			Field: name,
 			Err:   ErrRequired,
 		}
 	}
+	if utf8.RuneCountInString(value) > bookFieldMaxLens[name] {
+		return ValidationError{
+			Field: name,
+			Err:   ErrTooLong,
+		}
+	}
 
-	book := model.Book{
-		Title:              params.Title,
-		Author:             params.Author,
-		ISBN:               params.ISBN,
-		AvailabilityStatus: "available",
+	return nil
+}
+
+// validateBookNames checks the names of the entities a book is linked to,
+// which must be distinct.
+func validateBookNames(name string, values []string) error {
+	for i, value := range values {
+		if err := validateBookField(name, value); err != nil {
+			return err
+		}
+		if slices.Contains(values[:i], value) {
+			return ValidationError{
+				Field: name,
+				Err:   fmt.Errorf("%w name %q", ErrDuplicate, value),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePublicationYear allows years up to the next one, for books
+// announced ahead of their publication. Zero means the year is unknown.
+func validatePublicationYear(year int32) error {
+	if year < 0 || int(year) > time.Now().Year()+1 {
+		return ValidationError{
+			Field: "publication_year",
+			Err:   ErrOutOfRange,
+		}
+	}
+
+	return nil
+}
+
+// validateLanguage checks that language is empty or an ISO 639-1 or 639-2
+// code, such as "en" or "eng".
+func validateLanguage(language string) error {
+	if language == "" {
+		return nil
+	}
+	if len(language) < 2 || len(language) > 3 || strings.Trim(language, "abcdefghijklmnopqrstuvwxyz") != "" {
+		return ValidationError{
+			Field: "language",
+			Err:   ErrInvalidLanguage,
+		}
 	}
 
-	_, err := bs.DB.NewInsert().Model(&book).Exec(ctx)
+	return nil
+}
+
+func validatePageCount(count int32) error {
+	if count < 0 {
+		return ValidationError{
+			Field: "page_count",
+			Err:   ErrOutOfRange,
+		}
+	}
+
+	return nil
+}
+
+// validateCategories checks that the IDs of the categories of a book are
+// valid and distinct. Whether the categories exist is up to linkBooks.
+func validateCategories(categories []model.Category) error {
+	for i, category := range categories {
+		if category.ID < 1 {
+			return ValidationError{
+				Field: "categories",
+				Err:   ErrInvalidID,
+			}
+		}
+		if slices.ContainsFunc(categories[:i], func(prev model.Category) bool {
+			return prev.ID == category.ID
+		}) {
+			return ValidationError{
+				Field: "categories",
+				Err:   fmt.Errorf("%w category %d", ErrDuplicate, category.ID),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCallNumber checks that a book has both a classification and a call
+// number written in it, or neither, and returns the key of the call number.
+func validateCallNumber(classification, callNumber string) (string, error) {
+	if classification == "" && callNumber == "" {
+		return "", nil
+	}
+	if classification == "" {
+		return "", ValidationError{
+			Field: "classification",
+			Err:   ErrRequired,
+		}
+	}
+	if err := validateBookField("call_number", callNumber); err != nil {
+		return "", err
+	}
+
+	key, err := callnumber.Key(callnumber.Scheme(classification), callNumber)
+	if errors.Is(err, callnumber.ErrUnknownScheme) {
+		return "", ValidationError{
+			Field: "classification",
+			Err:   err,
+		}
+	}
 	if err != nil {
-		return nil, err
+		return "", ValidationError{
+			Field: "call_number",
+			Err:   err,
+		}
 	}
 
-	return &book, nil
+	return key, nil
 }
 
 func (bs BookService) GetByID(ctx context.Context, id int32) (*model.Book, error) {
@@ -71,29 +301,47 @@ func (bs BookService) GetByID(ctx context.Context, id int32) (*model.Book, error
 		}
 	}
 
-	var book model.Book
-	if err := bs.DB.
-		NewSelect().
-		Model(&book).
-		Where("id = ?", id).
-		Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	book, err := bs.Store.Books().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, ErrBookNotFound
 		}
 
 		return nil, err
 	}
 
-	return &book, nil
+	return book, nil
 }
 
 type BookUpdateByIDParams struct {
 	Title              string
-	Author             string
+	Authors            []string
+	Publishers         []string
+	Subjects           []string
 	ISBN               string
+	PublicationYear    int32
+	Edition            string
+	Language           string
+	PageCount          int32
+	Categories         []int32
+	Tags               []string
+	Classification     string
+	CallNumber         string
 	AvailabilityStatus string
+	// Versions, unless empty, lists the versions the book must be at for the
+	// update to go through.
+	Versions []int32
 }
 
+// bookColumns are the columns UpdateByID replaces.
+var bookColumns = []string{
+	"title", "authors", "publishers", "subjects", "isbn",
+	"publication_year", "edition", "language", "page_count",
+	"categories", "tags", "classification", "call_number", "call_number_key",
+}
+
+// UpdateByID replaces the book with the one described by params, except that
+// an empty availability status leaves that of the book alone.
 func (bs BookService) UpdateByID(ctx context.Context, id int32, params BookUpdateByIDParams) (*model.Book, error) {
 	if id < 1 {
 		return nil, ValidationError{
@@ -101,52 +349,346 @@ func (bs BookService) UpdateByID(ctx context.Context, id int32, params BookUpdat
 			Err:   ErrInvalidID,
 		}
 	}
-	if len(params.Title) == 0 {
-		return nil, ValidationError{
-			Field: "title",
-			Err:   ErrRequired,
+
+	book := model.Book{
+		ID:                 id,
+		Title:              params.Title,
+		Authors:            newAuthors(params.Authors),
+		Publishers:         newPublishers(params.Publishers),
+		Subjects:           newSubjects(params.Subjects),
+		ISBN:               params.ISBN,
+		PublicationYear:    params.PublicationYear,
+		Edition:            params.Edition,
+		Language:           params.Language,
+		PageCount:          params.PageCount,
+		Categories:         newCategories(params.Categories),
+		Tags:               newTags(params.Tags),
+		Classification:     params.Classification,
+		CallNumber:         params.CallNumber,
+		AvailabilityStatus: params.AvailabilityStatus,
+	}
+	if err := validateBook(&book); err != nil {
+		return nil, err
+	}
+	columns := bookColumns
+	if book.AvailabilityStatus != "" {
+		columns = append(slices.Clip(columns), "availability_status")
+	}
+
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getBookAtVersion(ctx, tx, id, params.Versions)
+		if err != nil {
+			return err
+		}
+
+		if err := linkBooks(ctx, tx, &book); err != nil {
+			return err
+		}
+		if err := tx.Books().Update(ctx, &book, columns...); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "book", id, before, &book)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrBookNotFound
 		}
+
+		return nil, err
 	}
-	if len(params.Author) == 0 {
+
+	return &book, nil
+}
+
+type BookPatchByIDParams struct {
+	Title              Optional[string]
+	Authors            Optional[[]string]
+	Publishers         Optional[[]string]
+	Subjects           Optional[[]string]
+	ISBN               Optional[string]
+	PublicationYear    Optional[int32]
+	Edition            Optional[string]
+	Language           Optional[string]
+	PageCount          Optional[int32]
+	Categories         Optional[[]int32]
+	Tags               Optional[[]string]
+	Classification     Optional[string]
+	CallNumber         Optional[string]
+	AvailabilityStatus Optional[string]
+	Versions           []int32
+}
+
+// PatchByID updates only the fields set in params. Title, authors and ISBN
+// can't be cleared, whereas clearing any other field empties it. The
+// classification and call number are checked together, taking the one that
+// isn't set from the book.
+func (bs BookService) PatchByID(ctx context.Context, id int32, params BookPatchByIDParams) (*model.Book, error) {
+	if id < 1 {
 		return nil, ValidationError{
-			Field: "author",
-			Err:   ErrRequired,
+			Field: "id",
+			Err:   ErrInvalidID,
 		}
 	}
-	if len(params.ISBN) == 0 {
-		return nil, ValidationError{
-			Field: "isbn",
-			Err:   ErrRequired,
+
+	patch := model.Book{ID: id}
+	var columns []string
+	if params.Title.Set {
+		if params.Title.Null {
+			return nil, ValidationError{
+				Field: "title",
+				Err:   ErrRequired,
+			}
 		}
+		if err := validateBookField("title", params.Title.Value); err != nil {
+			return nil, err
+		}
+
+		patch.Title = params.Title.Value
+		columns = append(columns, "title")
 	}
+	if params.Authors.Set {
+		patch.Authors = newAuthors(params.Authors.Value)
+		if params.Authors.Null || len(patch.Authors) == 0 {
+			return nil, ValidationError{
+				Field: "authors",
+				Err:   ErrRequired,
+			}
+		}
+		if err := validateBookNames("authors", authorNames(patch.Authors)); err != nil {
+			return nil, err
+		}
 
-	book := model.Book{
-		Title:              params.Title,
-		Author:             params.Author,
-		ISBN:               params.ISBN,
-		AvailabilityStatus: params.AvailabilityStatus,
+		columns = append(columns, "authors")
 	}
+	if params.Publishers.Set {
+		patch.Publishers = newPublishers(params.Publishers.Value)
+		if err := validateBookNames("publishers", publisherNames(patch.Publishers)); err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, "publishers")
+	}
+	if params.Subjects.Set {
+		patch.Subjects = newSubjects(params.Subjects.Value)
+		if err := validateBookNames("subjects", subjectNames(patch.Subjects)); err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, "subjects")
+	}
+	if params.ISBN.Set {
+		if params.ISBN.Null {
+			return nil, ValidationError{
+				Field: "isbn",
+				Err:   ErrRequired,
+			}
+		}
+		if err := validateBookField("isbn", params.ISBN.Value); err != nil {
+			return nil, err
+		}
+
+		patch.ISBN = params.ISBN.Value
+		columns = append(columns, "isbn")
+	}
+	if params.PublicationYear.Set {
+		if err := validatePublicationYear(params.PublicationYear.Value); err != nil {
+			return nil, err
+		}
+
+		patch.PublicationYear = params.PublicationYear.Value
+		columns = append(columns, "publication_year")
+	}
+	if params.Edition.Set {
+		if params.Edition.Value != "" {
+			if err := validateBookField("edition", params.Edition.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		patch.Edition = params.Edition.Value
+		columns = append(columns, "edition")
+	}
+	if params.Language.Set {
+		if err := validateLanguage(params.Language.Value); err != nil {
+			return nil, err
+		}
+
+		patch.Language = params.Language.Value
+		columns = append(columns, "language")
+	}
+	if params.PageCount.Set {
+		if err := validatePageCount(params.PageCount.Value); err != nil {
+			return nil, err
+		}
+
+		patch.PageCount = params.PageCount.Value
+		columns = append(columns, "page_count")
+	}
+	if params.Categories.Set {
+		patch.Categories = newCategories(params.Categories.Value)
+		if err := validateCategories(patch.Categories); err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, "categories")
+	}
+	if params.Tags.Set {
+		patch.Tags = newTags(params.Tags.Value)
+		if err := validateBookNames("tags", tagNames(patch.Tags)); err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, "tags")
+	}
+	classify := params.Classification.Set || params.CallNumber.Set
+	if classify {
+		columns = append(columns, "classification", "call_number", "call_number_key")
+	}
+	if params.AvailabilityStatus.Set {
+		patch.AvailabilityStatus = params.AvailabilityStatus.Value
+		columns = append(columns, "availability_status")
+	}
+
+	var book *model.Book
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getBookAtVersion(ctx, tx, id, params.Versions)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			book = before
+			return nil
+		}
+
+		updated := patch
+		book = &updated
+		if classify {
+			book.Classification, book.CallNumber = before.Classification, before.CallNumber
+			if params.Classification.Set {
+				book.Classification = params.Classification.Value
+			}
+			if params.CallNumber.Set {
+				book.CallNumber = params.CallNumber.Value
+			}
+
+			key, err := validateCallNumber(book.Classification, book.CallNumber)
+			if err != nil {
+				return err
+			}
+			book.CallNumberKey = key
+		}
+		if err := linkBooks(ctx, tx, book); err != nil {
+			return err
+		}
+		if err := tx.Books().Update(ctx, book, columns...); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "book", id, before, book)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrBookNotFound
+		}
 
-	if _, err := bs.DB.
-		NewUpdate().
-		Model(&book).
-		OmitZero().
-		Where("id = ?", id).
-		Exec(ctx); err != nil {
 		return nil, err
 	}
-	if err := bs.DB.
-		NewSelect().
-		Model(&book).
-		Where("id = ?", id).
-		Scan(ctx); err != nil {
+
+	return book, nil
+}
+
+// DeleteByID deletes the book, provided it's at one of versions when any are
+// given. Deleted books keep their loans and can be restored until they're
+// purged. Books that are out on loan or reserved aren't deleted, failing with
+// InUseError.
+func (bs BookService) DeleteByID(ctx context.Context, id int32, versions ...int32) error {
+	return bs.deleteByID(ctx, id, false, versions)
+}
+
+// ForceDeleteByID deletes the book like DeleteByID, except that it returns
+// its open loans and cancels its active reservations first.
+func (bs BookService) ForceDeleteByID(ctx context.Context, id int32, versions ...int32) error {
+	return bs.deleteByID(ctx, id, true, versions)
+}
+
+func (bs BookService) deleteByID(ctx context.Context, id int32, force bool, versions []int32) error {
+	if id < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getBookAtVersion(ctx, tx, id, versions)
+		if err != nil {
+			return err
+		}
+
+		loans, err := tx.Loans().ListOpenByBookID(ctx, id)
+		if err != nil {
+			return err
+		}
+		reservations, err := tx.Reservations().ListActiveByBookID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := closeOut(ctx, tx, loans, reservations, force); err != nil {
+			return err
+		}
+
+		if err := tx.Books().DeleteByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionDelete, "book", id, before, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrBookNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RestoreByID undoes the deletion of the book.
+func (bs BookService) RestoreByID(ctx context.Context, id int32) (*model.Book, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	var book *model.Book
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getDeletedBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Books().Restore(ctx, id); err != nil {
+			return err
+		}
+		if book, err = tx.Books().GetByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionRestore, "book", id, before, book)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrBookNotFound
+		}
+
 		return nil, err
 	}
 
-	return &book, nil
+	return book, nil
 }
 
-func (bs BookService) DeleteByID(ctx context.Context, id int32) error {
+// PurgeByID permanently removes the deleted book along with its loans and
+// reservations.
+func (bs BookService) PurgeByID(ctx context.Context, id int32) error {
 	if id < 1 {
 		return ValidationError{
 			Field: "id",
@@ -154,23 +696,64 @@ func (bs BookService) DeleteByID(ctx context.Context, id int32) error {
 		}
 	}
 
-	if _, err := bs.DB.
-		NewDelete().
-		Model((*model.Book)(nil)).
-		Where("id = ?", id).
-		Exec(ctx); err != nil {
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getDeletedBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Books().Purge(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionPurge, "book", id, before, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrBookNotFound
+		}
+
 		return err
 	}
 
 	return nil
 }
 
+// getDeletedBook returns the deleted book, failing with ErrNotDeleted when
+// the book exists but isn't deleted.
+func getDeletedBook(ctx context.Context, tx store.Store, id int32) (*model.Book, error) {
+	book, err := tx.Books().GetDeletedByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		if _, err := tx.Books().GetByID(ctx, id); err == nil {
+			return nil, ErrNotDeleted
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+// getBookAtVersion returns the book, failing with ErrVersionMismatch unless
+// versions is empty or contains its current version.
+func getBookAtVersion(ctx context.Context, tx store.Store, id int32, versions []int32) (*model.Book, error) {
+	book, err := tx.Books().GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 && !slices.Contains(versions, book.Version) {
+		return nil, ErrVersionMismatch
+	}
+
+	return book, nil
+}
+
 type BookBorrowParams struct {
 	UserID int32
 	BookID int32
 }
 
 func (bs BookService) Borrow(ctx context.Context, params BookBorrowParams) (*model.Loan, error) {
+	tracing.SetUserID(ctx, params.UserID)
 	if params.UserID < 1 {
 		return nil, ValidationError{
 			Field: "user_id",
@@ -184,35 +767,35 @@ func (bs BookService) Borrow(ctx context.Context, params BookBorrowParams) (*mod
 		}
 	}
 
-	reserved := true
-	var reservation model.Reservation
-	if err := bs.DB.
-		NewSelect().
-		Model(&reservation).
-		Where("book_id = ?", params.BookID).
-		Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			reserved = false
-		} else {
-			return nil, err
+	now := time.Now()
+	var loan model.Loan
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := checkUserAndBook(ctx, tx, params.UserID, params.BookID); err != nil {
+			return err
 		}
-	}
 
-	if reserved && reservation.UserID != params.UserID {
-		return nil, ErrBookReserved
-	}
+		reservation, err := tx.Reservations().GetByBookID(ctx, params.BookID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
 
-	now := time.Now()
-	loan := model.Loan{
-		UserID:   params.UserID,
-		BookID:   params.BookID,
-		LoanDate: now,
-		DueDate:  now.Add(14 * 24 * time.Hour),
-	}
+		if reservation != nil && reservation.UserID != params.UserID {
+			return ErrBookReserved
+		}
 
-	if _, err := bs.DB.NewInsert().Model(&loan).Exec(ctx); err != nil {
-		if pgErr := (pgdriver.Error{}); errors.As(err, &pgErr) &&
-			pgErr.Field('C') == pgerrcode.UniqueViolation {
+		loan = model.Loan{
+			UserID:   params.UserID,
+			BookID:   params.BookID,
+			LoanDate: now,
+			DueDate:  now.Add(14 * 24 * time.Hour),
+		}
+		if err := tx.Loans().Create(ctx, &loan); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionBorrow, "loan", loan.ID, nil, &loan)
+	}); err != nil {
+		if errors.Is(err, store.ErrConflict) {
 			return nil, ErrBookBorrowed
 		}
 
@@ -222,9 +805,33 @@ func (bs BookService) Borrow(ctx context.Context, params BookBorrowParams) (*mod
 	return &loan, nil
 }
 
+// checkUserAndBook fails with ErrUserNotFound or ErrBookNotFound unless both
+// exist, since foreign keys don't rule out deleted ones.
+func checkUserAndBook(ctx context.Context, tx store.Store, userID, bookID int32) error {
+	if _, err := tx.Users().GetByID(ctx, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return err
+	}
+	if _, err := tx.Books().GetByID(ctx, bookID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrBookNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 type BookReturnLoanParams struct {
 	LoanID     int32
 	ReturnDate time.Time
+	// UserID, unless zero, is the only user whose loan may be returned. Loans
+	// of others are reported as not found.
+	UserID int32
 }
 
 func (bs BookService) ReturnLoan(ctx context.Context, params BookReturnLoanParams) (*model.Loan, error) {
@@ -235,24 +842,42 @@ func (bs BookService) ReturnLoan(ctx context.Context, params BookReturnLoanParam
 		}
 	}
 
-	loan := model.Loan{
-		ID:         params.LoanID,
-		ReturnDate: sql.NullTime{Time: params.ReturnDate, Valid: true},
-	}
+	var loan *model.Loan
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		var err error
+		loan, err = tx.Loans().GetByID(ctx, params.LoanID)
+		if err != nil {
+			return err
+		}
+		if params.UserID != 0 && loan.UserID != params.UserID {
+			return ErrLoanNotFound
+		}
+		if loan.ReturnDate.Valid {
+			return ErrLoanReturned
+		}
+		if startOfDay(params.ReturnDate).Before(startOfDay(loan.LoanDate)) {
+			return ValidationError{
+				Field: "return_date",
+				Err:   ErrBeforeLoanDate,
+			}
+		}
+
+		before := *loan
+		loan.ReturnDate = sql.NullTime{Time: params.ReturnDate, Valid: true}
+		if err := tx.Loans().Update(ctx, loan); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionReturn, "loan", loan.ID, &before, loan)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrLoanNotFound
+		}
 
-	if _, err := bs.DB.
-		NewUpdate().
-		Model(&loan).
-		OmitZero().
-		WherePK().
-		Exec(ctx); err != nil {
-		return nil, err
-	}
-	if err := bs.DB.NewSelect().Model(&loan).WherePK().Scan(ctx); err != nil {
 		return nil, err
 	}
 
-	return &loan, nil
+	return loan, nil
 }
 
 type BookReserveParams struct {
@@ -261,6 +886,7 @@ type BookReserveParams struct {
 }
 
 func (bs BookService) Reserve(ctx context.Context, params BookReserveParams) (*model.Reservation, error) {
+	tracing.SetUserID(ctx, params.UserID)
 	if params.UserID < 1 {
 		return nil, ValidationError{
 			Field: "user_id",
@@ -279,9 +905,17 @@ func (bs BookService) Reserve(ctx context.Context, params BookReserveParams) (*m
 		BookID: params.BookID,
 	}
 
-	if _, err := bs.DB.NewInsert().Model(&reservation).Exec(ctx); err != nil {
-		if pgErr := (pgdriver.Error{}); errors.As(err, &pgErr) &&
-			pgErr.Field('C') == pgerrcode.UniqueViolation {
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := checkUserAndBook(ctx, tx, params.UserID, params.BookID); err != nil {
+			return err
+		}
+		if err := tx.Reservations().Create(ctx, &reservation); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionReserve, "reservation", reservation.ID, nil, &reservation)
+	}); err != nil {
+		if errors.Is(err, store.ErrConflict) {
 			return nil, ErrBookReserved
 		}
 
@@ -291,7 +925,9 @@ func (bs BookService) Reserve(ctx context.Context, params BookReserveParams) (*m
 	return &reservation, nil
 }
 
-func (bs BookService) CancelReservation(ctx context.Context, id int32) error {
+// CancelReservation cancels a reservation. Unless userID is zero, it must be
+// one of that user's, and those of others are reported as not found.
+func (bs BookService) CancelReservation(ctx context.Context, userID, id int32) error {
 	if id < 1 {
 		return ValidationError{
 			Field: "id",
@@ -299,13 +935,39 @@ func (bs BookService) CancelReservation(ctx context.Context, id int32) error {
 		}
 	}
 
-	if _, err := bs.DB.
-		NewDelete().
-		Model((*model.Reservation)(nil)).
-		Where("id = ?", id).
-		Exec(ctx); err != nil {
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		reservation, err := tx.Reservations().GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if userID != 0 && reservation.UserID != userID {
+			return ErrReservationNotFound
+		}
+		if reservation.CanceledAt.Valid {
+			return ErrReservationCanceled
+		}
+
+		before := *reservation
+		reservation.CanceledAt = sql.NullTime{Time: time.Now(), Valid: true}
+		if err := tx.Reservations().Update(ctx, reservation); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionCancel, "reservation", id, &before, reservation)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrReservationNotFound
+		}
+
 		return err
 	}
 
 	return nil
 }
+
+// startOfDay truncates t to midnight in its own location, since return dates
+// carry no time of day while loan dates do.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,789 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/service"
+	"github.com/utilyre/lms/internal/store/memstore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type library struct {
+	st    *memstore.Store
+	books service.BookService
+	users service.UserService
+
+	jane, john *model.User
+	book       *model.Book
+}
+
+func newLibrary(t *testing.T) library {
+	t.Helper()
+	st := memstore.New()
+	lib := library{
+		st:    st,
+		books: service.BookService{Store: st},
+		users: service.UserService{Store: st, Hasher: password.Hasher{BcryptCost: bcrypt.MinCost}},
+	}
+
+	lib.jane = mustCreateUser(t, lib.users, "jane@example.com")
+	lib.john = mustCreateUser(t, lib.users, "john@example.com")
+	lib.book = mustCreateBook(t, lib.books)
+	return lib
+}
+
+func mustCreateBook(t *testing.T, bs service.BookService) *model.Book {
+	t.Helper()
+	book, err := bs.Create(context.Background(), service.BookCreateParams{
+		Title:   "The Go Programming Language",
+		Authors: []string{"Alan Donovan"},
+		ISBN:    "9780134190440",
+	})
+	if err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+
+	return book
+}
+
+func TestBookServiceCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  service.BookCreateParams
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			params: service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440"},
+		},
+		{
+			name:    "missing title",
+			params:  service.BookCreateParams{Authors: []string{"Author"}, ISBN: "9780134190440"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing author",
+			params:  service.BookCreateParams{Title: "Title", ISBN: "9780134190440"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing isbn",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "hyphenated isbn",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "978-0-13-419044-0"},
+			wantErr: service.ErrTooLong,
+		},
+		{
+			name:    "duplicate author",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author", " Author"}, ISBN: "9780134190440"},
+			wantErr: service.ErrDuplicate,
+		},
+		{
+			name:    "blank subject",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, Subjects: []string{" "}, ISBN: "9780134190440"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "future year",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", PublicationYear: 3000},
+			wantErr: service.ErrOutOfRange,
+		},
+		{
+			name:    "invalid language",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", Language: "English"},
+			wantErr: service.ErrInvalidLanguage,
+		},
+		{
+			name:    "unknown category",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", Categories: []int32{1}},
+			wantErr: service.ErrCategoryNotFound,
+		},
+		{
+			name:    "call number without classification",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", CallNumber: "005.133"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "unknown classification",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", Classification: "udc", CallNumber: "004.43"},
+			wantErr: callnumber.ErrUnknownScheme,
+		},
+		{
+			name:    "malformed call number",
+			params:  service.BookCreateParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440", Classification: "dewey", CallNumber: "QA76"},
+			wantErr: callnumber.ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := service.BookService{Store: memstore.New()}
+
+			book, err := bs.Create(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if book.ID < 1 {
+				t.Errorf("id = %d; want positive", book.ID)
+			}
+			if book.AvailabilityStatus != "available" {
+				t.Errorf("availability status = %q; want %q", book.AvailabilityStatus, "available")
+			}
+		})
+	}
+}
+
+func TestBookServiceList(t *testing.T) {
+	lib := newLibrary(t)
+	dune, err := lib.books.Create(context.Background(), service.BookCreateParams{
+		Title:           "Dune",
+		Authors:         []string{"Frank Herbert"},
+		Subjects:        []string{"Science fiction"},
+		ISBN:            "9780441013593",
+		PublicationYear: 1965,
+		Language:        "eng",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messiah, err := lib.books.Create(context.Background(), service.BookCreateParams{
+		Title:           "Dune Messiah",
+		Authors:         []string{"Frank Herbert"},
+		Subjects:        []string{"Science fiction"},
+		ISBN:            "9780593098233",
+		PublicationYear: 1969,
+		Language:        "eng",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messiah.Authors[0].ID != dune.Authors[0].ID {
+		t.Errorf("authors = %+v and %+v; want the same", dune.Authors, messiah.Authors)
+	}
+
+	tests := []struct {
+		name    string
+		params  service.BookListParams
+		want    []int32
+		wantErr error
+	}{
+		{name: "all", want: []int32{lib.book.ID, dune.ID, messiah.ID}},
+		{name: "by author", params: service.BookListParams{AuthorID: dune.Authors[0].ID}, want: []int32{dune.ID, messiah.ID}},
+		{name: "by subject and year", params: service.BookListParams{SubjectID: dune.Subjects[0].ID, YearFrom: 1966}, want: []int32{messiah.ID}},
+		{name: "until year", params: service.BookListParams{YearTo: 1965}, want: []int32{dune.ID}},
+		{name: "by language", params: service.BookListParams{Language: "fre"}, want: nil},
+		{name: "paged", params: service.BookListParams{AfterID: lib.book.ID, Limit: 1}, want: []int32{dune.ID}},
+		{name: "invalid author id", params: service.BookListParams{AuthorID: -1}, wantErr: service.ErrInvalidID},
+		{name: "invalid language", params: service.BookListParams{Language: "EN"}, wantErr: service.ErrInvalidLanguage},
+		{name: "limit too high", params: service.BookListParams{Limit: 501}, wantErr: service.ErrOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books, err := lib.books.List(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+
+			var ids []int32
+			for _, book := range books {
+				ids = append(ids, book.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v; want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookServiceGetByID(t *testing.T) {
+	lib := newLibrary(t)
+
+	tests := []struct {
+		name    string
+		id      int32
+		wantErr error
+	}{
+		{name: "existing", id: lib.book.ID},
+		{name: "invalid id", id: 0, wantErr: service.ErrInvalidID},
+		{name: "missing", id: lib.book.ID + 1, wantErr: service.ErrBookNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book, err := lib.books.GetByID(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && book.Title != lib.book.Title {
+				t.Errorf("title = %q; want %q", book.Title, lib.book.Title)
+			}
+		})
+	}
+}
+
+func TestBookServiceUpdateByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int32
+		params  service.BookUpdateByIDParams
+		wantErr error
+	}{
+		{
+			name: "valid",
+			id:   1,
+			params: service.BookUpdateByIDParams{
+				Title:              "Title",
+				Authors:            []string{"Author"},
+				ISBN:               "9780134190440",
+				AvailabilityStatus: "lost",
+			},
+		},
+		{
+			name: "stale version",
+			id:   1,
+			params: service.BookUpdateByIDParams{
+				Title:    "Title",
+				Authors:  []string{"Author"},
+				ISBN:     "9780134190440",
+				Versions: []int32{2},
+			},
+			wantErr: service.ErrVersionMismatch,
+		},
+		{
+			name:    "invalid id",
+			id:      0,
+			params:  service.BookUpdateByIDParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440"},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name:    "missing",
+			id:      2,
+			params:  service.BookUpdateByIDParams{Title: "Title", Authors: []string{"Author"}, ISBN: "9780134190440"},
+			wantErr: service.ErrBookNotFound,
+		},
+		{
+			name:    "missing title",
+			id:      1,
+			params:  service.BookUpdateByIDParams{Authors: []string{"Author"}, ISBN: "9780134190440"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing author",
+			id:      1,
+			params:  service.BookUpdateByIDParams{Title: "Title", ISBN: "9780134190440"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing isbn",
+			id:      1,
+			params:  service.BookUpdateByIDParams{Title: "Title", Authors: []string{"Author"}},
+			wantErr: service.ErrRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lib := newLibrary(t)
+
+			book, err := lib.books.UpdateByID(context.Background(), tt.id, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if book.Title != tt.params.Title || book.AvailabilityStatus != tt.params.AvailabilityStatus {
+				t.Errorf("book = %+v; want fields of %+v", book, tt.params)
+			}
+		})
+	}
+}
+
+func TestBookServicePatchByID(t *testing.T) {
+	set := func(v string) service.Optional[string] {
+		return service.Optional[string]{Set: true, Value: v}
+	}
+	null := service.Optional[string]{Set: true, Null: true}
+
+	tests := []struct {
+		name    string
+		id      int32
+		params  service.BookPatchByIDParams
+		want    model.Book
+		wantErr error
+	}{
+		{
+			name:   "title only",
+			id:     1,
+			params: service.BookPatchByIDParams{Title: set("Title")},
+			want: model.Book{
+				Title:              "Title",
+				Authors:            []model.Author{{ID: 1, Name: "Alan Donovan"}},
+				ISBN:               "9780134190440",
+				AvailabilityStatus: "available",
+			},
+		},
+		{
+			name:   "clear availability status",
+			id:     1,
+			params: service.BookPatchByIDParams{AvailabilityStatus: null},
+			want: model.Book{
+				Title:   "The Go Programming Language",
+				Authors: []model.Author{{ID: 1, Name: "Alan Donovan"}},
+				ISBN:    "9780134190440",
+			},
+		},
+		{
+			name: "authors and language",
+			id:   1,
+			params: service.BookPatchByIDParams{
+				Authors:  service.Optional[[]string]{Set: true, Value: []string{"Alan Donovan", "Brian Kernighan"}},
+				Language: set("eng"),
+			},
+			want: model.Book{
+				Title:              "The Go Programming Language",
+				Authors:            []model.Author{{ID: 1, Name: "Alan Donovan"}, {ID: 2, Name: "Brian Kernighan"}},
+				ISBN:               "9780134190440",
+				Language:           "eng",
+				AvailabilityStatus: "available",
+			},
+		},
+		{
+			name: "tags and call number",
+			id:   1,
+			params: service.BookPatchByIDParams{
+				Tags:           service.Optional[[]string]{Set: true, Value: []string{"Go", "programming"}},
+				Classification: set("lcc"),
+				CallNumber:     set("QA76.73.G63 D66 2015"),
+			},
+			want: model.Book{
+				Title:              "The Go Programming Language",
+				Authors:            []model.Author{{ID: 1, Name: "Alan Donovan"}},
+				ISBN:               "9780134190440",
+				Tags:               []model.Tag{{ID: 1, Name: "go"}, {ID: 2, Name: "programming"}},
+				Classification:     "lcc",
+				CallNumber:         "QA76.73.G63 D66 2015",
+				CallNumberKey:      "QA 007673 G63 D66 2015",
+				AvailabilityStatus: "available",
+			},
+		},
+		{
+			name:    "call number without classification",
+			id:      1,
+			params:  service.BookPatchByIDParams{CallNumber: set("005.133")},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "stale version",
+			id:      1,
+			params:  service.BookPatchByIDParams{Title: set("Title"), Versions: []int32{3}},
+			wantErr: service.ErrVersionMismatch,
+		},
+		{
+			name:    "null author",
+			id:      1,
+			params:  service.BookPatchByIDParams{Authors: service.Optional[[]string]{Set: true, Null: true}},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "empty isbn",
+			id:      1,
+			params:  service.BookPatchByIDParams{ISBN: set("")},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing",
+			id:      2,
+			params:  service.BookPatchByIDParams{Title: set("Title")},
+			wantErr: service.ErrBookNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lib := newLibrary(t)
+
+			book, err := lib.books.PatchByID(context.Background(), tt.id, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			tt.want.ID = tt.id
+			tt.want.Version = 2
+			if !reflect.DeepEqual(*book, tt.want) {
+				t.Errorf("book = %+v; want %+v", *book, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookServiceDeleteByID(t *testing.T) {
+	lib := newLibrary(t)
+
+	if err := lib.books.DeleteByID(context.Background(), 0); !errors.Is(err, service.ErrInvalidID) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidID)
+	}
+
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID, 2); !errors.Is(err, service.ErrVersionMismatch) {
+		t.Fatalf("err = %v; want %v", err, service.ErrVersionMismatch)
+	}
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.books.GetByID(context.Background(), lib.book.ID); !errors.Is(err, service.ErrBookNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrBookNotFound)
+	}
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID); !errors.Is(err, service.ErrBookNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrBookNotFound)
+	}
+}
+
+func TestBookServiceDeleteByIDKeepsLoans(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+
+	if _, err := lib.books.ReturnLoan(context.Background(), service.BookReturnLoanParams{
+		LoanID:     loan.ID,
+		ReturnDate: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.st.Loans().GetByID(context.Background(), loan.ID); err != nil {
+		t.Fatalf("loan of deleted book: %v", err)
+	}
+	if _, err := lib.books.Borrow(context.Background(), service.BookBorrowParams{
+		UserID: lib.john.ID,
+		BookID: lib.book.ID,
+	}); !errors.Is(err, service.ErrBookNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrBookNotFound)
+	}
+}
+
+func TestBookServiceDeleteByIDInUse(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+	reservation := mustReserve(t, lib, lib.john)
+
+	var iue service.InUseError
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID); !errors.As(err, &iue) {
+		t.Fatalf("err = %v; want InUseError", err)
+	}
+	if !slices.Equal(iue.LoanIDs, []int32{loan.ID}) || !slices.Equal(iue.ReservationIDs, []int32{reservation.ID}) {
+		t.Errorf("err = %+v; want loan %d and reservation %d", iue, loan.ID, reservation.ID)
+	}
+
+	if err := lib.books.ForceDeleteByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loan, err := lib.st.Loans().GetByID(context.Background(), loan.ID); err != nil || !loan.ReturnDate.Valid {
+		t.Errorf("loan = %+v, %v; want it returned", loan, err)
+	}
+	if reservation, err := lib.st.Reservations().GetByID(context.Background(), reservation.ID); err != nil || !reservation.CanceledAt.Valid {
+		t.Errorf("reservation = %+v, %v; want it canceled", reservation, err)
+	}
+}
+
+func TestBookServiceRestoreByID(t *testing.T) {
+	lib := newLibrary(t)
+
+	if _, err := lib.books.RestoreByID(context.Background(), lib.book.ID); !errors.Is(err, service.ErrNotDeleted) {
+		t.Fatalf("err = %v; want %v", err, service.ErrNotDeleted)
+	}
+	if err := lib.books.DeleteByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := lib.books.RestoreByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != lib.book.Title || book.Version != 2 {
+		t.Errorf("book = %+v; want %q at version 2", book, lib.book.Title)
+	}
+	if _, err := lib.books.GetByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBookServicePurgeByID(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+
+	if err := lib.books.PurgeByID(context.Background(), lib.book.ID); !errors.Is(err, service.ErrNotDeleted) {
+		t.Fatalf("err = %v; want %v", err, service.ErrNotDeleted)
+	}
+	if err := lib.books.ForceDeleteByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.books.PurgeByID(context.Background(), lib.book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.books.ReturnLoan(context.Background(), service.BookReturnLoanParams{
+		LoanID:     loan.ID,
+		ReturnDate: time.Now(),
+	}); !errors.Is(err, service.ErrLoanNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrLoanNotFound)
+	}
+	if _, err := lib.books.RestoreByID(context.Background(), lib.book.ID); !errors.Is(err, service.ErrBookNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrBookNotFound)
+	}
+}
+
+func TestBookServiceBorrow(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, lib library)
+		params  func(lib library) service.BookBorrowParams
+		wantErr error
+	}{
+		{
+			name: "available",
+			params: func(lib library) service.BookBorrowParams {
+				return service.BookBorrowParams{UserID: lib.jane.ID, BookID: lib.book.ID}
+			},
+		},
+		{
+			name: "invalid user id",
+			params: func(lib library) service.BookBorrowParams {
+				return service.BookBorrowParams{UserID: 0, BookID: lib.book.ID}
+			},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name: "invalid book id",
+			params: func(lib library) service.BookBorrowParams {
+				return service.BookBorrowParams{UserID: lib.jane.ID, BookID: 0}
+			},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name: "reserved by borrower",
+			setup: func(t *testing.T, lib library) {
+				mustReserve(t, lib, lib.jane)
+			},
+			params: func(lib library) service.BookBorrowParams {
+				return service.BookBorrowParams{UserID: lib.jane.ID, BookID: lib.book.ID}
+			},
+		},
+		{
+			name: "reserved by someone else",
+			setup: func(t *testing.T, lib library) {
+				mustReserve(t, lib, lib.john)
+			},
+			params: func(lib library) service.BookBorrowParams {
+				return service.BookBorrowParams{UserID: lib.jane.ID, BookID: lib.book.ID}
+			},
+			wantErr: service.ErrBookReserved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lib := newLibrary(t)
+			if tt.setup != nil {
+				tt.setup(t, lib)
+			}
+
+			params := tt.params(lib)
+			loan, err := lib.books.Borrow(context.Background(), params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if loan.UserID != params.UserID || loan.BookID != params.BookID {
+				t.Errorf("loan = %+v; want user %d and book %d", loan, params.UserID, params.BookID)
+			}
+			if got := loan.DueDate.Sub(loan.LoanDate); got != 14*24*time.Hour {
+				t.Errorf("loan period = %v; want 14 days", got)
+			}
+		})
+	}
+}
+
+func mustReserve(t *testing.T, lib library, user *model.User) *model.Reservation {
+	t.Helper()
+	reservation, err := lib.books.Reserve(context.Background(), service.BookReserveParams{
+		UserID: user.ID,
+		BookID: lib.book.ID,
+	})
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	return reservation
+}
+
+func mustBorrow(t *testing.T, lib library, user *model.User) *model.Loan {
+	t.Helper()
+	loan, err := lib.books.Borrow(context.Background(), service.BookBorrowParams{
+		UserID: user.ID,
+		BookID: lib.book.ID,
+	})
+	if err != nil {
+		t.Fatalf("borrow: %v", err)
+	}
+
+	return loan
+}
+
+func TestBookServiceReturnLoan(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+	year, month, day := loan.LoanDate.Date()
+	returnDate := time.Date(year, month, day+3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		params  service.BookReturnLoanParams
+		wantErr error
+	}{
+		{
+			name:    "invalid id",
+			params:  service.BookReturnLoanParams{LoanID: 0, ReturnDate: returnDate},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name:    "missing",
+			params:  service.BookReturnLoanParams{LoanID: loan.ID + 1, ReturnDate: returnDate},
+			wantErr: service.ErrLoanNotFound,
+		},
+		{
+			name:    "another user's",
+			params:  service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: returnDate, UserID: lib.john.ID},
+			wantErr: service.ErrLoanNotFound,
+		},
+		{
+			name:    "before loan date",
+			params:  service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: returnDate.AddDate(0, 0, -4)},
+			wantErr: service.ErrBeforeLoanDate,
+		},
+		{
+			name:   "valid",
+			params: service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: returnDate, UserID: lib.jane.ID},
+		},
+		{
+			name:    "already returned",
+			params:  service.BookReturnLoanParams{LoanID: loan.ID, ReturnDate: returnDate},
+			wantErr: service.ErrLoanReturned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			returned, err := lib.books.ReturnLoan(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !returned.ReturnDate.Valid || !returned.ReturnDate.Time.Equal(returnDate) {
+				t.Errorf("return date = %v; want %v", returned.ReturnDate, returnDate)
+			}
+			if returned.UserID != loan.UserID || returned.BookID != loan.BookID {
+				t.Errorf("loan = %+v; want it reloaded from %+v", returned, loan)
+			}
+		})
+	}
+}
+
+func TestBookServiceReserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  func(lib library) service.BookReserveParams
+		wantErr error
+	}{
+		{
+			name: "valid",
+			params: func(lib library) service.BookReserveParams {
+				return service.BookReserveParams{UserID: lib.jane.ID, BookID: lib.book.ID}
+			},
+		},
+		{
+			name: "invalid user id",
+			params: func(lib library) service.BookReserveParams {
+				return service.BookReserveParams{UserID: 0, BookID: lib.book.ID}
+			},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name: "invalid book id",
+			params: func(lib library) service.BookReserveParams {
+				return service.BookReserveParams{UserID: lib.jane.ID, BookID: -1}
+			},
+			wantErr: service.ErrInvalidID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lib := newLibrary(t)
+
+			params := tt.params(lib)
+			reservation, err := lib.books.Reserve(context.Background(), params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if reservation.UserID != params.UserID || reservation.BookID != params.BookID {
+				t.Errorf("reservation = %+v; want user %d and book %d", reservation, params.UserID, params.BookID)
+			}
+		})
+	}
+}
+
+func TestBookServiceCancelReservation(t *testing.T) {
+	lib := newLibrary(t)
+	reservation := mustReserve(t, lib, lib.john)
+
+	if err := lib.books.CancelReservation(context.Background(), 0, 0); !errors.Is(err, service.ErrInvalidID) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidID)
+	}
+
+	if err := lib.books.CancelReservation(context.Background(), 0, reservation.ID+1); !errors.Is(err, service.ErrReservationNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrReservationNotFound)
+	}
+
+	if err := lib.books.CancelReservation(context.Background(), lib.jane.ID, reservation.ID); !errors.Is(err, service.ErrReservationNotFound) {
+		t.Fatalf("another user's: err = %v; want %v", err, service.ErrReservationNotFound)
+	}
+	if err := lib.books.CancelReservation(context.Background(), lib.john.ID, reservation.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.books.CancelReservation(context.Background(), 0, reservation.ID); !errors.Is(err, service.ErrReservationCanceled) {
+		t.Fatalf("err = %v; want %v", err, service.ErrReservationCanceled)
+	}
+
+	// With the reservation gone, anyone may borrow the book.
+	mustBorrow(t, lib, lib.jane)
+}
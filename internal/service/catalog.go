@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+var (
+	ErrAuthorNotFound    = errors.New("author not found")
+	ErrPublisherNotFound = errors.New("publisher not found")
+	ErrSubjectNotFound   = errors.New("subject not found")
+	ErrTagNotFound       = errors.New("tag not found")
+)
+
+type BookListParams struct {
+	AuthorID    int32
+	PublisherID int32
+	SubjectID   int32
+	// CategoryID lists the books filed under the category or any of its
+	// descendants.
+	CategoryID int32
+	TagID      int32
+	Language   string
+	// YearFrom and YearTo bound the publication year, inclusively. Books of
+	// unknown year are left out when either is set.
+	YearFrom int32
+	YearTo   int32
+	AfterID  int32
+	// Limit is how many books to list at most, up to 500. Zero means 50.
+	Limit int
+}
+
+// List lists the books matching params, oldest first.
+func (bs BookService) List(ctx context.Context, params BookListParams) ([]model.Book, error) {
+	ids := []struct {
+		field string
+		id    int32
+	}{
+		{field: "author_id", id: params.AuthorID},
+		{field: "publisher_id", id: params.PublisherID},
+		{field: "subject_id", id: params.SubjectID},
+		{field: "category_id", id: params.CategoryID},
+		{field: "tag_id", id: params.TagID},
+		{field: "after_id", id: params.AfterID},
+	}
+	for _, id := range ids {
+		if id.id < 0 {
+			return nil, ValidationError{
+				Field: id.field,
+				Err:   ErrInvalidID,
+			}
+		}
+	}
+	if err := validateLanguage(params.Language); err != nil {
+		return nil, err
+	}
+	if params.YearFrom < 0 {
+		return nil, ValidationError{
+			Field: "year_from",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if params.YearTo < 0 {
+		return nil, ValidationError{
+			Field: "year_to",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if params.Limit < 0 || params.Limit > 500 {
+		return nil, ValidationError{
+			Field: "limit",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if params.Limit == 0 {
+		params.Limit = 50
+	}
+
+	return bs.Store.Books().List(ctx, store.BookFilter(params))
+}
+
+type NameListParams struct {
+	// Prefix only lists names starting with it, regardless of case.
+	Prefix string
+	// After only lists names that sort after it, for paging.
+	After string
+	// Limit is how many to list at most, up to 500. Zero means 50.
+	Limit int
+}
+
+func (nlp NameListParams) filter() (store.NameFilter, error) {
+	if nlp.Limit < 0 || nlp.Limit > 500 {
+		return store.NameFilter{}, ValidationError{
+			Field: "limit",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if nlp.Limit == 0 {
+		nlp.Limit = 50
+	}
+
+	return store.NameFilter(nlp), nil
+}
+
+func (bs BookService) GetAuthorByID(ctx context.Context, id int32) (*model.Author, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	author, err := bs.Store.Authors().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrAuthorNotFound
+		}
+
+		return nil, err
+	}
+
+	return author, nil
+}
+
+// ListAuthors lists the authors matching params by name.
+func (bs BookService) ListAuthors(ctx context.Context, params NameListParams) ([]model.Author, error) {
+	filter, err := params.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.Store.Authors().List(ctx, filter)
+}
+
+func (bs BookService) GetPublisherByID(ctx context.Context, id int32) (*model.Publisher, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	publisher, err := bs.Store.Publishers().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrPublisherNotFound
+		}
+
+		return nil, err
+	}
+
+	return publisher, nil
+}
+
+func (bs BookService) ListPublishers(ctx context.Context, params NameListParams) ([]model.Publisher, error) {
+	filter, err := params.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.Store.Publishers().List(ctx, filter)
+}
+
+func (bs BookService) GetSubjectByID(ctx context.Context, id int32) (*model.Subject, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	subject, err := bs.Store.Subjects().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrSubjectNotFound
+		}
+
+		return nil, err
+	}
+
+	return subject, nil
+}
+
+func (bs BookService) ListSubjects(ctx context.Context, params NameListParams) ([]model.Subject, error) {
+	filter, err := params.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.Store.Subjects().List(ctx, filter)
+}
+
+func (bs BookService) GetTagByID(ctx context.Context, id int32) (*model.Tag, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	tag, err := bs.Store.Tags().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrTagNotFound
+		}
+
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (bs BookService) ListTags(ctx context.Context, params NameListParams) ([]model.Tag, error) {
+	filter, err := params.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.Store.Tags().List(ctx, filter)
+}
+
+// linkBooks replaces the authors, publishers, subjects and tags of books,
+// which are only named, with ones that exist, creating those that don't. It
+// looks up every name once, however many books share it. Categories, which
+// are identified by ID, are looked up but never created.
+func linkBooks(ctx context.Context, tx store.Store, books ...*model.Book) error {
+	var (
+		authors, publishers, subjects, tags []string
+		seen                                = make(map[[2]string]bool)
+		categorized                         bool
+	)
+	for _, book := range books {
+		authors = appendNew(authors, seen, "author", authorNames(book.Authors))
+		publishers = appendNew(publishers, seen, "publisher", publisherNames(book.Publishers))
+		subjects = appendNew(subjects, seen, "subject", subjectNames(book.Subjects))
+		tags = appendNew(tags, seen, "tag", tagNames(book.Tags))
+		categorized = categorized || len(book.Categories) > 0
+	}
+
+	authorsByName := make(map[string]model.Author, len(authors))
+	if len(authors) > 0 {
+		rows, err := tx.Authors().Ensure(ctx, authors)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			authorsByName[row.Name] = row
+		}
+	}
+	publishersByName := make(map[string]model.Publisher, len(publishers))
+	if len(publishers) > 0 {
+		rows, err := tx.Publishers().Ensure(ctx, publishers)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			publishersByName[row.Name] = row
+		}
+	}
+	subjectsByName := make(map[string]model.Subject, len(subjects))
+	if len(subjects) > 0 {
+		rows, err := tx.Subjects().Ensure(ctx, subjects)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			subjectsByName[row.Name] = row
+		}
+	}
+
+	tagsByName := make(map[string]model.Tag, len(tags))
+	if len(tags) > 0 {
+		rows, err := tx.Tags().Ensure(ctx, tags)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			tagsByName[row.Name] = row
+		}
+	}
+	categoriesByID := make(map[int32]model.Category)
+	if categorized {
+		rows, err := tx.Categories().List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			categoriesByID[row.ID] = row
+		}
+	}
+
+	for _, book := range books {
+		for i, author := range book.Authors {
+			book.Authors[i] = authorsByName[author.Name]
+		}
+		for i, publisher := range book.Publishers {
+			book.Publishers[i] = publishersByName[publisher.Name]
+		}
+		for i, subject := range book.Subjects {
+			book.Subjects[i] = subjectsByName[subject.Name]
+		}
+		for i, tag := range book.Tags {
+			book.Tags[i] = tagsByName[tag.Name]
+		}
+		for i, category := range book.Categories {
+			row, ok := categoriesByID[category.ID]
+			if !ok {
+				return ValidationError{
+					Field: "categories",
+					Err:   fmt.Errorf("%w: %d", ErrCategoryNotFound, category.ID),
+				}
+			}
+			book.Categories[i] = row
+		}
+	}
+
+	return nil
+}
+
+// appendNew appends the names of the kind to s that aren't in seen yet.
+func appendNew(s []string, seen map[[2]string]bool, kind string, names []string) []string {
+	for _, name := range names {
+		if key := [2]string{kind, name}; !seen[key] {
+			seen[key] = true
+			s = append(s, name)
+		}
+	}
+
+	return s
+}
+
+func newAuthors(names []string) []model.Author {
+	var authors []model.Author
+	for _, name := range names {
+		authors = append(authors, model.Author{Name: strings.TrimSpace(name)})
+	}
+
+	return authors
+}
+
+func newPublishers(names []string) []model.Publisher {
+	var publishers []model.Publisher
+	for _, name := range names {
+		publishers = append(publishers, model.Publisher{Name: strings.TrimSpace(name)})
+	}
+
+	return publishers
+}
+
+func newSubjects(names []string) []model.Subject {
+	var subjects []model.Subject
+	for _, name := range names {
+		subjects = append(subjects, model.Subject{Name: strings.TrimSpace(name)})
+	}
+
+	return subjects
+}
+
+// newTags trims and lowercases the names of tags, which are free-form and
+// thus matched regardless of case.
+func newTags(names []string) []model.Tag {
+	var tags []model.Tag
+	for _, name := range names {
+		tags = append(tags, model.Tag{Name: strings.ToLower(strings.TrimSpace(name))})
+	}
+
+	return tags
+}
+
+func newCategories(ids []int32) []model.Category {
+	var categories []model.Category
+	for _, id := range ids {
+		categories = append(categories, model.Category{ID: id})
+	}
+
+	return categories
+}
+
+func authorNames(authors []model.Author) []string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		names = append(names, author.Name)
+	}
+
+	return names
+}
+
+func publisherNames(publishers []model.Publisher) []string {
+	names := make([]string, 0, len(publishers))
+	for _, publisher := range publishers {
+		names = append(names, publisher.Name)
+	}
+
+	return names
+}
+
+func subjectNames(subjects []model.Subject) []string {
+	names := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		names = append(names, subject.Name)
+	}
+
+	return names
+}
+
+func tagNames(tags []model.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+
+	return names
+}
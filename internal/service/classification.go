@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryDup      = errors.New("category duplication")
+	ErrCategoryNotEmpty = errors.New("category has subcategories")
+)
+
+// CategoryNode is a category along with its descendants. Ancestors lists
+// the categories above it from the root down, and is only filled in for the
+// category asked for by GetCategoryByID.
+type CategoryNode struct {
+	model.Category
+	Ancestors []model.Category
+	Children  []CategoryNode
+}
+
+type CategoryCreateParams struct {
+	Name string
+	// ParentID is the category to create the category under, or zero to
+	// create it at the root.
+	ParentID int32
+}
+
+// CreateCategory creates a category, whose name must be unique among those
+// of its siblings.
+func (bs BookService) CreateCategory(ctx context.Context, params CategoryCreateParams) (*model.Category, error) {
+	category := model.Category{
+		ParentID: params.ParentID,
+		Name:     strings.TrimSpace(params.Name),
+	}
+	if err := validateBookField("name", category.Name); err != nil {
+		return nil, err
+	}
+	if category.ParentID < 0 {
+		return nil, ValidationError{
+			Field: "parent_id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := tx.Categories().Create(ctx, &category); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionCreate, "category", category.ID, nil, &category)
+	}); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return nil, ErrCategoryDup
+		}
+		if errors.Is(err, store.ErrInvalidReference) {
+			return nil, ValidationError{
+				Field: "parent_id",
+				Err:   ErrCategoryNotFound,
+			}
+		}
+
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// GetCategoryTree returns the root categories with their descendants, every
+// level ordered by name.
+func (bs BookService) GetCategoryTree(ctx context.Context) ([]CategoryNode, error) {
+	categories, err := bs.Store.Categories().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return categoryChildren(categories, 0), nil
+}
+
+// GetCategoryByID returns the category with its ancestors and descendants.
+func (bs BookService) GetCategoryByID(ctx context.Context, id int32) (*CategoryNode, error) {
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	categories, err := bs.Store.Categories().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int32]model.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	category, ok := byID[id]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+	node := CategoryNode{
+		Category:  category,
+		Ancestors: []model.Category{},
+		Children:  categoryChildren(categories, id),
+	}
+	for parentID := category.ParentID; parentID != 0; parentID = byID[parentID].ParentID {
+		node.Ancestors = append(node.Ancestors, byID[parentID])
+	}
+	slices.Reverse(node.Ancestors)
+
+	return &node, nil
+}
+
+// categoryChildren builds the subtrees of the children of the category with
+// parentID out of categories, which are ordered by name.
+func categoryChildren(categories []model.Category, parentID int32) []CategoryNode {
+	children := []CategoryNode{}
+	for _, category := range categories {
+		if category.ParentID == parentID {
+			children = append(children, CategoryNode{
+				Category: category,
+				Children: categoryChildren(categories, category.ID),
+			})
+		}
+	}
+
+	return children
+}
+
+// DeleteCategoryByID deletes the category and unfiles its books. Categories
+// with subcategories aren't deleted, failing with ErrCategoryNotEmpty.
+func (bs BookService) DeleteCategoryByID(ctx context.Context, id int32) error {
+	if id < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := tx.Categories().GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Categories().DeleteByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionDelete, "category", id, before, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrCategoryNotFound
+		}
+		if errors.Is(err, store.ErrInvalidReference) {
+			return ErrCategoryNotEmpty
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+type BookShelfParams struct {
+	Classification string
+	// From and To bound the call numbers, inclusively. To includes the call
+	// numbers it's a prefix of, so that 599 includes 599.75 and QA76
+	// includes QA76.73.G63.
+	From string
+	To   string
+	// AfterID lists the books shelved after the one it identifies, for
+	// paging.
+	AfterID int32
+	// Limit is how many books to list at most, up to 500. Zero means 50.
+	Limit int
+}
+
+// ListShelf lists the books with call numbers in the classification and
+// range given by params, in shelf order.
+func (bs BookService) ListShelf(ctx context.Context, params BookShelfParams) ([]model.Book, error) {
+	scheme := callnumber.Scheme(params.Classification)
+	if scheme == "" {
+		return nil, ValidationError{
+			Field: "classification",
+			Err:   ErrRequired,
+		}
+	}
+	if scheme != callnumber.Dewey && scheme != callnumber.LCC {
+		return nil, ValidationError{
+			Field: "classification",
+			Err:   callnumber.ErrUnknownScheme,
+		}
+	}
+	if params.AfterID < 0 {
+		return nil, ValidationError{
+			Field: "after_id",
+			Err:   ErrInvalidID,
+		}
+	}
+	if params.Limit < 0 || params.Limit > 500 {
+		return nil, ValidationError{
+			Field: "limit",
+			Err:   ErrOutOfRange,
+		}
+	}
+	if params.Limit == 0 {
+		params.Limit = 50
+	}
+
+	filter := store.ShelfFilter{
+		Classification: params.Classification,
+		AfterID:        params.AfterID,
+		Limit:          params.Limit,
+	}
+	if params.From != "" {
+		key, err := callnumber.Key(scheme, params.From)
+		if err != nil {
+			return nil, ValidationError{
+				Field: "from",
+				Err:   err,
+			}
+		}
+		filter.From = key
+	}
+	if params.To != "" {
+		key, err := callnumber.Key(scheme, params.To)
+		if err != nil {
+			return nil, ValidationError{
+				Field: "to",
+				Err:   err,
+			}
+		}
+		filter.Before = callnumber.UpperBound(key)
+	}
+
+	return bs.Store.Books().ListShelf(ctx, filter)
+}
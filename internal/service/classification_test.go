@@ -0,0 +1,236 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func mustCreateCategory(t *testing.T, bs service.BookService, name string, parentID int32) *model.Category {
+	t.Helper()
+	category, err := bs.CreateCategory(context.Background(), service.CategoryCreateParams{
+		Name:     name,
+		ParentID: parentID,
+	})
+	if err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	return category
+}
+
+// categoryNames flattens a tree into the names of its categories, each
+// followed by those of its descendants.
+func categoryNames(nodes []service.CategoryNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+		names = append(names, categoryNames(node.Children)...)
+	}
+
+	return names
+}
+
+func TestBookServiceCreateCategory(t *testing.T) {
+	lib := newLibrary(t)
+	science := mustCreateCategory(t, lib.books, "Science", 0)
+
+	tests := []struct {
+		name    string
+		params  service.CategoryCreateParams
+		wantErr error
+	}{
+		{name: "same name elsewhere", params: service.CategoryCreateParams{Name: "Science", ParentID: science.ID}},
+		{name: "sibling name", params: service.CategoryCreateParams{Name: " Science "}, wantErr: service.ErrCategoryDup},
+		{name: "missing name", params: service.CategoryCreateParams{Name: " "}, wantErr: service.ErrRequired},
+		{name: "unknown parent", params: service.CategoryCreateParams{Name: "Physics", ParentID: 99}, wantErr: service.ErrCategoryNotFound},
+		{name: "invalid parent", params: service.CategoryCreateParams{Name: "Physics", ParentID: -1}, wantErr: service.ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := lib.books.CreateCategory(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBookServiceGetCategoryTree(t *testing.T) {
+	lib := newLibrary(t)
+	science := mustCreateCategory(t, lib.books, "Science", 0)
+	computing := mustCreateCategory(t, lib.books, "Computing", science.ID)
+	mustCreateCategory(t, lib.books, "Astronomy", science.ID)
+	mustCreateCategory(t, lib.books, "Languages", computing.ID)
+	mustCreateCategory(t, lib.books, "Fiction", 0)
+
+	tree, err := lib.books.GetCategoryTree(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Fiction", "Science", "Astronomy", "Computing", "Languages"}
+	if got := categoryNames(tree); !slices.Equal(got, want) {
+		t.Errorf("tree = %q; want %q", got, want)
+	}
+
+	node, err := lib.books.GetCategoryByID(context.Background(), computing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Ancestors) != 1 || node.Ancestors[0].ID != science.ID {
+		t.Errorf("ancestors = %+v; want science", node.Ancestors)
+	}
+	if got := categoryNames(node.Children); !slices.Equal(got, []string{"Languages"}) {
+		t.Errorf("children = %q; want languages", got)
+	}
+
+	if _, err := lib.books.GetCategoryByID(context.Background(), 99); !errors.Is(err, service.ErrCategoryNotFound) {
+		t.Errorf("err = %v; want %v", err, service.ErrCategoryNotFound)
+	}
+}
+
+func TestBookServiceDeleteCategoryByID(t *testing.T) {
+	lib := newLibrary(t)
+	science := mustCreateCategory(t, lib.books, "Science", 0)
+	computing := mustCreateCategory(t, lib.books, "Computing", science.ID)
+	if _, err := lib.books.PatchByID(context.Background(), lib.book.ID, service.BookPatchByIDParams{
+		Categories: service.Optional[[]int32]{Set: true, Value: []int32{computing.ID}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.books.DeleteCategoryByID(context.Background(), science.ID); !errors.Is(err, service.ErrCategoryNotEmpty) {
+		t.Fatalf("err = %v; want %v", err, service.ErrCategoryNotEmpty)
+	}
+	if err := lib.books.DeleteCategoryByID(context.Background(), computing.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.books.DeleteCategoryByID(context.Background(), computing.ID); !errors.Is(err, service.ErrCategoryNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrCategoryNotFound)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Categories) != 0 {
+		t.Errorf("categories = %+v; want none", book.Categories)
+	}
+}
+
+func TestBookServiceListByClassification(t *testing.T) {
+	lib := newLibrary(t)
+	science := mustCreateCategory(t, lib.books, "Science", 0)
+	computing := mustCreateCategory(t, lib.books, "Computing", science.ID)
+	astronomy := mustCreateCategory(t, lib.books, "Astronomy", science.ID)
+	if _, err := lib.books.PatchByID(context.Background(), lib.book.ID, service.BookPatchByIDParams{
+		Categories: service.Optional[[]int32]{Set: true, Value: []int32{computing.ID}},
+		Tags:       service.Optional[[]string]{Set: true, Value: []string{"go"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cosmos, err := lib.books.Create(context.Background(), service.BookCreateParams{
+		Title:      "Cosmos",
+		Authors:    []string{"Carl Sagan"},
+		ISBN:       "9780345539434",
+		Categories: []int32{astronomy.ID},
+		Tags:       []string{"Classics"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		params  service.BookListParams
+		want    []int32
+		wantErr error
+	}{
+		{name: "by category", params: service.BookListParams{CategoryID: computing.ID}, want: []int32{lib.book.ID}},
+		{name: "by ancestor", params: service.BookListParams{CategoryID: science.ID}, want: []int32{lib.book.ID, cosmos.ID}},
+		{name: "by tag", params: service.BookListParams{TagID: cosmos.Tags[0].ID}, want: []int32{cosmos.ID}},
+		{name: "by category and tag", params: service.BookListParams{CategoryID: computing.ID, TagID: cosmos.Tags[0].ID}, want: nil},
+		{name: "invalid category id", params: service.BookListParams{CategoryID: -1}, wantErr: service.ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books, err := lib.books.List(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+
+			var ids []int32
+			for _, book := range books {
+				ids = append(ids, book.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v; want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookServiceListShelf(t *testing.T) {
+	lib := newLibrary(t)
+	shelve := func(title, isbn, classification, callNumber string) int32 {
+		t.Helper()
+		book, err := lib.books.Create(context.Background(), service.BookCreateParams{
+			Title:          title,
+			Authors:        []string{"Author"},
+			ISBN:           isbn,
+			Classification: classification,
+			CallNumber:     callNumber,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return book.ID
+	}
+	gopl := shelve("The Go Programming Language", "9780134190441", "lcc", "QA76.73.G63 D66 2015")
+	kr := shelve("The C Programming Language", "9780131103627", "lcc", "QA76.73.C15 K47 1988")
+	knuth := shelve("The Art of Computer Programming", "9780201896831", "lcc", "QA76.6 .K64")
+	dune := shelve("Dune", "9780441013593", "lcc", "PS3558.E63 D8 1965")
+	shelve("Cosmos", "9780345539434", "dewey", "520 SAG")
+
+	tests := []struct {
+		name    string
+		params  service.BookShelfParams
+		want    []int32
+		wantErr error
+	}{
+		{name: "all", params: service.BookShelfParams{Classification: "lcc"}, want: []int32{dune, knuth, kr, gopl}},
+		{name: "class", params: service.BookShelfParams{Classification: "lcc", From: "QA76.73", To: "QA76.73"}, want: []int32{kr, gopl}},
+		{name: "from", params: service.BookShelfParams{Classification: "lcc", From: "QA76.73.D"}, want: []int32{gopl}},
+		{name: "to", params: service.BookShelfParams{Classification: "lcc", To: "QA76.6"}, want: []int32{dune, knuth}},
+		{name: "paged", params: service.BookShelfParams{Classification: "lcc", AfterID: knuth, Limit: 1}, want: []int32{kr}},
+		{name: "missing classification", wantErr: service.ErrRequired},
+		{name: "unknown classification", params: service.BookShelfParams{Classification: "udc"}, wantErr: callnumber.ErrUnknownScheme},
+		{name: "malformed bound", params: service.BookShelfParams{Classification: "dewey", From: "QA76"}, wantErr: callnumber.ErrMalformed},
+		{name: "limit too high", params: service.BookShelfParams{Classification: "lcc", Limit: 501}, wantErr: service.ErrOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books, err := lib.books.ListShelf(context.Background(), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+
+			var ids []int32
+			for _, book := range books {
+				ids = append(ids, book.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v; want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+// InUseError reports the open loans and active reservations that keep a user
+// or book from being deleted.
+type InUseError struct {
+	LoanIDs        []int32
+	ReservationIDs []int32
+}
+
+func (iue InUseError) Error() string {
+	return fmt.Sprintf("%d open loans and %d active reservations", len(iue.LoanIDs), len(iue.ReservationIDs))
+}
+
+// closeOut fails with InUseError when there are any loans or reservations,
+// unless force is set, in which case it returns the loans and cancels the
+// reservations as of now instead.
+func closeOut(ctx context.Context, tx store.Store, loans []model.Loan, reservations []model.Reservation, force bool) error {
+	if len(loans) == 0 && len(reservations) == 0 {
+		return nil
+	}
+
+	if !force {
+		iue := InUseError{
+			LoanIDs:        make([]int32, 0, len(loans)),
+			ReservationIDs: make([]int32, 0, len(reservations)),
+		}
+		for _, loan := range loans {
+			iue.LoanIDs = append(iue.LoanIDs, loan.ID)
+		}
+		for _, reservation := range reservations {
+			iue.ReservationIDs = append(iue.ReservationIDs, reservation.ID)
+		}
+
+		return iue
+	}
+
+	now := time.Now()
+	for _, loan := range loans {
+		before := loan
+		loan.ReturnDate = sql.NullTime{Time: now, Valid: true}
+		if err := tx.Loans().Update(ctx, &loan); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, audit.ActionReturn, "loan", loan.ID, &before, &loan); err != nil {
+			return err
+		}
+	}
+	for _, reservation := range reservations {
+		before := reservation
+		reservation.CanceledAt = sql.NullTime{Time: now, Valid: true}
+		if err := tx.Reservations().Update(ctx, &reservation); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, tx, audit.ActionCancel, "reservation", reservation.ID, &before, &reservation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
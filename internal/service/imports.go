@@ -0,0 +1,469 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+const (
+	ImportFormatCSV     = "csv"
+	ImportFormatMARC    = "marc"
+	ImportFormatMARCXML = "marcxml"
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid format")
+	ErrMissingColumn = errors.New("missing column")
+	ErrDuplicate     = errors.New("duplicate")
+	ErrAmbiguousISBN = errors.New("matches more than one book")
+	ErrNotANumber    = errors.New("not a number")
+)
+
+// BookImportRowError is why a row of an import was skipped. Row is the line
+// a CSV row starts on, counting the header as line 1, or the position of a
+// MARC record, counting from 1.
+type BookImportRowError struct {
+	Row int
+	Err error
+}
+
+func (bire BookImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", bire.Row, bire.Err)
+}
+
+func (bire BookImportRowError) Unwrap() error {
+	return bire.Err
+}
+
+// BookImportReport counts what an import did, or would have done on a dry
+// run, to the books of the catalogue.
+type BookImportReport struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	// Errors lists the skipped rows in order.
+	Errors []BookImportRowError
+}
+
+type BookImportParams struct {
+	// Format is one of the ImportFormat constants, CSV when empty.
+	Format string
+	// Data holds the books in Format. A CSV starts with a header naming its
+	// columns, of which title, author and isbn are required and publisher,
+	// subject, tag, publication_year, edition, language, page_count,
+	// classification, call_number and availability_status are optional. The
+	// author, publisher, subject and tag columns hold names separated by
+	// semicolons. Columns are matched
+	// regardless of case and order, and unknown ones are ignored. MARC
+	// records are mapped to books as described by bookFromMARC.
+	Data io.Reader
+	// DryRun reports what the import would do without changing anything.
+	DryRun bool
+}
+
+type importRow struct {
+	line int
+	book model.Book
+}
+
+// ImportFormatOf guesses the import format of a file from its name.
+func ImportFormatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mrc", ".marc":
+		return ImportFormatMARC
+	case ".xml":
+		return ImportFormatMARCXML
+	default:
+		return ImportFormatCSV
+	}
+}
+
+// Import upserts a book for each row of the data by its ISBN: rows whose
+// ISBN no book has yet are created, whereas the books that have it are
+// updated, leaving their optional fields alone unless the row has them.
+// Rows that fail validation, repeat the ISBN of an earlier row or match
+// several books are skipped and reported, while the rest are imported in a
+// single transaction.
+func (bs BookService) Import(ctx context.Context, params BookImportParams) (*BookImportReport, error) {
+	var (
+		rows    []importRow
+		rowErrs []BookImportRowError
+		err     error
+	)
+	switch params.Format {
+	case "", ImportFormatCSV:
+		rows, rowErrs, err = readImportCSV(params.Data)
+	case ImportFormatMARC:
+		rows, err = readImportMARC(marc.NewReader(params.Data))
+	case ImportFormatMARCXML:
+		rows, err = readImportMARC(marc.NewXMLReader(params.Data))
+	default:
+		return nil, ValidationError{
+			Field: "format",
+			Err:   ErrInvalidFormat,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, rowErrs = checkImportRows(rows, rowErrs)
+
+	isbns := make([]string, 0, len(rows))
+	for _, row := range rows {
+		isbns = append(isbns, row.book.ISBN)
+	}
+
+	var report BookImportReport
+	if err := bs.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		report = BookImportReport{Errors: slices.Clone(rowErrs)}
+
+		existing, err := tx.Books().ListByISBNs(ctx, isbns)
+		if err != nil {
+			return err
+		}
+
+		// ISBNs are stored padded with spaces to 13 characters.
+		byISBN := make(map[string][]model.Book, len(existing))
+		for _, book := range existing {
+			isbn := strings.TrimRight(book.ISBN, " ")
+			byISBN[isbn] = append(byISBN[isbn], book)
+		}
+
+		type update struct {
+			before, after model.Book
+			columns       []string
+		}
+		var (
+			creates []model.Book
+			updates []update
+		)
+		for _, row := range rows {
+			matches := byISBN[row.book.ISBN]
+			if len(matches) == 0 {
+				book := row.book
+				if book.AvailabilityStatus == "" {
+					book.AvailabilityStatus = "available"
+				}
+				creates = append(creates, book)
+				report.Created++
+				continue
+			}
+			if len(matches) > 1 {
+				report.Errors = append(report.Errors, BookImportRowError{
+					Row: row.line,
+					Err: ValidationError{Field: "isbn", Err: ErrAmbiguousISBN},
+				})
+				continue
+			}
+
+			before := matches[0]
+			after, columns := mergeImport(before, row.book)
+			if sameBook(after, before) {
+				report.Unchanged++
+				continue
+			}
+
+			updates = append(updates, update{before: before, after: after, columns: columns})
+			report.Updated++
+		}
+		slices.SortStableFunc(report.Errors, func(a, b BookImportRowError) int {
+			return cmp.Compare(a.Row, b.Row)
+		})
+
+		if params.DryRun {
+			return nil
+		}
+
+		linked := make([]*model.Book, 0, len(creates)+len(updates))
+		for i := range creates {
+			linked = append(linked, &creates[i])
+		}
+		for i := range updates {
+			linked = append(linked, &updates[i].after)
+		}
+		if err := linkBooks(ctx, tx, linked...); err != nil {
+			return err
+		}
+
+		changes := make([]audit.Change, 0, len(creates)+len(updates))
+		for _, update := range updates {
+			before, after := update.before, update.after
+			if err := tx.Books().Update(ctx, &after, update.columns...); err != nil {
+				return err
+			}
+
+			changes = append(changes, audit.Change{
+				Action:   audit.ActionUpdate,
+				Entity:   "book",
+				EntityID: after.ID,
+				Before:   &before,
+				After:    &after,
+			})
+		}
+		if err := tx.Books().CreateMany(ctx, creates); err != nil {
+			return err
+		}
+		for _, book := range creates {
+			changes = append(changes, audit.Change{
+				Action:   audit.ActionCreate,
+				Entity:   "book",
+				EntityID: book.ID,
+				After:    &book,
+			})
+		}
+
+		return audit.RecordMany(ctx, tx, changes)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// readImportCSV parses the rows of an import, setting aside those with the
+// wrong number of fields. It only fails when the CSV as a whole is unusable.
+func readImportCSV(r io.Reader) ([]importRow, []BookImportRowError, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, ValidationError{
+			Field: "csv",
+			Err:   err,
+		}
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets tend to start the files they export with a BOM.
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "author", "isbn"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, ValidationError{
+				Field: name,
+				Err:   ErrMissingColumn,
+			}
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+
+		return strings.TrimSpace(record[i])
+	}
+
+	var (
+		rows    []importRow
+		rowErrs []BookImportRowError
+	)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if parseErr := (*csv.ParseError)(nil); errors.As(err, &parseErr) && errors.Is(parseErr, csv.ErrFieldCount) {
+			rowErrs = append(rowErrs, BookImportRowError{
+				Row: parseErr.StartLine,
+				Err: csv.ErrFieldCount,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, nil, ValidationError{
+				Field: "csv",
+				Err:   err,
+			}
+		}
+		line, _ := cr.FieldPos(0)
+
+		book := model.Book{
+			Title:              field(record, "title"),
+			Authors:            newAuthors(splitNames(field(record, "author"))),
+			Publishers:         newPublishers(splitNames(field(record, "publisher"))),
+			Subjects:           newSubjects(splitNames(field(record, "subject"))),
+			Tags:               newTags(splitNames(field(record, "tag"))),
+			ISBN:               field(record, "isbn"),
+			Edition:            field(record, "edition"),
+			Language:           field(record, "language"),
+			Classification:     field(record, "classification"),
+			CallNumber:         field(record, "call_number"),
+			AvailabilityStatus: field(record, "availability_status"),
+		}
+		err = parseNumber("publication_year", field(record, "publication_year"), &book.PublicationYear)
+		if err == nil {
+			err = parseNumber("page_count", field(record, "page_count"), &book.PageCount)
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, BookImportRowError{Row: line, Err: err})
+			continue
+		}
+
+		rows = append(rows, importRow{line: line, book: book})
+	}
+
+	return rows, rowErrs, nil
+}
+
+type recordReader interface {
+	Read() (*marc.Record, error)
+}
+
+// readImportMARC maps the records of an import to books. It fails when any
+// of them can't be parsed, as there's no telling where the next one starts.
+func readImportMARC(r recordReader) ([]importRow, error) {
+	var rows []importRow
+	for i := 1; ; i++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ValidationError{
+				Field: "marc",
+				Err:   fmt.Errorf("record %d: %w", i, err),
+			}
+		}
+
+		rows = append(rows, importRow{line: i, book: bookFromMARC(*rec)})
+	}
+
+	return rows, nil
+}
+
+// checkImportRows sets aside the rows that fail validation or repeat the
+// ISBN of an earlier row.
+func checkImportRows(rows []importRow, rowErrs []BookImportRowError) ([]importRow, []BookImportRowError) {
+	valid := make([]importRow, 0, len(rows))
+	seen := make(map[string]int)
+	for _, row := range rows {
+		if err := validateBook(&row.book); err != nil {
+			rowErrs = append(rowErrs, BookImportRowError{Row: row.line, Err: err})
+			continue
+		}
+		if prev, ok := seen[row.book.ISBN]; ok {
+			rowErrs = append(rowErrs, BookImportRowError{
+				Row: row.line,
+				Err: ValidationError{Field: "isbn", Err: fmt.Errorf("%w of row %d", ErrDuplicate, prev)},
+			})
+			continue
+		}
+		seen[row.book.ISBN] = row.line
+
+		valid = append(valid, row)
+	}
+
+	return valid, rowErrs
+}
+
+// splitNames splits a field of semicolon separated names.
+func splitNames(field string) []string {
+	var names []string
+	for _, name := range strings.Split(field, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// parseNumber parses the field into n, leaving n alone when it's empty.
+func parseNumber(name, field string, n *int32) error {
+	if field == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseInt(field, 10, 32)
+	if err != nil {
+		return ValidationError{
+			Field: name,
+			Err:   ErrNotANumber,
+		}
+	}
+
+	*n = int32(parsed)
+	return nil
+}
+
+// mergeImport updates book with the fields of an imported one, leaving the
+// optional fields the import doesn't have alone, and returns the columns it
+// replaced.
+func mergeImport(book, imported model.Book) (model.Book, []string) {
+	book.Title = imported.Title
+	book.Authors = imported.Authors
+	columns := []string{"title", "authors"}
+	if len(imported.Publishers) > 0 {
+		book.Publishers = imported.Publishers
+		columns = append(columns, "publishers")
+	}
+	if len(imported.Subjects) > 0 {
+		book.Subjects = imported.Subjects
+		columns = append(columns, "subjects")
+	}
+	if imported.PublicationYear != 0 {
+		book.PublicationYear = imported.PublicationYear
+		columns = append(columns, "publication_year")
+	}
+	if imported.Edition != "" {
+		book.Edition = imported.Edition
+		columns = append(columns, "edition")
+	}
+	if imported.Language != "" {
+		book.Language = imported.Language
+		columns = append(columns, "language")
+	}
+	if imported.PageCount != 0 {
+		book.PageCount = imported.PageCount
+		columns = append(columns, "page_count")
+	}
+	if len(imported.Tags) > 0 {
+		book.Tags = imported.Tags
+		columns = append(columns, "tags")
+	}
+	if imported.CallNumber != "" {
+		book.Classification = imported.Classification
+		book.CallNumber = imported.CallNumber
+		book.CallNumberKey = imported.CallNumberKey
+		columns = append(columns, "classification", "call_number", "call_number_key")
+	}
+	if imported.AvailabilityStatus != "" {
+		book.AvailabilityStatus = imported.AvailabilityStatus
+		columns = append(columns, "availability_status")
+	}
+
+	return book, columns
+}
+
+// sameBook reports whether two books have the same fields, comparing the
+// entities they're linked to by name.
+func sameBook(a, b model.Book) bool {
+	return a.Title == b.Title &&
+		a.ISBN == b.ISBN &&
+		a.PublicationYear == b.PublicationYear &&
+		a.Edition == b.Edition &&
+		a.Language == b.Language &&
+		a.PageCount == b.PageCount &&
+		a.Classification == b.Classification &&
+		a.CallNumber == b.CallNumber &&
+		a.AvailabilityStatus == b.AvailabilityStatus &&
+		slices.Equal(authorNames(a.Authors), authorNames(b.Authors)) &&
+		slices.Equal(publisherNames(a.Publishers), publisherNames(b.Publishers)) &&
+		slices.Equal(subjectNames(a.Subjects), subjectNames(b.Subjects)) &&
+		slices.Equal(tagNames(a.Tags), tagNames(b.Tags))
+}
@@ -0,0 +1,257 @@
+package service_test
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/service"
+)
+
+const catalogue = `Title,Author,ISBN,Shelf
+The Go Programming Language,Alan Donovan,9780134190440,A1
+Dune,Frank Herbert,9780441013593,B2
+,Nobody,9780000000000,C3
+Dune Messiah,Frank Herbert,9780441013593,B2
+Too,Many,Fields,9780593099322,D4
+Children of Dune,Frank Herbert,9780593098240,B2
+`
+
+func TestBookServiceImportCSV(t *testing.T) {
+	lib := newLibrary(t)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader(catalogue),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 2 || report.Updated != 0 || report.Unchanged != 1 {
+		t.Errorf("report = %+v; want 2 created and 1 unchanged", report)
+	}
+
+	wantErrs := []struct {
+		row int
+		err error
+	}{
+		{row: 4, err: service.ErrRequired},
+		{row: 5, err: service.ErrDuplicate},
+		{row: 6, err: csv.ErrFieldCount},
+	}
+	if len(report.Errors) != len(wantErrs) {
+		t.Fatalf("errors = %v; want %d", report.Errors, len(wantErrs))
+	}
+	for i, want := range wantErrs {
+		got := report.Errors[i]
+		if got.Row != want.row || !errors.Is(got, want.err) {
+			t.Errorf("errors[%d] = %v; want row %d failing with %v", i, got, want.row, want.err)
+		}
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "The Go Programming Language" || book.Authors[0].Name != "Alan Donovan" || book.Version != 1 {
+		t.Errorf("book = %+v; want it unchanged", book)
+	}
+
+	entries, err := service.AuditService{Store: lib.st}.List(context.Background(), service.AuditListParams{
+		Entity: "book",
+		Action: audit.ActionCreate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("entries = %d; want 2 besides that of the library's book", len(entries))
+	}
+}
+
+func TestBookServiceImportCSVUpserts(t *testing.T) {
+	lib := newLibrary(t)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("isbn,title,author,availability_status\n" +
+			"9780134190440,The Go Programming Language,Alan A. A. Donovan,lost\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 0 || report.Updated != 1 || len(report.Errors) != 0 {
+		t.Fatalf("report = %+v; want 1 updated", report)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Authors[0].Name != "Alan A. A. Donovan" || book.AvailabilityStatus != "lost" || book.Version != 2 {
+		t.Errorf("book = %+v; want it updated", book)
+	}
+}
+
+func TestBookServiceImportCSVCatalogFields(t *testing.T) {
+	lib := newLibrary(t)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("title,author,isbn,publisher,subject,publication_year,edition,language,page_count\n" +
+			"The Go Programming Language,Alan Donovan; Brian Kernighan,9780134190440,Addison-Wesley,Go;Programming,2015,1st ed.,eng,380\n" +
+			"Dune,Frank Herbert,9780441013593,,,soon,,,\n" +
+			"The C Programming Language,Brian Kernighan;Dennis Ritchie,9780131103627,Prentice Hall,Programming,1988,,,\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 1 || len(report.Errors) != 1 || !errors.Is(report.Errors[0], service.ErrNotANumber) {
+		t.Fatalf("report = %+v; want 1 created, 1 updated and the year of dune not a number", report)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Authors) != 2 || book.Authors[1].Name != "Brian Kernighan" ||
+		len(book.Publishers) != 1 || book.Publishers[0].Name != "Addison-Wesley" ||
+		len(book.Subjects) != 2 || book.Subjects[1].Name != "Programming" {
+		t.Errorf("book = %+v; want its authors, publishers and subjects in order", book)
+	}
+	if book.PublicationYear != 2015 || book.Edition != "1st ed." || book.Language != "eng" || book.PageCount != 380 {
+		t.Errorf("book = %+v; want its catalog fields set", book)
+	}
+
+	// Both books link to the same Brian Kernighan and subject.
+	books, err := lib.books.List(context.Background(), service.BookListParams{
+		AuthorID:  book.Authors[1].ID,
+		SubjectID: book.Subjects[1].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 {
+		t.Errorf("books = %+v; want both by Brian Kernighan", books)
+	}
+
+	// Rows leave the optional columns they don't have alone.
+	if _, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("title,author,isbn\nGopl,Alan Donovan,9780134190440\n"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	book, err = lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "Gopl" || len(book.Authors) != 1 || len(book.Subjects) != 2 || book.PublicationYear != 2015 {
+		t.Errorf("book = %+v; want only its title and authors replaced", book)
+	}
+}
+
+func TestBookServiceImportCSVClassification(t *testing.T) {
+	lib := newLibrary(t)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("title,author,isbn,tag,classification,call_number\n" +
+			"The Go Programming Language,Alan Donovan,9780134190440,Go;Concurrency,lcc,QA76.73.G63 D66 2015\n" +
+			"Dune,Frank Herbert,9780441013593,,dewey,813.54 HER\n" +
+			"Cosmos,Carl Sagan,9780345539434,,,520 SAG\n" +
+			"Lolita,Vladimir Nabokov,9780679723165,,dewey,PS3527.A15\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 1 || len(report.Errors) != 2 ||
+		!errors.Is(report.Errors[0], service.ErrRequired) || !errors.Is(report.Errors[1], callnumber.ErrMalformed) {
+		t.Fatalf("report = %+v; want 1 created, 1 updated, cosmos missing its classification and lolita malformed", report)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Tags) != 2 || book.Tags[0].Name != "go" || book.Tags[1].Name != "concurrency" ||
+		book.Classification != "lcc" || book.CallNumber != "QA76.73.G63 D66 2015" {
+		t.Errorf("book = %+v; want its tags and call number", book)
+	}
+
+	// Importing the same row again changes nothing, whereas leaving the
+	// call number out leaves it alone.
+	report, err = lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("title,author,isbn,tag,classification,call_number\n" +
+			"The Go Programming Language,Alan Donovan,9780134190440,go;concurrency,lcc,QA76.73.G63 D66 2015\n" +
+			"Dune,Frank Herbert,9780441013593,,,\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 2 {
+		t.Errorf("report = %+v; want 2 unchanged", report)
+	}
+}
+
+func TestBookServiceImportCSVDryRun(t *testing.T) {
+	lib := newLibrary(t)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data:   strings.NewReader(catalogue),
+		DryRun: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 2 || report.Unchanged != 1 || len(report.Errors) != 3 {
+		t.Errorf("report = %+v; want the same as a real import", report)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Version != 1 {
+		t.Errorf("version = %d; want 1", book.Version)
+	}
+	entries, err := service.AuditService{Store: lib.st}.List(context.Background(), service.AuditListParams{
+		Entity: "book",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("entries = %+v; want only that of the library's book", entries)
+	}
+}
+
+func TestBookServiceImportCSVAmbiguous(t *testing.T) {
+	lib := newLibrary(t)
+	mustCreateBook(t, lib.books)
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Data: strings.NewReader("title,author,isbn\nGopl,Alan Donovan,9780134190440\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 1 || !errors.Is(report.Errors[0], service.ErrAmbiguousISBN) {
+		t.Errorf("errors = %v; want the row to match both copies", report.Errors)
+	}
+}
+
+func TestBookServiceImportCSVInvalid(t *testing.T) {
+	bs := service.BookService{Store: newLibrary(t).st}
+
+	for name, data := range map[string]string{
+		"empty":     "",
+		"no isbn":   "title,author\nDune,Frank Herbert\n",
+		"bad quote": "title,author,isbn\n\"Dune,Frank Herbert,9780441013593\n",
+	} {
+		if _, err := bs.Import(context.Background(), service.BookImportParams{
+			Data: strings.NewReader(data),
+		}); !errors.As(err, new(service.ValidationError)) {
+			t.Errorf("%s: err = %v; want ValidationError", name, err)
+		}
+	}
+}
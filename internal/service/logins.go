@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+)
+
+// LockedError is returned for logins to an account, or from an address, that
+// failed too many times in a row.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (le LockedError) Error() string {
+	return fmt.Sprintf("locked out for %s", le.RetryAfter.Round(time.Second))
+}
+
+// LockoutPolicy decides how failed logins are throttled. Once an account or
+// an address runs out of free attempts, every further failure locks it for
+// twice as long as the previous one, up to MaxLockout.
+type LockoutPolicy struct {
+	// AccountAttempts is how many failures in a row an account gets before
+	// it's locked. Zero means 5.
+	AccountAttempts int
+	// IPAttempts is the same for addresses, which may be shared by many
+	// users. Zero means 20.
+	IPAttempts int
+	// BaseLockout is how long the first lockout lasts. Zero means 30
+	// seconds.
+	BaseLockout time.Duration
+	// MaxLockout caps lockouts. Zero means 15 minutes.
+	MaxLockout time.Duration
+	// Window is how long failures are remembered after the last one. Zero
+	// means an hour.
+	Window time.Duration
+}
+
+func (lp LockoutPolicy) withDefaults() LockoutPolicy {
+	if lp.AccountAttempts == 0 {
+		lp.AccountAttempts = 5
+	}
+	if lp.IPAttempts == 0 {
+		lp.IPAttempts = 20
+	}
+	if lp.BaseLockout == 0 {
+		lp.BaseLockout = 30 * time.Second
+	}
+	if lp.MaxLockout == 0 {
+		lp.MaxLockout = 15 * time.Minute
+	}
+	if lp.Window == 0 {
+		lp.Window = time.Hour
+	}
+
+	return lp
+}
+
+// attempt checks that none of the subjects given by KEYS is locked out and
+// counts a failure against each of them, locking out those that ran out of
+// free attempts. KEYS holds the failures and lock of each subject in turn and
+// ARGV the free attempts of each, followed by the window and the base and
+// maximum lockout in milliseconds. It returns 0 and how many milliseconds are
+// left of a lockout, or 1 and whether each subject got locked out.
+var attempt = redis.NewScript(`
+local n = #KEYS / 2
+local window = tonumber(ARGV[n + 1])
+local base = tonumber(ARGV[n + 2])
+local max = tonumber(ARGV[n + 3])
+
+for i = 1, n do
+	local ttl = redis.call("PTTL", KEYS[2 * i])
+	if ttl > 0 then
+		return {0, ttl}
+	end
+end
+
+local locked = {}
+for i = 1, n do
+	local failures = redis.call("INCR", KEYS[2 * i - 1])
+	redis.call("PEXPIRE", KEYS[2 * i - 1], window)
+
+	local free = tonumber(ARGV[i])
+	locked[i] = 0
+	if failures >= free then
+		local lockout = base
+		for _ = 1, failures - free do
+			lockout = lockout * 2
+			if lockout >= max then
+				break
+			end
+		end
+		redis.call("SET", KEYS[2 * i], 1, "PX", math.min(lockout, max))
+		locked[i] = 1
+	end
+end
+
+return {1, unpack(locked)}
+`)
+
+// LoginService authenticates users while keeping count of failed attempts per
+// account and per address in Redis, and records every attempt.
+type LoginService struct {
+	Store   store.Store
+	RDB     *redis.Client
+	Users   UserService
+	Lockout LockoutPolicy
+}
+
+// Lengths of the columns of login_attempts, which the email and address of
+// an attempt are cut down to so that any attempt can be recorded.
+const (
+	maxAttemptEmailLength = 300
+	maxAttemptIPLength    = 45
+)
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Login returns the user with the given email if password is theirs. It
+// returns a LockedError instead when the account or ip is locked out, and
+// ErrInvalidCredentials otherwise. Attempts count as failures until they
+// succeed, so that a burst of them can't get past the free ones.
+func (ls LoginService) Login(ctx context.Context, email string, password []byte, ip string) (*model.User, error) {
+	policy := ls.Lockout.withDefaults()
+	account, addr := accountKey(email), ipKey(ip)
+
+	res, err := attempt.Run(ctx, ls.RDB,
+		[]string{"login-failures:" + account, "login-lock:" + account, "login-failures:" + addr, "login-lock:" + addr},
+		policy.AccountAttempts, policy.IPAttempts,
+		policy.Window.Milliseconds(), policy.BaseLockout.Milliseconds(), policy.MaxLockout.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if res[0] == 0 {
+		return nil, LockedError{RetryAfter: time.Duration(res[1]) * time.Millisecond}
+	}
+	addrLocked := res[2] == 1
+
+	user, err := ls.Users.Authenticate(ctx, email, password)
+	if err != nil && !errors.Is(err, ErrInvalidCredentials) {
+		return nil, err
+	}
+
+	attempt := model.LoginAttempt{
+		Email:     truncate(email, maxAttemptEmailLength),
+		IP:        truncate(ip, maxAttemptIPLength),
+		Succeeded: err == nil,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		attempt.UserID = sql.NullInt32{Int32: user.ID, Valid: true}
+	} else if known, err := ls.Store.Users().GetByEmail(ctx, email); err == nil {
+		attempt.UserID = sql.NullInt32{Int32: known.ID, Valid: true}
+	}
+	if err := ls.Store.LoginAttempts().Create(ctx, &attempt); err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Take back the failure the attempt was counted as: the account starts
+	// over, while the address is only spared this one.
+	if _, err := ls.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, "login-failures:"+account, "login-lock:"+account)
+		pipe.Decr(ctx, "login-failures:"+addr)
+		if addrLocked {
+			pipe.Del(ctx, "login-lock:"+addr)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+
+	return s
+}
+
+// Unlock lifts the lockout of the user's account and forgets its failed
+// attempts.
+func (ls LoginService) Unlock(ctx context.Context, id int32) error {
+	tracing.SetUserID(ctx, id)
+	user, err := ls.Users.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	key := accountKey(user.Email)
+	return ls.RDB.Del(ctx, "login-lock:"+key, "login-failures:"+key).Err()
+}
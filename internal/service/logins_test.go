@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func newLoginService(t *testing.T) (service.LoginService, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	us := newUserService()
+	mustCreateUser(t, us, "jane@example.com")
+	return service.LoginService{Store: us.Store, RDB: rdb, Users: us}, mr
+}
+
+func failLogins(t *testing.T, ls service.LoginService, n int, email, ip string) {
+	t.Helper()
+	for i := range n {
+		_, err := ls.Login(context.Background(), email, []byte("battery staple"), ip)
+		if !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("failure %d: err = %v; want %v", i+1, err, service.ErrInvalidCredentials)
+		}
+	}
+}
+
+func TestLoginServiceLocksAccount(t *testing.T) {
+	ls, mr := newLoginService(t)
+
+	failLogins(t, ls, 5, "jane@example.com", "10.0.0.1")
+
+	_, err := ls.Login(context.Background(), "jane@example.com", []byte("correct horse"), "10.0.0.2")
+	var lockedErr service.LockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("err = %v; want LockedError", err)
+	}
+	if lockedErr.RetryAfter <= 0 || lockedErr.RetryAfter > 30*time.Second {
+		t.Errorf("retry after = %s; want at most 30s", lockedErr.RetryAfter)
+	}
+
+	mr.FastForward(31 * time.Second)
+	failLogins(t, ls, 1, "jane@example.com", "10.0.0.1")
+	if ttl := mr.TTL("login-lock:account:jane@example.com"); ttl != time.Minute {
+		t.Errorf("second lockout = %s; want 1m", ttl)
+	}
+}
+
+func TestLoginServiceLocksAccountDuringBurst(t *testing.T) {
+	ls, _ := newLoginService(t)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ls.Login(context.Background(), "jane@example.com", []byte("battery staple"), "10.0.0.1")
+			if errors.Is(err, service.ErrInvalidCredentials) {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			} else if !errors.As(err, new(service.LockedError)) {
+				t.Errorf("err = %v; want %v or LockedError", err, service.ErrInvalidCredentials)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed != 5 {
+		t.Fatalf("%d attempts were checked; want 5", failed)
+	}
+}
+
+func TestLoginServiceSuccessResetsFailures(t *testing.T) {
+	ls, _ := newLoginService(t)
+
+	failLogins(t, ls, 4, "jane@example.com", "10.0.0.1")
+	if _, err := ls.Login(context.Background(), "jane@example.com", []byte("correct horse"), "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	failLogins(t, ls, 4, "jane@example.com", "10.0.0.1")
+}
+
+func TestLoginServiceLocksAddress(t *testing.T) {
+	ls, _ := newLoginService(t)
+	ls.Lockout.IPAttempts = 3
+
+	failLogins(t, ls, 1, "john@example.com", "10.0.0.1")
+	failLogins(t, ls, 1, "joe@example.com", "10.0.0.1")
+	failLogins(t, ls, 1, "jim@example.com", "10.0.0.1")
+
+	_, err := ls.Login(context.Background(), "jane@example.com", []byte("correct horse"), "10.0.0.1")
+	if !errors.As(err, new(service.LockedError)) {
+		t.Fatalf("err = %v; want LockedError", err)
+	}
+	if _, err := ls.Login(context.Background(), "jane@example.com", []byte("correct horse"), "10.0.0.2"); err != nil {
+		t.Fatalf("login from another address: %v", err)
+	}
+}
+
+func TestLoginServiceUnlock(t *testing.T) {
+	ls, _ := newLoginService(t)
+
+	failLogins(t, ls, 5, "jane@example.com", "10.0.0.1")
+	if err := ls.Unlock(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.Login(context.Background(), "jane@example.com", []byte("correct horse"), "10.0.0.2"); err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+
+	if err := ls.Unlock(context.Background(), 2); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
+
+func TestLoginServiceRecordsOverlongAttempts(t *testing.T) {
+	ls, _ := newLoginService(t)
+	ip := strings.Repeat("1.2.3.4, ", 10)
+
+	failLogins(t, ls, 1, strings.Repeat("é", 301)+"@example.com", ip)
+	failLogins(t, ls, 1, "jane@example.com", ip)
+
+	attempts, err := ls.Store.LoginAttempts().ListByUserID(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || attempts[0].IP != ip[:45] {
+		t.Fatalf("attempts = %+v; want one from %q", attempts, ip[:45])
+	}
+}
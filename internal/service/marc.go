@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/utilyre/lms/internal/callnumber"
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+// exportPageSize is how many books ExportMARC reads at a time.
+const exportPageSize = 1000
+
+// GetMARCByID returns the book as a MARC 21 record.
+func (bs BookService) GetMARCByID(ctx context.Context, id int32) (*marc.Record, error) {
+	book, err := bs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := marcFromBook(*book)
+	return &rec, nil
+}
+
+// ExportMARC calls fn with a MARC 21 record of each book, oldest first. The
+// catalogue is read a page at a time rather than in a single transaction, so
+// that fn is free to stream the records as they come.
+func (bs BookService) ExportMARC(ctx context.Context, fn func(rec *marc.Record) error) error {
+	var afterID int32
+	for {
+		books, err := bs.Store.Books().List(ctx, store.BookFilter{
+			AfterID: afterID,
+			Limit:   exportPageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			rec := marcFromBook(book)
+			if err := fn(&rec); err != nil {
+				return err
+			}
+		}
+		if len(books) < exportPageSize {
+			return nil
+		}
+		afterID = books[len(books)-1].ID
+	}
+}
+
+// bookFromMARC maps a MARC 21 bibliographic record to a book, without the
+// punctuation cataloguers separate fields with:
+//
+//   - the ISBN comes from 020 $a
+//   - the authors from 100 $a, or 110 $a for works by organizations,
+//     followed by those of 700 $a and 710 $a
+//   - the title from 245 $a and $b and the edition from 250 $a
+//   - the publishers from 264 $b, or 260 $b in older records
+//   - the publication year from 008/07-10, or else 264 $c or 260 $c
+//   - the language from 008/35-37, or else 041 $a
+//   - the page count from 300 $a, as in "xii, 412 p."
+//   - the subjects from 650 $a and 651 $a, and the tags from 653 $a
+//   - the call number from 050 $a and $b in LCC, or else 082 $a and $b in
+//     Dewey, unless it's malformed
+func bookFromMARC(rec marc.Record) model.Book {
+	// ISBNs are often qualified, as in "0-441-01359-7 (pbk.)".
+	isbn, _, _ := strings.Cut(strings.TrimSpace(rec.Subfield("020", 'a')), " ")
+	isbn = strings.ReplaceAll(isbn, "-", "")
+
+	title := trimISBD(rec.Subfield("245", 'a'))
+	if subtitle := trimISBD(rec.Subfield("245", 'b')); subtitle != "" {
+		title += ": " + subtitle
+	}
+
+	var (
+		authors, publishers, subjects, tags []string
+		dates                               []string
+	)
+	for _, tag := range []string{"100", "110", "700", "710"} {
+		for _, field := range dataFields(rec, tag) {
+			authors = appendName(authors, strings.TrimRight(strings.TrimSpace(field.Subfield('a')), " ,"))
+		}
+	}
+	// Fields 264 with a second indicator of 1 are about publication, rather
+	// than production, distribution or manufacture.
+	for _, field := range append(dataFields(rec, "264"), dataFields(rec, "260")...) {
+		if field.Tag == "264" && field.Ind2 != '1' {
+			continue
+		}
+		for _, sub := range field.Subfields {
+			switch sub.Code {
+			case 'b':
+				publishers = appendName(publishers, trimISBD(sub.Value))
+			case 'c':
+				dates = append(dates, sub.Value)
+			}
+		}
+	}
+	for _, tag := range []string{"650", "651"} {
+		for _, field := range dataFields(rec, tag) {
+			subjects = appendName(subjects, trimISBD(field.Subfield('a')))
+		}
+	}
+	for _, field := range dataFields(rec, "653") {
+		tags = appendName(tags, trimISBD(field.Subfield('a')))
+	}
+
+	var classification, callNumber string
+	for _, scheme := range []struct {
+		tag    string
+		scheme callnumber.Scheme
+	}{
+		{tag: "050", scheme: callnumber.LCC},
+		{tag: "082", scheme: callnumber.Dewey},
+	} {
+		// The classification number and the item number, which are written
+		// apart, make up the call number together.
+		s := strings.TrimSpace(strings.TrimSpace(rec.Subfield(scheme.tag, 'a')) + " " + strings.TrimSpace(rec.Subfield(scheme.tag, 'b')))
+		if _, err := callnumber.Key(scheme.scheme, s); err == nil {
+			classification, callNumber = string(scheme.scheme), s
+			break
+		}
+	}
+
+	var year int32
+	fixed := rec.ControlField("008")
+	if len(fixed) >= 11 {
+		year = parseYear(fixed[7:11])
+	}
+	for _, date := range dates {
+		if year != 0 {
+			break
+		}
+		year = parseYear(yearPattern.FindString(date))
+	}
+
+	var language string
+	if len(fixed) >= 38 && validateLanguage(fixed[35:38]) == nil {
+		language = fixed[35:38]
+	} else if code := strings.TrimSpace(rec.Subfield("041", 'a')); validateLanguage(code) == nil {
+		language = code
+	}
+
+	var pageCount int32
+	if m := pagesPattern.FindStringSubmatch(rec.Subfield("300", 'a')); m != nil {
+		n, _ := strconv.ParseInt(m[1], 10, 32)
+		pageCount = int32(n)
+	}
+
+	return model.Book{
+		Title:      title,
+		Authors:    newAuthors(authors),
+		Publishers: newPublishers(publishers),
+		Subjects:   newSubjects(subjects),
+		Tags:       newTags(tags),
+		ISBN:       isbn,
+		// Editions tend to end with an abbreviation, as in "2nd ed.".
+		Edition:         strings.TrimRight(strings.TrimSpace(rec.Subfield("250", 'a')), " /:;=,"),
+		PublicationYear: year,
+		Language:        language,
+		PageCount:       pageCount,
+		Classification:  classification,
+		CallNumber:      callNumber,
+	}
+}
+
+var (
+	yearPattern  = regexp.MustCompile(`\d{4}`)
+	pagesPattern = regexp.MustCompile(`(\d+) ?(?:p\b|pages\b)`)
+)
+
+func dataFields(rec marc.Record, tag string) []marc.DataField {
+	var fields []marc.DataField
+	for _, field := range rec.DataFields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// appendName appends name to names unless it's empty or already there.
+func appendName(names []string, name string) []string {
+	if name == "" || slices.Contains(names, name) {
+		return names
+	}
+
+	return append(names, name)
+}
+
+// parseYear parses a year of four digits, returning zero for anything else,
+// such as "uuuu" for unknown years in 008.
+func parseYear(s string) int32 {
+	if len(s) != 4 {
+		return 0
+	}
+	year, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || year < 1 {
+		return 0
+	}
+
+	return int32(year)
+}
+
+// trimISBD trims the punctuation that ends fields in ISBD, as in
+// "Dune Messiah /".
+func trimISBD(s string) string {
+	return strings.TrimRight(strings.TrimSpace(s), " /:;=,.")
+}
+
+// marcFromBook maps a book to a MARC 21 bibliographic record the way
+// bookFromMARC reads it back, with the ID of the book as its control number.
+func marcFromBook(book model.Book) marc.Record {
+	year, language := "uuuu", "|||"
+	dateType := byte('n')
+	if book.PublicationYear != 0 {
+		year = fmt.Sprintf("%04d", book.PublicationYear)
+		dateType = 's'
+	}
+	if len(book.Language) == 3 {
+		language = book.Language
+	}
+	// Positions not mapped to fields of books are filled with "|", which
+	// stands for "no attempt to code".
+	fixed := "||||||" + string(dateType) + year + "    " + "xx " + strings.Repeat("|", 17) + language + " d"
+
+	rec := marc.Record{
+		Leader: marc.DefaultLeader,
+		ControlFields: []marc.ControlField{
+			{Tag: "001", Value: strconv.Itoa(int(book.ID))},
+			{Tag: "008", Value: fixed},
+		},
+	}
+	field := func(tag string, ind1, ind2 byte, subfields ...marc.Subfield) {
+		rec.DataFields = append(rec.DataFields, marc.DataField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: subfields})
+	}
+
+	field("020", ' ', ' ', marc.Subfield{Code: 'a', Value: strings.TrimRight(book.ISBN, " ")})
+	if len(book.Language) == 2 {
+		// 008 only has room for the three letter codes of MARC, so the two
+		// letter ones of ISO 639-1 are given along with their source.
+		field("041", ' ', '7', marc.Subfield{Code: 'a', Value: book.Language}, marc.Subfield{Code: '2', Value: "iso639-1"})
+	}
+	if book.CallNumber != "" {
+		// The call number is split into the classification number and the
+		// item number at its first space, and the second indicator of 4
+		// marks it as assigned by the library rather than the Library of
+		// Congress.
+		class, item, _ := strings.Cut(book.CallNumber, " ")
+		subfields := []marc.Subfield{{Code: 'a', Value: class}}
+		if item = strings.TrimSpace(item); item != "" {
+			subfields = append(subfields, marc.Subfield{Code: 'b', Value: item})
+		}
+		switch callnumber.Scheme(book.Classification) {
+		case callnumber.LCC:
+			field("050", ' ', '4', subfields...)
+		case callnumber.Dewey:
+			field("082", '0', '4', subfields...)
+		}
+	}
+	if len(book.Authors) > 0 {
+		field("100", '1', ' ', marc.Subfield{Code: 'a', Value: book.Authors[0].Name})
+	}
+	field("245", '1', '0', marc.Subfield{Code: 'a', Value: book.Title})
+	if book.Edition != "" {
+		field("250", ' ', ' ', marc.Subfield{Code: 'a', Value: book.Edition})
+	}
+	if len(book.Publishers) > 0 || book.PublicationYear != 0 {
+		var subfields []marc.Subfield
+		for _, publisher := range book.Publishers {
+			subfields = append(subfields, marc.Subfield{Code: 'b', Value: publisher.Name})
+		}
+		if book.PublicationYear != 0 {
+			subfields = append(subfields, marc.Subfield{Code: 'c', Value: year})
+		}
+		field("264", ' ', '1', subfields...)
+	}
+	if book.PageCount != 0 {
+		field("300", ' ', ' ', marc.Subfield{Code: 'a', Value: fmt.Sprintf("%d p.", book.PageCount)})
+	}
+	for _, subject := range book.Subjects {
+		// The second indicator of 4 leaves the thesaurus unspecified.
+		field("650", ' ', '4', marc.Subfield{Code: 'a', Value: subject.Name})
+	}
+	for _, tag := range book.Tags {
+		field("653", ' ', ' ', marc.Subfield{Code: 'a', Value: tag.Name})
+	}
+	for _, author := range book.Authors[min(1, len(book.Authors)):] {
+		field("700", '1', ' ', marc.Subfield{Code: 'a', Value: author.Name})
+	}
+
+	return rec
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/utilyre/lms/internal/marc"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func TestBookServiceImportMARC(t *testing.T) {
+	lib := newLibrary(t)
+
+	var data bytes.Buffer
+	w := marc.NewWriter(&data)
+	for _, rec := range []marc.Record{
+		{
+			ControlFields: []marc.ControlField{{Tag: "008", Value: "850101s1965    nyu           000 1 eng d"}},
+			DataFields: []marc.DataField{
+				{Tag: "020", Subfields: []marc.Subfield{{Code: 'a', Value: "0-441-01359-7 (pbk.)"}}},
+				{Tag: "050", Ind1: '0', Ind2: '0', Subfields: []marc.Subfield{
+					{Code: 'a', Value: "PS3558.E63"},
+					{Code: 'b', Value: "D8 1965"},
+				}},
+				{Tag: "082", Ind1: '0', Ind2: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "813/.54"}}},
+				{Tag: "100", Ind1: '1', Subfields: []marc.Subfield{{Code: 'a', Value: "Herbert, Frank,"}}},
+				{Tag: "245", Ind1: '1', Subfields: []marc.Subfield{
+					{Code: 'a', Value: "Dune :"},
+					{Code: 'b', Value: "a novel /"},
+					{Code: 'c', Value: "Frank Herbert."},
+				}},
+				{Tag: "250", Subfields: []marc.Subfield{{Code: 'a', Value: "Ace ed."}}},
+				{Tag: "260", Subfields: []marc.Subfield{
+					{Code: 'a', Value: "New York :"},
+					{Code: 'b', Value: "Ace Books,"},
+					{Code: 'c', Value: "c1965."},
+				}},
+				{Tag: "300", Subfields: []marc.Subfield{{Code: 'a', Value: "535 p. ;"}}},
+				{Tag: "650", Ind2: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "Science fiction."}}},
+				{Tag: "651", Ind2: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "Arrakis (Imaginary place)"}}},
+				{Tag: "653", Subfields: []marc.Subfield{{Code: 'a', Value: "Desert planets"}}},
+			},
+		},
+		{DataFields: []marc.DataField{
+			{Tag: "245", Ind1: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "Anonymous."}}},
+		}},
+	} {
+		if err := w.Write(&rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Format: service.ImportFormatMARC,
+		Data:   &data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || len(report.Errors) != 1 || report.Errors[0].Row != 2 || !errors.Is(report.Errors[0], service.ErrRequired) {
+		t.Fatalf("report = %+v; want 1 created and record 2 missing its author", report)
+	}
+
+	book, err := lib.books.GetByID(context.Background(), lib.book.ID+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "Dune: a novel" || book.Authors[0].Name != "Herbert, Frank" || book.ISBN != "0441013597" {
+		t.Errorf("book = %+v; want dune", book)
+	}
+	if book.PublicationYear != 1965 || book.Edition != "Ace ed." || book.Language != "eng" || book.PageCount != 535 {
+		t.Errorf("book = %+v; want its 008, 250 and 300 fields", book)
+	}
+	if len(book.Publishers) != 1 || book.Publishers[0].Name != "Ace Books" ||
+		len(book.Subjects) != 2 || book.Subjects[1].Name != "Arrakis (Imaginary place)" {
+		t.Errorf("book = %+v; want its 260, 650 and 651 fields", book)
+	}
+	if book.Classification != "lcc" || book.CallNumber != "PS3558.E63 D8 1965" ||
+		len(book.Tags) != 1 || book.Tags[0].Name != "desert planets" {
+		t.Errorf("book = %+v; want its 050 and 653 fields", book)
+	}
+}
+
+func TestBookServiceImportMARCXMLMalformed(t *testing.T) {
+	lib := newLibrary(t)
+
+	if _, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Format: service.ImportFormatMARCXML,
+		Data:   strings.NewReader("<collection><record><leader>"),
+	}); !errors.As(err, new(service.ValidationError)) {
+		t.Fatalf("err = %v; want ValidationError", err)
+	}
+	if _, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Format: "json",
+		Data:   strings.NewReader("[]"),
+	}); !errors.Is(err, service.ErrInvalidFormat) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidFormat)
+	}
+}
+
+func TestBookServiceExportMARC(t *testing.T) {
+	lib := newLibrary(t)
+	dune, err := lib.books.Create(context.Background(), service.BookCreateParams{
+		Title:           "Dune",
+		Authors:         []string{"Frank Herbert", "Brian Herbert"},
+		Publishers:      []string{"Ace Books"},
+		Subjects:        []string{"Science fiction"},
+		ISBN:            "9780441013593",
+		PublicationYear: 1965,
+		Edition:         "Ace ed.",
+		Language:        "en",
+		PageCount:       535,
+		Tags:            []string{"desert", "classics"},
+		Classification:  "lcc",
+		CallNumber:      "PS3558.E63 D8 1965",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data bytes.Buffer
+	w := marc.NewXMLWriter(&data)
+	if err := lib.books.ExportMARC(context.Background(), w.Write); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := data.String()
+
+	r := marc.NewXMLReader(strings.NewReader(exported))
+	for _, want := range []struct{ id, title string }{
+		{id: "1", title: lib.book.Title},
+		{id: "2", title: dune.Title},
+	} {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ControlField("001") != want.id || rec.Subfield("245", 'a') != want.title {
+			t.Errorf("record = %+v; want %s", rec, want.title)
+		}
+	}
+
+	// Exported records import as the books they came from.
+	report, err := lib.books.Import(context.Background(), service.BookImportParams{
+		Format: service.ImportFormatMARCXML,
+		Data:   strings.NewReader(exported),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 2 || report.Created != 0 || report.Updated != 0 {
+		t.Errorf("report = %+v; want 2 unchanged", report)
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "encoding/json"
+
+// Optional is a field of a partial update. The zero value leaves the field
+// alone, while a set Optional either clears it, when Null, or replaces it
+// with Value. Decoding JSON into an Optional sets it, so members that are
+// absent from a document stay unset.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(data, &o.Value)
+}
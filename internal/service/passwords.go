@@ -0,0 +1,384 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/mail"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+)
+
+var (
+	ErrWrongPassword      = errors.New("wrong password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrBreachedPassword   = errors.New("appears in a list of breached passwords")
+	ErrSimilarToEmail     = errors.New("too similar to email")
+)
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters. Zero means 8.
+	MinLength int
+	// MaxLength is the maximum number of bytes. Zero means 72, the most
+	// bcrypt can hash.
+	MaxLength int
+	// Breached holds passwords known to have leaked, which are rejected.
+	Breached map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a file with one password per line, such as the
+// lists of common passwords from past breaches. Blank lines are skipped.
+func LoadBreachedPasswords(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	breached := make(map[string]struct{})
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			breached[line] = struct{}{}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return breached, nil
+}
+
+// check returns a ValidationError for field when password, chosen by the
+// user with the given email, goes against the policy.
+func (pp PasswordPolicy) check(field string, password []byte, email string) error {
+	minLength := pp.MinLength
+	if minLength == 0 {
+		minLength = 8
+	}
+	maxLength := pp.MaxLength
+	if maxLength == 0 {
+		maxLength = 72
+	}
+
+	if utf8.RuneCount(password) < minLength {
+		return ValidationError{
+			Field: field,
+			Err:   ErrTooShort,
+		}
+	}
+	if len(password) > maxLength {
+		return ValidationError{
+			Field: field,
+			Err:   ErrTooLong,
+		}
+	}
+	if _, ok := pp.Breached[string(password)]; ok {
+		return ValidationError{
+			Field: field,
+			Err:   ErrBreachedPassword,
+		}
+	}
+	if similarToEmail(string(password), email) {
+		return ValidationError{
+			Field: field,
+			Err:   ErrSimilarToEmail,
+		}
+	}
+
+	return nil
+}
+
+// similarToEmail reports whether password contains the name part of email or
+// is contained in it.
+func similarToEmail(password, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+	name, _, _ := strings.Cut(email, "@")
+
+	return strings.Contains(email, password) ||
+		(len(name) >= 3 && strings.Contains(password, name))
+}
+
+type UserChangePasswordParams struct {
+	Current []byte
+	New     []byte
+}
+
+// ChangePassword replaces the password of the user, provided params.Current
+// is the password they have now, and revokes their sessions.
+func (us UserService) ChangePassword(ctx context.Context, id int32, params UserChangePasswordParams) error {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+	if len(params.Current) == 0 {
+		return ValidationError{
+			Field: "current_password",
+			Err:   ErrRequired,
+		}
+	}
+
+	user, err := us.Store.Users().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return err
+	}
+	if err := us.Hasher.Verify(user.Password, params.Current); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return ValidationError{
+				Field: "current_password",
+				Err:   ErrWrongPassword,
+			}
+		}
+
+		return err
+	}
+	if err := us.Policy.check("new_password", params.New, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := us.Hasher.Hash(params.New)
+	if err != nil {
+		return err
+	}
+
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		updated := model.User{ID: id, Password: hash}
+		if err := tx.Users().Update(ctx, &updated, "password"); err != nil {
+			return err
+		}
+		if err := tx.RefreshTokens().RevokeByUserID(ctx, id, time.Now()); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "user", id, user, &updated)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RequestPasswordReset mails a single-use token for resetting the password
+// to the user with the given email. It reports success for unknown emails
+// too, so that callers can't tell which emails have an account.
+func (us UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	if len(email) == 0 {
+		return ValidationError{
+			Field: "email",
+			Err:   ErrRequired,
+		}
+	}
+	if !reEmail.MatchString(email) {
+		return ValidationError{
+			Field: "email",
+			Err:   ErrInvalidEmail,
+		}
+	}
+
+	user, err := us.Store.Users().GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+	tracing.SetUserID(ctx, user.ID)
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	ttl := us.ResetTokenTTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+
+	if err := us.Store.PasswordResets().Create(ctx, &model.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	return us.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Use the token below to reset your password. It expires in %s and can only be used once.\n\n"+
+			"%s\n\n"+
+			"If you didn't ask to reset your password, you can ignore this email.\n",
+			user.Name, ttl, token),
+	})
+}
+
+// newToken returns a random token for handing out to a user along with the
+// hash to store in its place.
+func newToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+type UserResetPasswordParams struct {
+	Token    string
+	Password []byte
+}
+
+// ResetPassword sets the password of the user a token from
+// RequestPasswordReset was mailed to, uses the token up and revokes their
+// sessions.
+func (us UserService) ResetPassword(ctx context.Context, params UserResetPasswordParams) error {
+	if len(params.Token) == 0 {
+		return ValidationError{
+			Field: "token",
+			Err:   ErrRequired,
+		}
+	}
+
+	return us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		reset, err := tx.PasswordResets().GetByTokenHash(ctx, hashToken(params.Token))
+		if errors.Is(err, store.ErrNotFound) {
+			return ValidationError{
+				Field: "token",
+				Err:   ErrInvalidToken,
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if reset.UsedAt.Valid || !now.Before(reset.ExpiresAt) {
+			return ValidationError{
+				Field: "token",
+				Err:   ErrInvalidToken,
+			}
+		}
+		tracing.SetUserID(ctx, reset.UserID)
+
+		// Deleted users can't reset their password any more than log in.
+		user, err := tx.Users().GetByID(ctx, reset.UserID)
+		if errors.Is(err, store.ErrNotFound) {
+			return ValidationError{
+				Field: "token",
+				Err:   ErrInvalidToken,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := us.Policy.check("password", params.Password, user.Email); err != nil {
+			return err
+		}
+
+		hash, err := us.Hasher.Hash(params.Password)
+		if err != nil {
+			return err
+		}
+
+		reset.UsedAt = sql.NullTime{Time: now, Valid: true}
+		if err := tx.PasswordResets().Update(ctx, reset); err != nil {
+			return err
+		}
+
+		updated := model.User{ID: reset.UserID, Password: hash}
+		if err := tx.Users().Update(ctx, &updated, "password"); err != nil {
+			return err
+		}
+		if err := tx.RefreshTokens().RevokeByUserID(ctx, reset.UserID, now); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "user", user.ID, user, &updated)
+	})
+}
+
+// Authenticate returns the user with the given email if password is theirs,
+// and ErrInvalidCredentials otherwise. The stored hash is upgraded when it
+// was made with other settings than us.Hasher's.
+func (us UserService) Authenticate(ctx context.Context, email string, pw []byte) (*model.User, error) {
+	if len(email) == 0 || len(pw) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := us.Store.Users().GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+	tracing.SetUserID(ctx, user.ID)
+
+	if err := us.Hasher.Verify(user.Password, pw); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if us.Hasher.NeedsRehash(user.Password) {
+		if err := us.rehash(ctx, user, pw); err != nil {
+			slog.WarnContext(ctx, "Failed to upgrade password hash", "error", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (us UserService) rehash(ctx context.Context, user *model.User, pw []byte) error {
+	hash, err := us.Hasher.Hash(pw)
+	if err != nil {
+		return err
+	}
+
+	updated := model.User{ID: user.ID, Password: hash}
+	if err := us.Store.Users().Update(ctx, &updated, "password"); err != nil {
+		return err
+	}
+
+	*user = updated
+	return nil
+}
@@ -0,0 +1,274 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utilyre/lms/internal/mail"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type outbox []mail.Message
+
+func (o *outbox) Send(_ context.Context, msg mail.Message) error {
+	*o = append(*o, msg)
+	return nil
+}
+
+var reToken = regexp.MustCompile(`(?m)^[\w-]{43}$`)
+
+func requestReset(t *testing.T, us service.UserService, sent *outbox, email string) string {
+	t.Helper()
+	if err := us.RequestPasswordReset(context.Background(), email); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	if len(*sent) == 0 {
+		t.Fatal("no message was sent")
+	}
+
+	msg := (*sent)[len(*sent)-1]
+	if msg.To != email {
+		t.Fatalf("message sent to %q; want %q", msg.To, email)
+	}
+	token := reToken.FindString(msg.Body)
+	if token == "" {
+		t.Fatalf("no token in message:\n%s", msg.Body)
+	}
+
+	return token
+}
+
+func TestUserServiceChangePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int32
+		params  service.UserChangePasswordParams
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			id:     1,
+			params: service.UserChangePasswordParams{Current: []byte("correct horse"), New: []byte("battery staple")},
+		},
+		{
+			name:    "wrong password",
+			id:      1,
+			params:  service.UserChangePasswordParams{Current: []byte("wrong"), New: []byte("battery staple")},
+			wantErr: service.ErrWrongPassword,
+		},
+		{
+			name:    "missing current password",
+			id:      1,
+			params:  service.UserChangePasswordParams{New: []byte("battery staple")},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "short new password",
+			id:      1,
+			params:  service.UserChangePasswordParams{Current: []byte("correct horse"), New: []byte("ab")},
+			wantErr: service.ErrTooShort,
+		},
+		{
+			name:    "long new password",
+			id:      1,
+			params:  service.UserChangePasswordParams{Current: []byte("correct horse"), New: []byte(strings.Repeat("horse", 15))},
+			wantErr: service.ErrTooLong,
+		},
+		{
+			name:    "missing",
+			id:      2,
+			params:  service.UserChangePasswordParams{Current: []byte("correct horse"), New: []byte("battery staple")},
+			wantErr: service.ErrUserNotFound,
+		},
+		{
+			name:    "invalid id",
+			id:      0,
+			params:  service.UserChangePasswordParams{Current: []byte("correct horse"), New: []byte("battery staple")},
+			wantErr: service.ErrInvalidID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newUserService()
+			mustCreateUser(t, us, "jane@example.com")
+
+			err := us.ChangePassword(context.Background(), tt.id, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			user, err := us.GetByID(context.Background(), tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := bcrypt.CompareHashAndPassword(user.Password, tt.params.New); err != nil {
+				t.Errorf("password was not changed: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserServiceResetPassword(t *testing.T) {
+	var sent outbox
+	us := newUserService()
+	us.Mailer = &sent
+	jane := mustCreateUser(t, us, "jane@example.com")
+
+	if err := us.RequestPasswordReset(context.Background(), "john@example.com"); err != nil {
+		t.Fatalf("request reset for unknown email: %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("sent %d messages for an unknown email; want none", len(sent))
+	}
+	if err := us.RequestPasswordReset(context.Background(), "jane"); !errors.Is(err, service.ErrInvalidEmail) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidEmail)
+	}
+
+	token := requestReset(t, us, &sent, jane.Email)
+
+	reset := service.UserResetPasswordParams{Token: token, Password: []byte("battery staple")}
+	if err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+		Token: token, Password: []byte("ab"),
+	}); !errors.Is(err, service.ErrTooShort) {
+		t.Fatalf("err = %v; want %v", err, service.ErrTooShort)
+	}
+	if err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+		Token: token, Password: []byte(strings.Repeat("horse", 15)),
+	}); !errors.Is(err, service.ErrTooLong) {
+		t.Fatalf("err = %v; want %v", err, service.ErrTooLong)
+	}
+	if err := us.ResetPassword(context.Background(), reset); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := us.GetByID(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword(user.Password, reset.Password); err != nil {
+		t.Errorf("password was not reset: %v", err)
+	}
+
+	if err := us.ResetPassword(context.Background(), reset); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("reusing token: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+	if err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+		Token: "bogus", Password: []byte("battery staple"),
+	}); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("unknown token: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
+
+func TestUserServiceResetPasswordExpired(t *testing.T) {
+	var sent outbox
+	us := newUserService()
+	us.Mailer = &sent
+	us.ResetTokenTTL = time.Millisecond
+	jane := mustCreateUser(t, us, "jane@example.com")
+
+	token := requestReset(t, us, &sent, jane.Email)
+	time.Sleep(2 * time.Millisecond)
+
+	err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+		Token: token, Password: []byte("battery staple"),
+	})
+	if !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
+
+func TestUserServiceResetPasswordDeletedUser(t *testing.T) {
+	var sent outbox
+	us := newUserService()
+	us.Mailer = &sent
+	jane := mustCreateUser(t, us, "jane@example.com")
+	john := mustCreateUser(t, us, "john@example.com")
+
+	janes := requestReset(t, us, &sent, jane.Email)
+	if err := us.DeleteByID(context.Background(), jane.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.RestoreByID(context.Background(), jane.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens handed out before deleting users used to outlive them.
+	johns := requestReset(t, us, &sent, john.Email)
+	if err := us.Store.Users().DeleteByID(context.Background(), john.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"restored": janes, "deleted": johns} {
+		err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+			Token: token, Password: []byte("battery staple"),
+		})
+		if !errors.Is(err, service.ErrInvalidToken) {
+			t.Errorf("%s: err = %v; want %v", name, err, service.ErrInvalidToken)
+		}
+	}
+}
+
+func TestUserServiceAuthenticate(t *testing.T) {
+	us := newUserService()
+	jane := mustCreateUser(t, us, "jane@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", email: jane.Email, password: "correct horse"},
+		{name: "wrong password", email: jane.Email, password: "battery staple", wantErr: service.ErrInvalidCredentials},
+		{name: "unknown email", email: "john@example.com", password: "correct horse", wantErr: service.ErrInvalidCredentials},
+		{name: "missing password", email: jane.Email, wantErr: service.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := us.Authenticate(context.Background(), tt.email, []byte(tt.password))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && user.ID != jane.ID {
+				t.Errorf("id = %d; want %d", user.ID, jane.ID)
+			}
+		})
+	}
+}
+
+func TestUserServiceAuthenticateRehashes(t *testing.T) {
+	us := newUserService()
+	jane := mustCreateUser(t, us, "jane@example.com")
+
+	us.Hasher = password.Hasher{
+		Algorithm:     password.Argon2id,
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+	}
+	if _, err := us.Authenticate(context.Background(), jane.Email, []byte("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := us.GetByID(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(user.Password), "$argon2id$") {
+		t.Fatalf("password hash = %s; want it upgraded to argon2id", user.Password)
+	}
+	if _, err := us.Authenticate(context.Background(), jane.Email, []byte("correct horse")); err != nil {
+		t.Fatalf("authenticate with upgraded hash: %v", err)
+	}
+}
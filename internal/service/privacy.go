@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+)
+
+// AnonymizedName is what the names of anonymized users are replaced with.
+const AnonymizedName = "Anonymized user"
+
+// UserExport is everything kept about a user.
+type UserExport struct {
+	User          model.User
+	Loans         []model.Loan
+	Reservations  []model.Reservation
+	APIKeys       []model.APIKey
+	LoginAttempts []model.LoginAttempt
+	// AuditLog holds the entries of actions the user took or that were taken
+	// on them.
+	AuditLog []model.AuditEntry
+}
+
+// Export gathers everything kept about the user, whether they're deleted or
+// not, to answer data subject access requests.
+func (us UserService) Export(ctx context.Context, id int32) (*UserExport, error) {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	var export UserExport
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		user, err := getUserWithDeleted(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		export.User = *user
+
+		if export.Loans, err = tx.Loans().ListByUserID(ctx, id); err != nil {
+			return err
+		}
+		if export.Reservations, err = tx.Reservations().ListByUserID(ctx, id); err != nil {
+			return err
+		}
+		if export.APIKeys, err = tx.APIKeys().ListByUserID(ctx, id); err != nil {
+			return err
+		}
+		if export.LoginAttempts, err = tx.LoginAttempts().ListByUserID(ctx, id); err != nil {
+			return err
+		}
+		if export.AuditLog, err = tx.AuditLog().ListByUserID(ctx, id); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// Anonymize replaces the name, email and password of the user, whether
+// they're deleted or not, so that their loans and reservations can be kept
+// for statistics without identifying them. Their sessions, API keys, password
+// resets and login attempts are removed altogether, and the IP of the actions
+// they took is cleared from the audit log, which never records names or
+// emails.
+func (us UserService) Anonymize(ctx context.Context, id int32) (*model.User, error) {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	// Nobody knows the password, so nobody can log in as the user.
+	secret, _, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := us.Hasher.Hash([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{
+		ID:       id,
+		Name:     AnonymizedName,
+		Email:    fmt.Sprintf("anonymized-%d@lms.invalid", id),
+		Password: hash,
+	}
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := tx.Users().Anonymize(ctx, &user); err != nil {
+			return err
+		}
+		if err := tx.AuditLog().ClearIPByActorID(ctx, id); err != nil {
+			return err
+		}
+
+		// The states around the change would record what it scrubs.
+		return audit.Record(ctx, tx, audit.ActionAnonymize, "user", id, nil, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// getUserWithDeleted returns the user whether they're deleted or not.
+func getUserWithDeleted(ctx context.Context, tx store.Store, id int32) (*model.User, error) {
+	user, err := tx.Users().GetByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return tx.Users().GetDeletedByID(ctx, id)
+	}
+
+	return user, err
+}
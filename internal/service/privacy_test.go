@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/auth"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func TestUserServiceExport(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+	reservation := mustReserve(t, lib, lib.jane)
+	if _, _, err := (service.APIKeyService{Store: lib.st}).Create(context.Background(), lib.jane.ID, service.APIKeyCreateParams{
+		Name:   "Kiosk",
+		Scopes: []string{"books:read"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.users.Authenticate(context.Background(), "jane@example.com", []byte("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := lib.users.Export(context.Background(), lib.jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.User.Email != "jane@example.com" {
+		t.Errorf("user = %+v; want jane", export.User)
+	}
+	if len(export.Loans) != 1 || export.Loans[0].ID != loan.ID {
+		t.Errorf("loans = %+v; want loan %d", export.Loans, loan.ID)
+	}
+	if len(export.Reservations) != 1 || export.Reservations[0].ID != reservation.ID {
+		t.Errorf("reservations = %+v; want reservation %d", export.Reservations, reservation.ID)
+	}
+	if len(export.APIKeys) != 1 || export.APIKeys[0].Name != "Kiosk" {
+		t.Errorf("api keys = %+v; want the kiosk's", export.APIKeys)
+	}
+	if len(export.AuditLog) != 1 || export.AuditLog[0].Entity != "user" || export.AuditLog[0].Action != audit.ActionCreate {
+		t.Errorf("audit log = %+v; want jane's creation", export.AuditLog)
+	}
+
+	if _, err := lib.users.Export(context.Background(), 1000); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
+
+func TestUserServiceExportDeleted(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+	if err := us.DeleteByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := us.Export(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.User.ID != user.ID || export.User.DeletedAt.IsZero() {
+		t.Errorf("user = %+v; want deleted jane", export.User)
+	}
+}
+
+func TestUserServiceAnonymize(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: lib.jane.ID})
+	ctx = audit.WithClientIP(ctx, "10.0.0.1")
+	if _, err := lib.users.PatchByID(ctx, lib.jane.ID, service.UserPatchByIDParams{
+		Name: service.Optional[string]{Set: true, Value: "Jane Roe"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := lib.users.Anonymize(context.Background(), lib.jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != service.AnonymizedName || user.Email == "jane@example.com" {
+		t.Errorf("user = %+v; want them anonymized", user)
+	}
+	if _, err := lib.users.Authenticate(context.Background(), "jane@example.com", []byte("correct horse")); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidCredentials)
+	}
+
+	export, err := lib.users.Export(context.Background(), lib.jane.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Loans) != 1 || export.Loans[0].ID != loan.ID {
+		t.Errorf("loans = %+v; want loan %d kept", export.Loans, loan.ID)
+	}
+	if len(export.LoginAttempts) != 0 || len(export.APIKeys) != 0 {
+		t.Errorf("export = %+v; want no login attempts or API keys", export)
+	}
+	// Jane renamed herself, which she's the actor of.
+	if len(export.AuditLog) != 3 || export.AuditLog[1].ActorID.Int32 != lib.jane.ID {
+		t.Fatalf("audit log = %+v; want jane's creation, renaming and anonymization", export.AuditLog)
+	}
+	for _, entry := range export.AuditLog {
+		if entry.IP != "" {
+			t.Errorf("audit log entry %d has ip %q; want it cleared", entry.ID, entry.IP)
+		}
+	}
+
+	entries, err := service.AuditService{Store: lib.st}.List(context.Background(), service.AuditListParams{
+		Action: audit.ActionAnonymize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Before != nil || entries[0].After != nil {
+		t.Errorf("entries = %+v; want one without the states around it", entries)
+	}
+}
+
+func TestUserServiceAnonymizeDeleted(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+	if err := us.DeleteByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := us.Anonymize(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.Anonymize(context.Background(), 1000); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
@@ -3,72 +3,73 @@ package service
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
-	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/metrics"
 	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ReportService struct {
-	DB  bun.IDB
-	RDB *redis.Client
+	Store store.Store
+	RDB   *redis.Client
 }
 
 var keyOverdueLoans = "overdue-loans"
 
-func (rs ReportService) GetOverdueLoans(ctx context.Context) ([]model.Loan, error) {
+func (rs ReportService) GetOverdueLoans(ctx context.Context) (_ []model.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "ReportService.GetOverdueLoans")
+	defer func() { tracing.End(span, err) }()
+
 	exists, err := rs.RDB.Exists(ctx, keyOverdueLoans).Result()
 	if err != nil {
 		return nil, err
 	}
 	if exists != 0 {
+		metrics.CacheHit(keyOverdueLoans)
+		span.SetAttributes(attribute.Bool("lms.cache_hit", true))
 		data, err := rs.RDB.Get(ctx, keyOverdueLoans).Result()
 		if err != nil {
 			return nil, err
 		}
 
 		var loans []model.Loan
-		if err := json.NewDecoder(strings.NewReader(data)).Decode(&loans); err != nil {
+		if err := decodeCached(ctx, data, &loans); err != nil {
 			return nil, err
 		}
 
-		log.Println("Used cache to respond overdue loans")
+		slog.DebugContext(ctx, "Used cache to respond overdue loans")
 		return loans, nil
 	}
+	metrics.CacheMiss(keyOverdueLoans)
+	span.SetAttributes(attribute.Bool("lms.cache_hit", false))
 
-	var loans []model.Loan
-	if err := rs.DB.
-		NewSelect().
-		Model(&loans).
-		// WHERE return_date IS NULL AND NOW() > due_date OR return_date > due_date
-		Where("return_date IS NULL").
-		WhereGroup("AND", func(sq *bun.SelectQuery) *bun.SelectQuery {
-			return sq.Where("NOW() > due_date")
-		}).
-		WhereOr("return_date > due_date").
-		Scan(ctx); err != nil {
+	loans, err := rs.Store.Loans().ListOverdue(ctx, time.Now())
+	if err != nil {
 		return nil, err
 	}
 
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 4*time.Second)
 		defer cancel()
 
-		data, err := json.Marshal(loans)
+		data, err := encodeCached(ctx, loans)
 		if err != nil {
-			log.Println("Failed to marshal overdue loans:", err)
+			slog.ErrorContext(ctx, "Failed to marshal overdue loans", "error", err)
 			return
 		}
 
 		if err := rs.RDB.Set(ctx, keyOverdueLoans, data, time.Hour).Err(); err != nil {
-			log.Println("Failed to set overdue loans in cache:", err)
+			slog.ErrorContext(ctx, "Failed to set overdue loans in cache", "error", err)
 			return
 		}
 
-		log.Println("Cached overdue loans")
+		slog.DebugContext(ctx, "Cached overdue loans")
 	}()
 
 	return loans, nil
@@ -84,61 +85,70 @@ var (
 	keyPopularBooks = "popular-books"
 )
 
-func (rs ReportService) GetPopularBooks(ctx context.Context) ([]ReportGetPopularBooksResult, error) {
+func (rs ReportService) GetPopularBooks(ctx context.Context) (_ []ReportGetPopularBooksResult, err error) {
+	ctx, span := tracing.Start(ctx, "ReportService.GetPopularBooks")
+	defer func() { tracing.End(span, err) }()
+
 	exists, err := rs.RDB.Exists(ctx, keyPopularBooks).Result()
 	if err != nil {
 		return nil, err
 	}
 	if exists != 0 {
+		metrics.CacheHit(keyPopularBooks)
+		span.SetAttributes(attribute.Bool("lms.cache_hit", true))
 		data, err := rs.RDB.Get(ctx, keyPopularBooks).Result()
 		if err != nil {
 			return nil, err
 		}
 
 		var results []ReportGetPopularBooksResult
-		if err := json.NewDecoder(strings.NewReader(data)).Decode(&results); err != nil {
+		if err := decodeCached(ctx, data, &results); err != nil {
 			return nil, err
 		}
 
-		log.Println("Used cache to respond popular books")
+		slog.DebugContext(ctx, "Used cache to respond popular books")
 		return results, nil
 	}
+	metrics.CacheMiss(keyPopularBooks)
+	span.SetAttributes(attribute.Bool("lms.cache_hit", false))
 
-	var results []ReportGetPopularBooksResult
-	if err := rs.DB.
-		NewSelect().
-		Model((*model.Book)(nil)).
-		ColumnExpr("book.id id, book.title title, COUNT(*) borrows").
-		Join("JOIN loans loan ON loan.book_id = book.id").
-		Group("book.id").
-		Order("borrows DESC").
-		Limit(10).
-		Scan(ctx, &results); err != nil {
+	borrows, err := rs.Store.Loans().MostBorrowed(ctx, 10)
+	if err != nil {
 		return nil, err
 	}
 
+	results := make([]ReportGetPopularBooksResult, len(borrows))
+	for i, b := range borrows {
+		results[i] = ReportGetPopularBooksResult{
+			ID:      b.ID,
+			Title:   b.Title,
+			Borrows: b.Borrows,
+		}
+	}
+
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 4*time.Second)
 		defer cancel()
 
-		data, err := json.Marshal(results)
+		data, err := encodeCached(ctx, results)
 		if err != nil {
-			log.Println("Failed to marshal popular book:", err)
+			slog.ErrorContext(ctx, "Failed to marshal popular books", "error", err)
 			return
 		}
 
 		if err := rs.RDB.Set(ctx, keyPopularBooks, data, 24*time.Hour).Err(); err != nil {
-			log.Println("Failed to set popular books in cache:", err)
+			slog.ErrorContext(ctx, "Failed to set popular books in cache", "error", err)
 			return
 		}
 
-		log.Println("Cached popular books")
+		slog.DebugContext(ctx, "Cached popular books")
 	}()
 
 	return results, nil
 }
 
 func (rs ReportService) GetUserActivity(ctx context.Context, id int32) ([]model.Loan, error) {
+	tracing.SetUserID(ctx, id)
 	if id < 1 {
 		return nil, ValidationError{
 			Field: "id",
@@ -146,16 +156,24 @@ func (rs ReportService) GetUserActivity(ctx context.Context, id int32) ([]model.
 		}
 	}
 
-	var loans []model.Loan
-
-	if err := rs.DB.
-		NewSelect().
-		Column("id", "book_id", "loan_date", "due_date", "return_date").
-		Model(&loans).
-		Where("user_id = ?", id).
-		Scan(ctx); err != nil {
+	loans, err := rs.Store.Loans().ListByUserID(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 
 	return loans, nil
 }
+
+func decodeCached(ctx context.Context, data string, v any) (err error) {
+	_, span := tracing.Start(ctx, "json.Decode")
+	defer func() { tracing.End(span, err) }()
+
+	return json.NewDecoder(strings.NewReader(data)).Decode(v)
+}
+
+func encodeCached(ctx context.Context, v any) (_ []byte, err error) {
+	_, span := tracing.Start(ctx, "json.Encode")
+	defer func() { tracing.End(span, err) }()
+
+	return json.Marshal(v)
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/service"
+)
+
+func newReportService(t *testing.T, lib library) (service.ReportService, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	return service.ReportService{Store: lib.st, RDB: rdb}, mr
+}
+
+func mustCreateLoan(t *testing.T, lib library, loan model.Loan) model.Loan {
+	t.Helper()
+	if err := lib.st.Loans().Create(context.Background(), &loan); err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+
+	return loan
+}
+
+func waitForKey(t *testing.T, mr *miniredis.Miniredis, key string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !mr.Exists(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("key %q was never cached", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func loanIDs(loans []model.Loan) []int32 {
+	ids := make([]int32, len(loans))
+	for i, loan := range loans {
+		ids[i] = loan.ID
+	}
+
+	return ids
+}
+
+func TestReportServiceGetOverdueLoans(t *testing.T) {
+	lib := newLibrary(t)
+	rs, mr := newReportService(t, lib)
+
+	now := time.Now()
+	day := 24 * time.Hour
+	overdue := mustCreateLoan(t, lib, model.Loan{
+		UserID: lib.jane.ID, BookID: lib.book.ID,
+		LoanDate: now.Add(-20 * day), DueDate: now.Add(-6 * day),
+	})
+	returnedLate := mustCreateLoan(t, lib, model.Loan{
+		UserID: lib.jane.ID, BookID: lib.book.ID,
+		LoanDate: now.Add(-40 * day), DueDate: now.Add(-26 * day),
+		ReturnDate: sql.NullTime{Time: now.Add(-25 * day), Valid: true},
+	})
+	mustCreateLoan(t, lib, model.Loan{
+		UserID: lib.john.ID, BookID: lib.book.ID,
+		LoanDate: now.Add(-60 * day), DueDate: now.Add(-46 * day),
+		ReturnDate: sql.NullTime{Time: now.Add(-50 * day), Valid: true},
+	})
+	mustCreateLoan(t, lib, model.Loan{
+		UserID: lib.john.ID, BookID: lib.book.ID,
+		LoanDate: now, DueDate: now.Add(14 * day),
+	})
+	want := []int32{overdue.ID, returnedLate.ID}
+
+	loans, err := rs.GetOverdueLoans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loanIDs(loans); !slices.Equal(got, want) {
+		t.Fatalf("overdue loans = %v; want %v", got, want)
+	}
+
+	waitForKey(t, mr, "overdue-loans")
+	mustCreateLoan(t, lib, model.Loan{
+		UserID: lib.john.ID, BookID: lib.book.ID,
+		LoanDate: now.Add(-30 * day), DueDate: now.Add(-16 * day),
+	})
+
+	cached, err := rs.GetOverdueLoans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loanIDs(cached); !slices.Equal(got, want) {
+		t.Fatalf("cached overdue loans = %v; want %v", got, want)
+	}
+}
+
+func TestReportServiceGetOverdueLoansCacheDown(t *testing.T) {
+	lib := newLibrary(t)
+	rs, mr := newReportService(t, lib)
+	mr.Close()
+
+	if _, err := rs.GetOverdueLoans(context.Background()); err == nil {
+		t.Fatal("err = nil; want cache error")
+	}
+}
+
+func TestReportServiceGetPopularBooks(t *testing.T) {
+	lib := newLibrary(t)
+	rs, mr := newReportService(t, lib)
+
+	var books []*model.Book
+	for range 12 {
+		books = append(books, mustCreateBook(t, lib.books))
+	}
+	for i, book := range books {
+		for range i % 4 {
+			mustCreateLoan(t, lib, model.Loan{UserID: lib.jane.ID, BookID: book.ID})
+		}
+	}
+
+	results, err := rs.GetPopularBooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 9 {
+		t.Fatalf("len(results) = %d; want 9 borrowed books", len(results))
+	}
+	if results[0].ID != books[3].ID || results[0].Borrows != 3 {
+		t.Errorf("results[0] = %+v; want book %d with 3 borrows", results[0], books[3].ID)
+	}
+	if !slices.IsSortedFunc(results, func(a, b service.ReportGetPopularBooksResult) int {
+		return b.Borrows - a.Borrows
+	}) {
+		t.Errorf("results = %+v; want them sorted by borrows", results)
+	}
+
+	waitForKey(t, mr, "popular-books")
+	cached, err := rs.GetPopularBooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cached, results) {
+		t.Errorf("cached results = %+v; want %+v", cached, results)
+	}
+}
+
+func TestReportServiceGetUserActivity(t *testing.T) {
+	lib := newLibrary(t)
+	rs, _ := newReportService(t, lib)
+
+	first := mustBorrow(t, lib, lib.jane)
+	mustBorrow(t, lib, lib.john)
+	second := mustBorrow(t, lib, lib.jane)
+
+	tests := []struct {
+		name    string
+		id      int32
+		want    []int32
+		wantErr error
+	}{
+		{name: "active user", id: lib.jane.ID, want: []int32{first.ID, second.ID}},
+		{name: "unknown user", id: 1000, want: []int32{}},
+		{name: "invalid id", id: 0, wantErr: service.ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loans, err := rs.GetUserActivity(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if got := loanIDs(loans); tt.wantErr == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("loans = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
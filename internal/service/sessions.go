@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
+)
+
+// SessionService hands out refresh tokens, which let users get new access
+// tokens without logging in again. Every refresh token can be used once, and
+// is then replaced by another one of the same family, i.e. session. Using a
+// token twice means it was stolen, so the whole session is revoked.
+type SessionService struct {
+	Store store.Store
+	// TTL is how long refresh tokens are valid. Zero means 30 days.
+	TTL time.Duration
+}
+
+func (ss SessionService) ttl() time.Duration {
+	if ss.TTL == 0 {
+		return 30 * 24 * time.Hour
+	}
+
+	return ss.TTL
+}
+
+// Start begins a new session of the user and returns its first refresh
+// token.
+func (ss SessionService) Start(ctx context.Context, userID int32) (string, error) {
+	familyID := make([]byte, 16)
+	if _, err := rand.Read(familyID); err != nil {
+		return "", err
+	}
+
+	return ss.issue(ctx, ss.Store, userID, familyID)
+}
+
+func (ss SessionService) issue(ctx context.Context, tx store.Store, userID int32, familyID []byte) (string, error) {
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if err := tx.RefreshTokens().Create(ctx, &model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ss.ttl()),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for its successor and returns the user
+// it belongs to. It returns ErrInvalidToken when the token is unknown,
+// expired, revoked or already used.
+func (ss SessionService) Refresh(ctx context.Context, token string) (*model.User, string, error) {
+	if len(token) == 0 {
+		return nil, "", ValidationError{
+			Field: "refresh_token",
+			Err:   ErrRequired,
+		}
+	}
+
+	var (
+		user      *model.User
+		successor string
+		reused    bool
+	)
+	if err := ss.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		user, successor, reused = nil, "", false
+
+		rt, err := tx.RefreshTokens().GetByTokenHash(ctx, hashToken(token))
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		tracing.SetUserID(ctx, rt.UserID)
+
+		now := time.Now()
+		if rt.RevokedAt.Valid || !now.Before(rt.ExpiresAt) {
+			return ErrInvalidToken
+		}
+		if rt.UsedAt.Valid {
+			reused = true
+			return tx.RefreshTokens().RevokeByFamilyID(ctx, rt.FamilyID, now)
+		}
+
+		rt.UsedAt = sql.NullTime{Time: now, Valid: true}
+		if err := tx.RefreshTokens().Update(ctx, rt); err != nil {
+			return err
+		}
+
+		user, err = tx.Users().GetByID(ctx, rt.UserID)
+		if err != nil {
+			return err
+		}
+
+		successor, err = ss.issue(ctx, tx, rt.UserID, rt.FamilyID)
+		return err
+	}); err != nil {
+		return nil, "", err
+	}
+
+	if reused {
+		slog.WarnContext(ctx, "Refresh token was reused, revoked its session")
+		return nil, "", ErrInvalidToken
+	}
+
+	return user, successor, nil
+}
+
+// End revokes the session a refresh token belongs to. It returns
+// ErrInvalidToken when the token is unknown.
+func (ss SessionService) End(ctx context.Context, token string) error {
+	if len(token) == 0 {
+		return ValidationError{
+			Field: "refresh_token",
+			Err:   ErrRequired,
+		}
+	}
+
+	rt, err := ss.Store.RefreshTokens().GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrInvalidToken
+		}
+
+		return err
+	}
+	tracing.SetUserID(ctx, rt.UserID)
+
+	return ss.Store.RefreshTokens().RevokeByFamilyID(ctx, rt.FamilyID, time.Now())
+}
+
+// RevokeAll revokes every session of the user. Access tokens that were
+// already issued stay valid until they expire.
+func (ss SessionService) RevokeAll(ctx context.Context, userID int32) error {
+	tracing.SetUserID(ctx, userID)
+	if userID < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	return ss.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if _, err := tx.Users().GetByID(ctx, userID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return ErrUserNotFound
+			}
+
+			return err
+		}
+
+		return tx.RefreshTokens().RevokeByUserID(ctx, userID, time.Now())
+	})
+}
@@ -0,0 +1,169 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/utilyre/lms/internal/service"
+)
+
+func newSessionService(t *testing.T) (service.SessionService, service.UserService) {
+	t.Helper()
+	us := newUserService()
+	mustCreateUser(t, us, "jane@example.com")
+	return service.SessionService{Store: us.Store}, us
+}
+
+func mustStart(t *testing.T, ss service.SessionService, userID int32) string {
+	t.Helper()
+	token, err := ss.Start(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	return token
+}
+
+func TestSessionServiceRefreshRotates(t *testing.T) {
+	ss, _ := newSessionService(t)
+	token := mustStart(t, ss, 1)
+
+	user, successor, err := ss.Refresh(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 {
+		t.Errorf("id = %d; want 1", user.ID)
+	}
+	if successor == "" || successor == token {
+		t.Fatalf("successor = %q; want a new token", successor)
+	}
+
+	if _, _, err := ss.Refresh(context.Background(), successor); err != nil {
+		t.Fatalf("refresh with successor: %v", err)
+	}
+}
+
+func TestSessionServiceRefreshDetectsReuse(t *testing.T) {
+	ss, _ := newSessionService(t)
+	token := mustStart(t, ss, 1)
+	other := mustStart(t, ss, 1)
+
+	_, successor, err := ss.Refresh(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("reuse: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+	if _, _, err := ss.Refresh(context.Background(), successor); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("successor of reused token: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+	if _, _, err := ss.Refresh(context.Background(), other); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+}
+
+func TestSessionServiceRefreshInvalid(t *testing.T) {
+	ss, _ := newSessionService(t)
+	ss.TTL = time.Millisecond
+	token := mustStart(t, ss, 1)
+	time.Sleep(2 * time.Millisecond)
+
+	if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("expired: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+	if _, _, err := ss.Refresh(context.Background(), "bogus"); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("unknown: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+	if _, _, err := ss.Refresh(context.Background(), ""); !errors.Is(err, service.ErrRequired) {
+		t.Fatalf("missing: err = %v; want %v", err, service.ErrRequired)
+	}
+}
+
+func TestSessionServiceEnd(t *testing.T) {
+	ss, _ := newSessionService(t)
+	token := mustStart(t, ss, 1)
+
+	_, successor, err := ss.Refresh(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.End(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Refresh(context.Background(), successor); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidToken)
+	}
+
+	if err := ss.End(context.Background(), "bogus"); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("unknown: err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
+
+func TestSessionServiceRevokeAll(t *testing.T) {
+	ss, us := newSessionService(t)
+	tokens := []string{mustStart(t, ss, 1), mustStart(t, ss, 1)}
+	john := mustCreateUser(t, us, "john@example.com")
+	johns := mustStart(t, ss, john.ID)
+
+	if err := ss.RevokeAll(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	for i, token := range tokens {
+		if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+			t.Errorf("session %d: err = %v; want %v", i, err, service.ErrInvalidToken)
+		}
+	}
+	if _, _, err := ss.Refresh(context.Background(), johns); err != nil {
+		t.Errorf("another user's session: %v", err)
+	}
+
+	if err := ss.RevokeAll(context.Background(), 3); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
+
+func TestUserServiceDeleteRevokesSessions(t *testing.T) {
+	ss, us := newSessionService(t)
+	token := mustStart(t, ss, 1)
+
+	if err := us.DeleteByID(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
+
+func TestUserServiceChangePasswordRevokesSessions(t *testing.T) {
+	ss, us := newSessionService(t)
+	token := mustStart(t, ss, 1)
+
+	if err := us.ChangePassword(context.Background(), 1, service.UserChangePasswordParams{
+		Current: []byte("correct horse"), New: []byte("battery staple"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
+
+func TestUserServiceResetPasswordRevokesSessions(t *testing.T) {
+	var sent outbox
+	ss, us := newSessionService(t)
+	us.Mailer = &sent
+	token := mustStart(t, ss, 1)
+
+	if err := us.ResetPassword(context.Background(), service.UserResetPasswordParams{
+		Token: requestReset(t, us, &sent, "jane@example.com"), Password: []byte("battery staple"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Refresh(context.Background(), token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidToken)
+	}
+}
@@ -2,25 +2,29 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"time"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/audit"
+	"github.com/utilyre/lms/internal/mail"
 	"github.com/utilyre/lms/internal/model"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/tracing"
 )
 
 var (
-	ErrRequired     = errors.New("required")
-	ErrTooShort     = errors.New("too short")
-	ErrInvalidEmail = errors.New("invalid email")
-	ErrInvalidID    = errors.New("invalid id")
-	ErrUserNotFound = errors.New("user not found")
-	ErrUserDup      = errors.New("user duplication")
+	ErrRequired        = errors.New("required")
+	ErrTooShort        = errors.New("too short")
+	ErrInvalidEmail    = errors.New("invalid email")
+	ErrInvalidID       = errors.New("invalid id")
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUserDup         = errors.New("user duplication")
+	ErrNotDeleted      = errors.New("not deleted")
 )
 
 type ValidationError struct {
@@ -37,16 +41,29 @@ func (ve ValidationError) Unwrap() error {
 }
 
 type UserService struct {
-	DB bun.IDB
+	Store store.Store
+	// Hasher hashes new passwords. Existing hashes are upgraded to its
+	// settings when their users log in.
+	Hasher password.Hasher
+	Policy PasswordPolicy
+	// Mailer delivers password reset tokens.
+	Mailer mail.Mailer
+	// ResetTokenTTL is how long password reset tokens stay valid. Zero means
+	// an hour.
+	ResetTokenTTL time.Duration
 }
 
 type UserCreateParams struct {
 	Name     string
 	Email    string
 	Password []byte
-	Role     string
+	// Role defaults to DefaultRole when empty.
+	Role string
 }
 
+// DefaultRole is the role of users created without one.
+const DefaultRole = "member"
+
 var reEmail = regexp.MustCompile(`^[^@]+@[^@]+\.[^@]+$`)
 
 func (us UserService) Create(ctx context.Context, params UserCreateParams) (*model.User, error) {
@@ -68,14 +85,11 @@ func (us UserService) Create(ctx context.Context, params UserCreateParams) (*mod
 			Err:   ErrInvalidEmail,
 		}
 	}
-	if len(params.Password) < 3 {
-		return nil, ValidationError{
-			Field: "password",
-			Err:   ErrTooShort,
-		}
+	if err := us.Policy.check("password", params.Password, params.Email); err != nil {
+		return nil, err
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+	hash, err := us.Hasher.Hash(params.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -86,11 +100,18 @@ func (us UserService) Create(ctx context.Context, params UserCreateParams) (*mod
 		Password: hash,
 		Role:     params.Role,
 	}
+	if user.Role == "" {
+		user.Role = DefaultRole
+	}
 
-	_, err = us.DB.NewInsert().Model(&user).Exec(ctx)
-	if err != nil {
-		if pgErr := (pgdriver.Error{}); errors.As(err, &pgErr) &&
-			pgErr.Field('C') == pgerrcode.UniqueViolation {
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		if err := tx.Users().Create(ctx, &user); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionCreate, "user", user.ID, nil, &user)
+	}); err != nil {
+		if errors.Is(err, store.ErrConflict) {
 			return nil, ErrUserDup
 		}
 
@@ -101,6 +122,7 @@ func (us UserService) Create(ctx context.Context, params UserCreateParams) (*mod
 }
 
 func (us UserService) GetByID(ctx context.Context, id int32) (*model.User, error) {
+	tracing.SetUserID(ctx, id)
 	if id < 1 {
 		return nil, ValidationError{
 			Field: "id",
@@ -108,29 +130,30 @@ func (us UserService) GetByID(ctx context.Context, id int32) (*model.User, error
 		}
 	}
 
-	var user model.User
-	if err := us.DB.
-		NewSelect().
-		Model(&user).
-		Where("id = ?", id).
-		Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	user, err := us.Store.Users().GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, ErrUserNotFound
 		}
 
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 type UserUpdateByIDParams struct {
 	Name  string
 	Email string
-	Role  string
+	// Role, when empty, leaves that of the user alone.
+	Role string
+	// Versions, unless empty, lists the versions the user must be at for the
+	// update to go through.
+	Versions []int32
 }
 
 func (us UserService) UpdateByID(ctx context.Context, id int32, params UserUpdateByIDParams) (*model.User, error) {
+	tracing.SetUserID(ctx, id)
 	if id < 1 {
 		return nil, ValidationError{
 			Field: "id",
@@ -156,32 +179,230 @@ func (us UserService) UpdateByID(ctx context.Context, id int32, params UserUpdat
 		}
 	}
 
-	user := model.User{
-		Name:  params.Name,
-		Email: params.Email,
-		Role:  params.Role,
+	var user model.User
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getUserAtVersion(ctx, tx, id, params.Versions)
+		if err != nil {
+			return err
+		}
+
+		user = model.User{
+			ID:    id,
+			Name:  params.Name,
+			Email: params.Email,
+			Role:  params.Role,
+		}
+		if err := tx.Users().Update(ctx, &user); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "user", id, before, &user)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(err, store.ErrConflict) {
+			return nil, ErrUserDup
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+type UserPatchByIDParams struct {
+	Name     Optional[string]
+	Email    Optional[string]
+	Role     Optional[string]
+	Versions []int32
+}
+
+// PatchByID updates only the fields set in params. Name and email can't be
+// cleared, whereas clearing the role empties it.
+func (us UserService) PatchByID(ctx context.Context, id int32, params UserPatchByIDParams) (*model.User, error) {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	patch := model.User{ID: id}
+	var columns []string
+	if params.Name.Set {
+		if params.Name.Null || len(params.Name.Value) == 0 {
+			return nil, ValidationError{
+				Field: "name",
+				Err:   ErrRequired,
+			}
+		}
+
+		patch.Name = params.Name.Value
+		columns = append(columns, "name")
+	}
+	if params.Email.Set {
+		if params.Email.Null || len(params.Email.Value) == 0 {
+			return nil, ValidationError{
+				Field: "email",
+				Err:   ErrRequired,
+			}
+		}
+		if !reEmail.MatchString(params.Email.Value) {
+			return nil, ValidationError{
+				Field: "email",
+				Err:   ErrInvalidEmail,
+			}
+		}
+
+		patch.Email = params.Email.Value
+		columns = append(columns, "email")
+	}
+	if params.Role.Set {
+		patch.Role = params.Role.Value
+		columns = append(columns, "role")
 	}
 
-	if _, err := us.DB.
-		NewUpdate().
-		Model(&user).
-		OmitZero().
-		Where("id = ?", id).
-		Exec(ctx); err != nil {
+	var user *model.User
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getUserAtVersion(ctx, tx, id, params.Versions)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			user = before
+			return nil
+		}
+
+		updated := patch
+		user = &updated
+		if err := tx.Users().Update(ctx, user, columns...); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUpdate, "user", id, before, user)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(err, store.ErrConflict) {
+			return nil, ErrUserDup
+		}
+
 		return nil, err
 	}
-	if err := us.DB.
-		NewSelect().
-		Model(&user).
-		Where("id = ?", id).
-		Scan(ctx); err != nil {
+
+	return user, nil
+}
+
+// DeleteByID deletes the user, provided it's at one of versions when any are
+// given, and revokes their sessions. Deleted users keep their loans and can
+// be restored until they're purged. Users with open loans or active
+// reservations aren't deleted, failing with InUseError.
+func (us UserService) DeleteByID(ctx context.Context, id int32, versions ...int32) error {
+	return us.deleteByID(ctx, id, false, versions)
+}
+
+// ForceDeleteByID deletes the user like DeleteByID, except that it returns
+// their open loans and cancels their active reservations first.
+func (us UserService) ForceDeleteByID(ctx context.Context, id int32, versions ...int32) error {
+	return us.deleteByID(ctx, id, true, versions)
+}
+
+func (us UserService) deleteByID(ctx context.Context, id int32, force bool, versions []int32) error {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getUserAtVersion(ctx, tx, id, versions)
+		if err != nil {
+			return err
+		}
+
+		loans, err := tx.Loans().ListOpenByUserID(ctx, id)
+		if err != nil {
+			return err
+		}
+		reservations, err := tx.Reservations().ListActiveByUserID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := closeOut(ctx, tx, loans, reservations, force); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.RefreshTokens().RevokeByUserID(ctx, id, now); err != nil {
+			return err
+		}
+		if err := tx.PasswordResets().UseUpByUserID(ctx, id, now); err != nil {
+			return err
+		}
+		if err := tx.Users().DeleteByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionDelete, "user", id, before, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RestoreByID undoes the deletion of the user, failing with ErrUserDup when
+// another user has taken their email since.
+func (us UserService) RestoreByID(ctx context.Context, id int32) (*model.User, error) {
+	tracing.SetUserID(ctx, id)
+	if id < 1 {
+		return nil, ValidationError{
+			Field: "id",
+			Err:   ErrInvalidID,
+		}
+	}
+
+	var user *model.User
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getDeletedUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Users().Restore(ctx, id); err != nil {
+			return err
+		}
+		if user, err = tx.Users().GetByID(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionRestore, "user", id, before, user)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(err, store.ErrConflict) {
+			return nil, ErrUserDup
+		}
+
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (us UserService) DeleteByID(ctx context.Context, id int32) error {
+// PurgeByID permanently removes the deleted user along with their loans,
+// reservations, sessions and API keys.
+func (us UserService) PurgeByID(ctx context.Context, id int32) error {
+	tracing.SetUserID(ctx, id)
 	if id < 1 {
 		return ValidationError{
 			Field: "id",
@@ -189,13 +410,53 @@ func (us UserService) DeleteByID(ctx context.Context, id int32) error {
 		}
 	}
 
-	if _, err := us.DB.
-		NewDelete().
-		Model((*model.User)(nil)).
-		Where("id = ?", id).
-		Exec(ctx); err != nil {
+	if err := us.Store.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		before, err := getDeletedUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Users().Purge(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionPurge, "user", id, before, nil)
+	}); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
 		return err
 	}
 
 	return nil
 }
+
+// getDeletedUser returns the deleted user, failing with ErrNotDeleted when
+// the user exists but isn't deleted.
+func getDeletedUser(ctx context.Context, tx store.Store, id int32) (*model.User, error) {
+	user, err := tx.Users().GetDeletedByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		if _, err := tx.Users().GetByID(ctx, id); err == nil {
+			return nil, ErrNotDeleted
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// getUserAtVersion returns the user, failing with ErrVersionMismatch unless
+// versions is empty or contains its current version.
+func getUserAtVersion(ctx context.Context, tx store.Store, id int32, versions []int32) (*model.User, error) {
+	user, err := tx.Users().GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 && !slices.Contains(versions, user.Version) {
+		return nil, ErrVersionMismatch
+	}
+
+	return user, nil
+}
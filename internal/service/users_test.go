@@ -0,0 +1,490 @@
+package service_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/password"
+	"github.com/utilyre/lms/internal/service"
+	"github.com/utilyre/lms/internal/store/memstore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newUserService() service.UserService {
+	return service.UserService{
+		Store:  memstore.New(),
+		Hasher: password.Hasher{BcryptCost: bcrypt.MinCost},
+		Policy: service.PasswordPolicy{
+			Breached: map[string]struct{}{"password1": {}},
+		},
+	}
+}
+
+func mustCreateUser(t *testing.T, us service.UserService, email string) *model.User {
+	t.Helper()
+	user, err := us.Create(context.Background(), service.UserCreateParams{
+		Name:     "Jane Doe",
+		Email:    email,
+		Password: []byte("correct horse"),
+		Role:     "member",
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	return user
+}
+
+func TestUserServiceCreate(t *testing.T) {
+	valid := service.UserCreateParams{
+		Name:     "Jane Doe",
+		Email:    "jane@example.com",
+		Password: []byte("correct horse"),
+		Role:     "member",
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, us service.UserService)
+		params  func(p service.UserCreateParams) service.UserCreateParams
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			params: func(p service.UserCreateParams) service.UserCreateParams { return p },
+		},
+		{
+			name: "default role",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Role = ""
+				return p
+			},
+		},
+		{
+			name: "missing name",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Name = ""
+				return p
+			},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name: "missing email",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Email = ""
+				return p
+			},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name: "invalid email",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Email = "jane"
+				return p
+			},
+			wantErr: service.ErrInvalidEmail,
+		},
+		{
+			name: "short password",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Password = []byte("ab")
+				return p
+			},
+			wantErr: service.ErrTooShort,
+		},
+		{
+			name: "long password",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Password = []byte(strings.Repeat("horse", 15))
+				return p
+			},
+			wantErr: service.ErrTooLong,
+		},
+		{
+			name: "breached password",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Password = []byte("password1")
+				return p
+			},
+			wantErr: service.ErrBreachedPassword,
+		},
+		{
+			name: "password contains email name",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Password = []byte("Jane1234!")
+				return p
+			},
+			wantErr: service.ErrSimilarToEmail,
+		},
+		{
+			name: "password is part of email",
+			params: func(p service.UserCreateParams) service.UserCreateParams {
+				p.Password = []byte("example.com")
+				return p
+			},
+			wantErr: service.ErrSimilarToEmail,
+		},
+		{
+			name: "duplicate email",
+			setup: func(t *testing.T, us service.UserService) {
+				mustCreateUser(t, us, "jane@example.com")
+			},
+			params:  func(p service.UserCreateParams) service.UserCreateParams { return p },
+			wantErr: service.ErrUserDup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newUserService()
+			if tt.setup != nil {
+				tt.setup(t, us)
+			}
+
+			params := tt.params(valid)
+			user, err := us.Create(context.Background(), params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if user.ID < 1 {
+				t.Errorf("id = %d; want positive", user.ID)
+			}
+			if user.Email != params.Email {
+				t.Errorf("email = %q; want %q", user.Email, params.Email)
+			}
+			if wantRole := cmp.Or(params.Role, service.DefaultRole); user.Role != wantRole {
+				t.Errorf("role = %q; want %q", user.Role, wantRole)
+			}
+			if err := bcrypt.CompareHashAndPassword(user.Password, params.Password); err != nil {
+				t.Errorf("password is not hashed correctly: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserServiceGetByID(t *testing.T) {
+	us := newUserService()
+	created := mustCreateUser(t, us, "jane@example.com")
+
+	tests := []struct {
+		name    string
+		id      int32
+		wantErr error
+	}{
+		{name: "existing", id: created.ID},
+		{name: "invalid id", id: 0, wantErr: service.ErrInvalidID},
+		{name: "missing", id: created.ID + 1, wantErr: service.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := us.GetByID(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && user.Email != created.Email {
+				t.Errorf("email = %q; want %q", user.Email, created.Email)
+			}
+		})
+	}
+}
+
+func TestUserServiceUpdateByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      func(jane, john *model.User) int32
+		params  service.UserUpdateByIDParams
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com", Role: "admin"},
+		},
+		{
+			name:   "role left alone",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com"},
+		},
+		{
+			name:    "invalid id",
+			id:      func(_, _ *model.User) int32 { return -1 },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com"},
+			wantErr: service.ErrInvalidID,
+		},
+		{
+			name:   "current version",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com", Role: "member", Versions: []int32{1}},
+		},
+		{
+			name:    "stale version",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com", Versions: []int32{2}},
+			wantErr: service.ErrVersionMismatch,
+		},
+		{
+			name:    "missing",
+			id:      func(_, john *model.User) int32 { return john.ID + 1 },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe@example.com"},
+			wantErr: service.ErrUserNotFound,
+		},
+		{
+			name:    "missing name",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserUpdateByIDParams{Email: "roe@example.com"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "missing email",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe"},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "invalid email",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe", Email: "roe"},
+			wantErr: service.ErrInvalidEmail,
+		},
+		{
+			name:    "email taken",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserUpdateByIDParams{Name: "Jane Roe", Email: "john@example.com"},
+			wantErr: service.ErrUserDup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newUserService()
+			jane := mustCreateUser(t, us, "jane@example.com")
+			john := mustCreateUser(t, us, "john@example.com")
+
+			user, err := us.UpdateByID(context.Background(), tt.id(jane, john), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if user.Name != tt.params.Name || user.Email != tt.params.Email || user.Role != cmp.Or(tt.params.Role, jane.Role) {
+				t.Errorf("user = %+v; want fields of %+v", user, tt.params)
+			}
+			if len(user.Password) == 0 {
+				t.Error("password was cleared by update")
+			}
+			if user.Version != 2 {
+				t.Errorf("version = %d; want 2", user.Version)
+			}
+		})
+	}
+}
+
+func TestUserServicePatchByID(t *testing.T) {
+	set := func(v string) service.Optional[string] {
+		return service.Optional[string]{Set: true, Value: v}
+	}
+	null := service.Optional[string]{Set: true, Null: true}
+
+	tests := []struct {
+		name    string
+		id      func(jane, john *model.User) int32
+		params  service.UserPatchByIDParams
+		want    func(jane *model.User) model.User
+		wantErr error
+	}{
+		{
+			name:   "name only",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserPatchByIDParams{Name: set("Jane Roe")},
+			want: func(jane *model.User) model.User {
+				return model.User{Name: "Jane Roe", Email: jane.Email, Role: jane.Role}
+			},
+		},
+		{
+			name:   "clear role",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserPatchByIDParams{Role: null},
+			want: func(jane *model.User) model.User {
+				return model.User{Name: jane.Name, Email: jane.Email}
+			},
+		},
+		{
+			name:   "empty patch",
+			id:     func(jane, _ *model.User) int32 { return jane.ID },
+			params: service.UserPatchByIDParams{},
+			want: func(jane *model.User) model.User {
+				return model.User{Name: jane.Name, Email: jane.Email, Role: jane.Role}
+			},
+		},
+		{
+			name:    "stale version",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserPatchByIDParams{Versions: []int32{2}},
+			wantErr: service.ErrVersionMismatch,
+		},
+		{
+			name:    "null name",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserPatchByIDParams{Name: null},
+			wantErr: service.ErrRequired,
+		},
+		{
+			name:    "invalid email",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserPatchByIDParams{Email: set("roe")},
+			wantErr: service.ErrInvalidEmail,
+		},
+		{
+			name:    "email taken",
+			id:      func(jane, _ *model.User) int32 { return jane.ID },
+			params:  service.UserPatchByIDParams{Email: set("john@example.com")},
+			wantErr: service.ErrUserDup,
+		},
+		{
+			name:    "missing",
+			id:      func(_, john *model.User) int32 { return john.ID + 1 },
+			params:  service.UserPatchByIDParams{Name: set("Jane Roe")},
+			wantErr: service.ErrUserNotFound,
+		},
+		{
+			name:    "invalid id",
+			id:      func(_, _ *model.User) int32 { return 0 },
+			params:  service.UserPatchByIDParams{Name: set("Jane Roe")},
+			wantErr: service.ErrInvalidID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newUserService()
+			jane := mustCreateUser(t, us, "jane@example.com")
+			john := mustCreateUser(t, us, "john@example.com")
+
+			user, err := us.PatchByID(context.Background(), tt.id(jane, john), tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			want := tt.want(jane)
+			if user.Name != want.Name || user.Email != want.Email || user.Role != want.Role {
+				t.Errorf("user = %+v; want fields of %+v", user, want)
+			}
+		})
+	}
+}
+
+func TestUserServiceDeleteByID(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+
+	if err := us.DeleteByID(context.Background(), 0); !errors.Is(err, service.ErrInvalidID) {
+		t.Fatalf("err = %v; want %v", err, service.ErrInvalidID)
+	}
+
+	if err := us.DeleteByID(context.Background(), user.ID, 2); !errors.Is(err, service.ErrVersionMismatch) {
+		t.Fatalf("err = %v; want %v", err, service.ErrVersionMismatch)
+	}
+	if err := us.DeleteByID(context.Background(), user.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.GetByID(context.Background(), user.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+	if err := us.DeleteByID(context.Background(), user.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
+
+func TestUserServiceDeleteByIDInUse(t *testing.T) {
+	lib := newLibrary(t)
+	loan := mustBorrow(t, lib, lib.jane)
+
+	var iue service.InUseError
+	if err := lib.users.DeleteByID(context.Background(), lib.jane.ID); !errors.As(err, &iue) {
+		t.Fatalf("err = %v; want InUseError", err)
+	}
+	if len(iue.LoanIDs) != 1 || iue.LoanIDs[0] != loan.ID || len(iue.ReservationIDs) != 0 {
+		t.Errorf("err = %+v; want loan %d", iue, loan.ID)
+	}
+	if _, err := lib.users.GetByID(context.Background(), lib.jane.ID); err != nil {
+		t.Fatalf("user refused deletion: %v", err)
+	}
+
+	if err := lib.users.ForceDeleteByID(context.Background(), lib.jane.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loan, err := lib.st.Loans().GetByID(context.Background(), loan.ID); err != nil || !loan.ReturnDate.Valid {
+		t.Errorf("loan = %+v, %v; want it returned", loan, err)
+	}
+}
+
+func TestUserServiceRestoreByID(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+
+	if _, err := us.RestoreByID(context.Background(), user.ID); !errors.Is(err, service.ErrNotDeleted) {
+		t.Fatalf("err = %v; want %v", err, service.ErrNotDeleted)
+	}
+	if err := us.DeleteByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := us.RestoreByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Email != user.Email || restored.Version != 2 {
+		t.Errorf("user = %+v; want %s at version 2", restored, user.Email)
+	}
+	if _, err := us.GetByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.RestoreByID(context.Background(), 1000); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
+
+func TestUserServiceRestoreByIDTakenEmail(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+	if err := us.DeleteByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustCreateUser(t, us, "jane@example.com")
+
+	if _, err := us.RestoreByID(context.Background(), user.ID); !errors.Is(err, service.ErrUserDup) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserDup)
+	}
+}
+
+func TestUserServicePurgeByID(t *testing.T) {
+	us := newUserService()
+	user := mustCreateUser(t, us, "jane@example.com")
+
+	if err := us.PurgeByID(context.Background(), user.ID); !errors.Is(err, service.ErrNotDeleted) {
+		t.Fatalf("err = %v; want %v", err, service.ErrNotDeleted)
+	}
+	if err := us.DeleteByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.PurgeByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.RestoreByID(context.Background(), user.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("err = %v; want %v", err, service.ErrUserNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type apiKeyRepository struct {
+	db bun.IDB
+}
+
+func (akr apiKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	if _, err := akr.db.NewInsert().Model(key).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (akr apiKeyRepository) GetByID(ctx context.Context, id int32) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := akr.db.
+		NewSelect().
+		Model(&key).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &key, nil
+}
+
+func (akr apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := akr.db.
+		NewSelect().
+		Model(&key).
+		Where("prefix = ?", prefix).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &key, nil
+}
+
+func (akr apiKeyRepository) ListByUserID(ctx context.Context, userID int32) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := akr.db.
+		NewSelect().
+		Model(&keys).
+		Where("user_id = ?", userID).
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return keys, nil
+}
+
+func (akr apiKeyRepository) Update(ctx context.Context, key *model.APIKey) error {
+	res, err := akr.db.
+		NewUpdate().
+		Model(key).
+		OmitZero().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := akr.db.
+		NewSelect().
+		Model(key).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (akr apiKeyRepository) DeleteByID(ctx context.Context, id int32) error {
+	res, err := akr.db.
+		NewDelete().
+		Model((*model.APIKey)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
@@ -0,0 +1,99 @@
+package bunstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type auditLogRepository struct {
+	db bun.IDB
+}
+
+func (alr auditLogRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	if _, err := alr.db.NewInsert().Model(entry).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (alr auditLogRepository) CreateMany(ctx context.Context, entries []model.AuditEntry) error {
+	for batch := range slices.Chunk(entries, batchSize) {
+		if _, err := alr.db.NewInsert().Model(&batch).Exec(ctx); err != nil {
+			return translateErr(err)
+		}
+	}
+
+	return nil
+}
+
+func (alr auditLogRepository) List(ctx context.Context, filter store.AuditFilter) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	q := alr.db.
+		NewSelect().
+		Model(&entries).
+		Order("id DESC")
+
+	if filter.ActorID != 0 {
+		q = q.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		q = q.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		q = q.Where("entity_id = ?", filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("created_at <= ?", filter.Until)
+	}
+	if filter.BeforeID != 0 {
+		q = q.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return entries, nil
+}
+
+func (alr auditLogRepository) ListByUserID(ctx context.Context, userID int32) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	if err := alr.db.
+		NewSelect().
+		Model(&entries).
+		Where("actor_id = ? OR (entity = 'user' AND entity_id = ?)", userID, userID).
+		Order("id ASC").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return entries, nil
+}
+
+func (alr auditLogRepository) ClearIPByActorID(ctx context.Context, actorID int32) error {
+	if _, err := alr.db.
+		NewUpdate().
+		Model((*model.AuditEntry)(nil)).
+		Set("ip = NULL").
+		Where("actor_id = ?", actorID).
+		Where("ip IS NOT NULL").
+		Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type authorRepository struct {
+	db bun.IDB
+}
+
+func (ar authorRepository) Ensure(ctx context.Context, names []string) ([]model.Author, error) {
+	authors := make([]model.Author, 0, len(names))
+	for _, name := range names {
+		authors = append(authors, model.Author{Name: name})
+	}
+	if err := ensureNames(ctx, ar.db, authors); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+func (ar authorRepository) GetByID(ctx context.Context, id int32) (*model.Author, error) {
+	return getNameByID[model.Author](ctx, ar.db, id)
+}
+
+func (ar authorRepository) List(ctx context.Context, filter store.NameFilter) ([]model.Author, error) {
+	return listNames[model.Author](ctx, ar.db, filter)
+}
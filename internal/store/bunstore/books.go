@@ -0,0 +1,473 @@
+package bunstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type bookRepository struct {
+	db bun.IDB
+}
+
+// linkColumns are the columns of books that stand for their links.
+var linkColumns = []string{"authors", "publishers", "subjects", "categories", "tags"}
+
+func (br bookRepository) Create(ctx context.Context, book *model.Book) error {
+	book.Version = 1
+	if _, err := br.db.NewInsert().Model(book).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return br.link(ctx, []model.Book{*book}, linkColumns)
+}
+
+func (br bookRepository) CreateMany(ctx context.Context, books []model.Book) error {
+	for i := range books {
+		books[i].Version = 1
+	}
+	for batch := range slices.Chunk(books, batchSize) {
+		if _, err := br.db.NewInsert().Model(&batch).Exec(ctx); err != nil {
+			return translateErr(err)
+		}
+		if err := br.link(ctx, batch, linkColumns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (br bookRepository) GetByID(ctx context.Context, id int32) (*model.Book, error) {
+	var book model.Book
+	if err := br.db.
+		NewSelect().
+		Model(&book).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	books := []model.Book{book}
+	if err := br.load(ctx, books); err != nil {
+		return nil, err
+	}
+
+	return &books[0], nil
+}
+
+func (br bookRepository) List(ctx context.Context, filter store.BookFilter) ([]model.Book, error) {
+	books := []model.Book{}
+	q := br.db.
+		NewSelect().
+		Model(&books).
+		Order("id")
+
+	if filter.AuthorID != 0 {
+		q = q.Where("id IN (SELECT book_id FROM book_authors WHERE author_id = ?)", filter.AuthorID)
+	}
+	if filter.PublisherID != 0 {
+		q = q.Where("id IN (SELECT book_id FROM book_publishers WHERE publisher_id = ?)", filter.PublisherID)
+	}
+	if filter.SubjectID != 0 {
+		q = q.Where("id IN (SELECT book_id FROM book_subjects WHERE subject_id = ?)", filter.SubjectID)
+	}
+	if filter.CategoryID != 0 {
+		q = q.Where(`id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ?
+				UNION ALL
+				SELECT c.id FROM categories AS c JOIN tree ON c.parent_id = tree.id
+			)
+			SELECT book_id FROM book_categories WHERE category_id IN (SELECT id FROM tree)
+		)`, filter.CategoryID)
+	}
+	if filter.TagID != 0 {
+		q = q.Where("id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)", filter.TagID)
+	}
+	if filter.Language != "" {
+		q = q.Where("language = ?", filter.Language)
+	}
+	if filter.YearFrom != 0 {
+		q = q.Where("publication_year >= ?", filter.YearFrom)
+	}
+	if filter.YearTo != 0 {
+		q = q.Where("publication_year <= ?", filter.YearTo)
+	}
+	if filter.AfterID != 0 {
+		q = q.Where("id > ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+	if err := br.load(ctx, books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (br bookRepository) ListByISBNs(ctx context.Context, isbns []string) ([]model.Book, error) {
+	books := []model.Book{}
+	if len(isbns) == 0 {
+		return books, nil
+	}
+	if err := br.db.
+		NewSelect().
+		Model(&books).
+		Where("isbn IN (?)", bun.In(isbns)).
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+	if err := br.load(ctx, books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (br bookRepository) ListShelf(ctx context.Context, filter store.ShelfFilter) ([]model.Book, error) {
+	books := []model.Book{}
+	q := br.db.
+		NewSelect().
+		Model(&books).
+		Where("classification = ?", filter.Classification).
+		Where("call_number_key IS NOT NULL").
+		Order("call_number_key", "id")
+
+	if filter.From != "" {
+		q = q.Where("call_number_key >= ?", filter.From)
+	}
+	if filter.Before != "" {
+		q = q.Where("call_number_key < ?", filter.Before)
+	}
+	if filter.AfterID != 0 {
+		q = q.Where("(call_number_key, id) > (SELECT call_number_key, id FROM books WHERE id = ?)", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+	if err := br.load(ctx, books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (br bookRepository) Update(ctx context.Context, book *model.Book, columns ...string) error {
+	omitZero := len(columns) == 0
+	var links []string
+	if omitZero {
+		if len(book.Authors) > 0 {
+			links = append(links, "authors")
+		}
+		if len(book.Publishers) > 0 {
+			links = append(links, "publishers")
+		}
+		if len(book.Subjects) > 0 {
+			links = append(links, "subjects")
+		}
+		if len(book.Categories) > 0 {
+			links = append(links, "categories")
+		}
+		if len(book.Tags) > 0 {
+			links = append(links, "tags")
+		}
+	} else {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(column string) bool {
+			if slices.Contains(linkColumns, column) {
+				links = append(links, column)
+				return true
+			}
+			return false
+		})
+	}
+
+	q := br.db.
+		NewUpdate().
+		Model(book).
+		Value("version", "version + 1").
+		WherePK()
+	if omitZero {
+		q = q.OmitZero()
+	} else {
+		q = q.Column(append(columns, "version")...)
+	}
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := br.link(ctx, []model.Book{*book}, links); err != nil {
+		return err
+	}
+	if err := br.db.
+		NewSelect().
+		Model(book).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	books := []model.Book{*book}
+	if err := br.load(ctx, books); err != nil {
+		return err
+	}
+	*book = books[0]
+
+	return nil
+}
+
+func (br bookRepository) DeleteByID(ctx context.Context, id int32) error {
+	res, err := br.db.
+		NewDelete().
+		Model((*model.Book)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+func (br bookRepository) GetDeletedByID(ctx context.Context, id int32) (*model.Book, error) {
+	var book model.Book
+	if err := br.db.
+		NewSelect().
+		Model(&book).
+		WhereDeleted().
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	books := []model.Book{book}
+	if err := br.load(ctx, books); err != nil {
+		return nil, err
+	}
+
+	return &books[0], nil
+}
+
+func (br bookRepository) Restore(ctx context.Context, id int32) error {
+	res, err := br.db.
+		NewUpdate().
+		Model((*model.Book)(nil)).
+		Set("deleted_at = NULL").
+		Set("version = version + 1").
+		WhereDeleted().
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+func (br bookRepository) Purge(ctx context.Context, id int32) error {
+	res, err := br.db.
+		NewDelete().
+		Model((*model.Book)(nil)).
+		WhereDeleted().
+		Where("id = ?", id).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+// link replaces the links of books named by columns with those in their
+// fields.
+func (br bookRepository) link(ctx context.Context, books []model.Book, columns []string) error {
+	if len(books) == 0 || len(columns) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, 0, len(books))
+	var (
+		authors    []model.BookAuthor
+		publishers []model.BookPublisher
+		subjects   []model.BookSubject
+		categories []model.BookCategory
+		tags       []model.BookTag
+	)
+	for _, book := range books {
+		ids = append(ids, book.ID)
+		for i, author := range book.Authors {
+			authors = append(authors, model.BookAuthor{BookID: book.ID, AuthorID: author.ID, Position: int16(i)})
+		}
+		for i, publisher := range book.Publishers {
+			publishers = append(publishers, model.BookPublisher{BookID: book.ID, PublisherID: publisher.ID, Position: int16(i)})
+		}
+		for i, subject := range book.Subjects {
+			subjects = append(subjects, model.BookSubject{BookID: book.ID, SubjectID: subject.ID, Position: int16(i)})
+		}
+		for i, category := range book.Categories {
+			categories = append(categories, model.BookCategory{BookID: book.ID, CategoryID: category.ID, Position: int16(i)})
+		}
+		for i, tag := range book.Tags {
+			tags = append(tags, model.BookTag{BookID: book.ID, TagID: tag.ID, Position: int16(i)})
+		}
+	}
+
+	if slices.Contains(columns, "authors") {
+		if err := replaceLinks(ctx, br.db, ids, authors); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(columns, "publishers") {
+		if err := replaceLinks(ctx, br.db, ids, publishers); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(columns, "subjects") {
+		if err := replaceLinks(ctx, br.db, ids, subjects); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(columns, "categories") {
+		if err := replaceLinks(ctx, br.db, ids, categories); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(columns, "tags") {
+		if err := replaceLinks(ctx, br.db, ids, tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replaceLinks[T any](ctx context.Context, db bun.IDB, bookIDs []int32, links []T) error {
+	if _, err := db.
+		NewDelete().
+		Model((*T)(nil)).
+		Where("book_id IN (?)", bun.In(bookIDs)).
+		Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+	for batch := range slices.Chunk(links, batchSize) {
+		if _, err := db.NewInsert().Model(&batch).Exec(ctx); err != nil {
+			return translateErr(err)
+		}
+	}
+
+	return nil
+}
+
+// bookLink is an entity linked to a book.
+type bookLink struct {
+	BookID   int32
+	ID       int32
+	ParentID int32
+	Name     string
+}
+
+// load fills in the entities books are linked to.
+func (br bookRepository) load(ctx context.Context, books []model.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, 0, len(books))
+	index := make(map[int32]int, len(books))
+	for i := range books {
+		ids = append(ids, books[i].ID)
+		index[books[i].ID] = i
+		books[i].Authors = nil
+		books[i].Publishers = nil
+		books[i].Subjects = nil
+		books[i].Categories = nil
+		books[i].Tags = nil
+	}
+
+	authors, err := loadLinks(ctx, br.db, "authors", "author_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, link := range authors {
+		book := &books[index[link.BookID]]
+		book.Authors = append(book.Authors, model.Author{ID: link.ID, Name: link.Name})
+	}
+
+	publishers, err := loadLinks(ctx, br.db, "publishers", "publisher_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, link := range publishers {
+		book := &books[index[link.BookID]]
+		book.Publishers = append(book.Publishers, model.Publisher{ID: link.ID, Name: link.Name})
+	}
+
+	subjects, err := loadLinks(ctx, br.db, "subjects", "subject_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, link := range subjects {
+		book := &books[index[link.BookID]]
+		book.Subjects = append(book.Subjects, model.Subject{ID: link.ID, Name: link.Name})
+	}
+
+	categories, err := loadLinks(ctx, br.db, "categories", "category_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, link := range categories {
+		book := &books[index[link.BookID]]
+		book.Categories = append(book.Categories, model.Category{ID: link.ID, ParentID: link.ParentID, Name: link.Name})
+	}
+
+	tags, err := loadLinks(ctx, br.db, "tags", "tag_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, link := range tags {
+		book := &books[index[link.BookID]]
+		book.Tags = append(book.Tags, model.Tag{ID: link.ID, Name: link.Name})
+	}
+
+	return nil
+}
+
+// loadLinks lists the entities of table linked to the books, ordered by
+// book and then by position.
+func loadLinks(ctx context.Context, db bun.IDB, table, column string, bookIDs []int32) ([]bookLink, error) {
+	var links []bookLink
+	q := db.
+		NewSelect().
+		TableExpr("? AS l", bun.Ident("book_"+table)).
+		Join("JOIN ? AS e ON e.id = l.?", bun.Ident(table), bun.Ident(column)).
+		ColumnExpr("l.book_id, e.id, e.name").
+		Where("l.book_id IN (?)", bun.In(bookIDs)).
+		OrderExpr("l.book_id, l.position")
+	// Categories come with their parents, to be placed in the tree.
+	if table == "categories" {
+		q = q.ColumnExpr("e.parent_id")
+	}
+	if err := q.Scan(ctx, &links); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return links, nil
+}
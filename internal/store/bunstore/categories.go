@@ -0,0 +1,59 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type categoryRepository struct {
+	db bun.IDB
+}
+
+func (cr categoryRepository) Create(ctx context.Context, category *model.Category) error {
+	if _, err := cr.db.NewInsert().Model(category).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (cr categoryRepository) GetByID(ctx context.Context, id int32) (*model.Category, error) {
+	var category model.Category
+	if err := cr.db.
+		NewSelect().
+		Model(&category).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &category, nil
+}
+
+func (cr categoryRepository) List(ctx context.Context) ([]model.Category, error) {
+	categories := []model.Category{}
+	if err := cr.db.
+		NewSelect().
+		Model(&categories).
+		Order("name", "id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return categories, nil
+}
+
+func (cr categoryRepository) DeleteByID(ctx context.Context, id int32) error {
+	res, err := cr.db.
+		NewDelete().
+		Model((*model.Category)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
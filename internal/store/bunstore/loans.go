@@ -0,0 +1,132 @@
+package bunstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type loanRepository struct {
+	db bun.IDB
+}
+
+func (lr loanRepository) Create(ctx context.Context, loan *model.Loan) error {
+	if _, err := lr.db.NewInsert().Model(loan).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (lr loanRepository) GetByID(ctx context.Context, id int32) (*model.Loan, error) {
+	var loan model.Loan
+	if err := lr.db.
+		NewSelect().
+		Model(&loan).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &loan, nil
+}
+
+func (lr loanRepository) Update(ctx context.Context, loan *model.Loan) error {
+	res, err := lr.db.
+		NewUpdate().
+		Model(loan).
+		OmitZero().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := lr.db.
+		NewSelect().
+		Model(loan).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (lr loanRepository) ListByUserID(ctx context.Context, userID int32) ([]model.Loan, error) {
+	var loans []model.Loan
+	if err := lr.db.
+		NewSelect().
+		Model(&loans).
+		Where("user_id = ?", userID).
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return loans, nil
+}
+
+func (lr loanRepository) ListOpenByUserID(ctx context.Context, userID int32) ([]model.Loan, error) {
+	return lr.listOpen(ctx, "user_id", userID)
+}
+
+func (lr loanRepository) ListOpenByBookID(ctx context.Context, bookID int32) ([]model.Loan, error) {
+	return lr.listOpen(ctx, "book_id", bookID)
+}
+
+func (lr loanRepository) listOpen(ctx context.Context, column string, id int32) ([]model.Loan, error) {
+	var loans []model.Loan
+	if err := lr.db.
+		NewSelect().
+		Model(&loans).
+		Where("? = ?", bun.Ident(column), id).
+		Where("return_date IS NULL").
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return loans, nil
+}
+
+func (lr loanRepository) ListOverdue(ctx context.Context, now time.Time) ([]model.Loan, error) {
+	var loans []model.Loan
+	if err := lr.db.
+		NewSelect().
+		Model(&loans).
+		// WHERE return_date IS NULL AND ? > due_date OR return_date > due_date
+		Where("return_date IS NULL").
+		WhereGroup("AND", func(sq *bun.SelectQuery) *bun.SelectQuery {
+			return sq.Where("? > due_date", now)
+		}).
+		WhereOr("return_date > due_date").
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return loans, nil
+}
+
+func (lr loanRepository) MostBorrowed(ctx context.Context, limit int) ([]store.BookBorrows, error) {
+	var results []store.BookBorrows
+	if err := lr.db.
+		NewSelect().
+		Model((*model.Book)(nil)).
+		ColumnExpr("book.id id, book.title title, COUNT(*) borrows").
+		Join("JOIN loans loan ON loan.book_id = book.id").
+		Group("book.id").
+		OrderExpr("borrows DESC, book.id").
+		Limit(limit).
+		Scan(ctx, &results); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return results, nil
+}
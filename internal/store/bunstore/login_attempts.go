@@ -0,0 +1,34 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type loginAttemptRepository struct {
+	db bun.IDB
+}
+
+func (lar loginAttemptRepository) Create(ctx context.Context, attempt *model.LoginAttempt) error {
+	if _, err := lar.db.NewInsert().Model(attempt).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (lar loginAttemptRepository) ListByUserID(ctx context.Context, userID int32) ([]model.LoginAttempt, error) {
+	var attempts []model.LoginAttempt
+	if err := lar.db.
+		NewSelect().
+		Model(&attempts).
+		Where("user_id = ?", userID).
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,75 @@
+package bunstore
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/store"
+)
+
+// The helpers below implement the repositories of authors, publishers,
+// subjects and tags, whose tables all consist of an ID and a unique name.
+
+// ensureNames fills in the IDs of rows, which must have distinct names,
+// inserting those that don't exist yet.
+func ensureNames[T any](ctx context.Context, db bun.IDB, rows []T) error {
+	for batch := range slices.Chunk(rows, batchSize) {
+		// Setting the name to itself on conflict makes the existing rows
+		// return their IDs too, in the order they were given.
+		if _, err := db.
+			NewInsert().
+			Model(&batch).
+			On("CONFLICT (name) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Returning("id").
+			Exec(ctx); err != nil {
+			return translateErr(err)
+		}
+	}
+
+	return nil
+}
+
+func getNameByID[T any](ctx context.Context, db bun.IDB, id int32) (*T, error) {
+	var row T
+	if err := db.
+		NewSelect().
+		Model(&row).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &row, nil
+}
+
+func listNames[T any](ctx context.Context, db bun.IDB, filter store.NameFilter) ([]T, error) {
+	rows := []T{}
+	q := db.
+		NewSelect().
+		Model(&rows).
+		Order("name")
+
+	if filter.Prefix != "" {
+		q = q.Where("lower(name) LIKE ?", likePrefix(strings.ToLower(filter.Prefix)))
+	}
+	if filter.After != "" {
+		q = q.Where("name > ?", filter.After)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return rows, nil
+}
+
+// likePrefix makes a LIKE pattern matching strings that start with prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
@@ -0,0 +1,72 @@
+package bunstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type passwordResetRepository struct {
+	db bun.IDB
+}
+
+func (prr passwordResetRepository) Create(ctx context.Context, reset *model.PasswordReset) error {
+	if _, err := prr.db.NewInsert().Model(reset).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (prr passwordResetRepository) GetByTokenHash(ctx context.Context, tokenHash []byte) (*model.PasswordReset, error) {
+	var reset model.PasswordReset
+	if err := prr.db.
+		NewSelect().
+		Model(&reset).
+		Where("token_hash = ?", tokenHash).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &reset, nil
+}
+
+func (prr passwordResetRepository) Update(ctx context.Context, reset *model.PasswordReset) error {
+	res, err := prr.db.
+		NewUpdate().
+		Model(reset).
+		OmitZero().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := prr.db.
+		NewSelect().
+		Model(reset).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (prr passwordResetRepository) UseUpByUserID(ctx context.Context, userID int32, at time.Time) error {
+	if _, err := prr.db.
+		NewUpdate().
+		Model((*model.PasswordReset)(nil)).
+		Set("used_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("used_at IS NULL").
+		Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type publisherRepository struct {
+	db bun.IDB
+}
+
+func (pr publisherRepository) Ensure(ctx context.Context, names []string) ([]model.Publisher, error) {
+	publishers := make([]model.Publisher, 0, len(names))
+	for _, name := range names {
+		publishers = append(publishers, model.Publisher{Name: name})
+	}
+	if err := ensureNames(ctx, pr.db, publishers); err != nil {
+		return nil, err
+	}
+
+	return publishers, nil
+}
+
+func (pr publisherRepository) GetByID(ctx context.Context, id int32) (*model.Publisher, error) {
+	return getNameByID[model.Publisher](ctx, pr.db, id)
+}
+
+func (pr publisherRepository) List(ctx context.Context, filter store.NameFilter) ([]model.Publisher, error) {
+	return listNames[model.Publisher](ctx, pr.db, filter)
+}
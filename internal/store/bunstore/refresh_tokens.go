@@ -0,0 +1,86 @@
+package bunstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type refreshTokenRepository struct {
+	db bun.IDB
+}
+
+func (rtr refreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	if _, err := rtr.db.NewInsert().Model(token).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (rtr refreshTokenRepository) GetByTokenHash(ctx context.Context, tokenHash []byte) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := rtr.db.
+		NewSelect().
+		Model(&token).
+		Where("token_hash = ?", tokenHash).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &token, nil
+}
+
+func (rtr refreshTokenRepository) Update(ctx context.Context, token *model.RefreshToken) error {
+	res, err := rtr.db.
+		NewUpdate().
+		Model(token).
+		OmitZero().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := rtr.db.
+		NewSelect().
+		Model(token).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (rtr refreshTokenRepository) RevokeByFamilyID(ctx context.Context, familyID []byte, at time.Time) error {
+	if _, err := rtr.db.
+		NewUpdate().
+		Model((*model.RefreshToken)(nil)).
+		Set("revoked_at = ?", at).
+		Where("family_id = ?", familyID).
+		Where("revoked_at IS NULL").
+		Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (rtr refreshTokenRepository) RevokeByUserID(ctx context.Context, userID int32, at time.Time) error {
+	if _, err := rtr.db.
+		NewUpdate().
+		Model((*model.RefreshToken)(nil)).
+		Set("revoked_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type reservationRepository struct {
+	db bun.IDB
+}
+
+func (rr reservationRepository) Create(ctx context.Context, reservation *model.Reservation) error {
+	if _, err := rr.db.NewInsert().Model(reservation).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (rr reservationRepository) GetByID(ctx context.Context, id int32) (*model.Reservation, error) {
+	var reservation model.Reservation
+	if err := rr.db.
+		NewSelect().
+		Model(&reservation).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &reservation, nil
+}
+
+func (rr reservationRepository) GetByBookID(ctx context.Context, bookID int32) (*model.Reservation, error) {
+	var reservation model.Reservation
+	if err := rr.db.
+		NewSelect().
+		Model(&reservation).
+		Where("book_id = ?", bookID).
+		Where("canceled_at IS NULL").
+		Order("id").
+		Limit(1).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &reservation, nil
+}
+
+func (rr reservationRepository) ListByUserID(ctx context.Context, userID int32) ([]model.Reservation, error) {
+	var reservations []model.Reservation
+	if err := rr.db.
+		NewSelect().
+		Model(&reservations).
+		Where("user_id = ?", userID).
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return reservations, nil
+}
+
+func (rr reservationRepository) ListActiveByUserID(ctx context.Context, userID int32) ([]model.Reservation, error) {
+	return rr.listActive(ctx, "user_id", userID)
+}
+
+func (rr reservationRepository) ListActiveByBookID(ctx context.Context, bookID int32) ([]model.Reservation, error) {
+	return rr.listActive(ctx, "book_id", bookID)
+}
+
+func (rr reservationRepository) listActive(ctx context.Context, column string, id int32) ([]model.Reservation, error) {
+	var reservations []model.Reservation
+	if err := rr.db.
+		NewSelect().
+		Model(&reservations).
+		Where("? = ?", bun.Ident(column), id).
+		Where("canceled_at IS NULL").
+		Order("id").
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return reservations, nil
+}
+
+func (rr reservationRepository) Update(ctx context.Context, reservation *model.Reservation) error {
+	res, err := rr.db.
+		NewUpdate().
+		Model(reservation).
+		OmitZero().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := rr.db.
+		NewSelect().
+		Model(reservation).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
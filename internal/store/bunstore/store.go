@@ -0,0 +1,110 @@
+package bunstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type Store struct {
+	db bun.IDB
+}
+
+var _ store.Store = Store{}
+
+func New(db bun.IDB) Store {
+	return Store{db: db}
+}
+
+func (s Store) Users() store.UserRepository {
+	return userRepository{db: s.db}
+}
+
+func (s Store) Books() store.BookRepository {
+	return bookRepository{db: s.db}
+}
+
+func (s Store) Authors() store.AuthorRepository {
+	return authorRepository{db: s.db}
+}
+
+func (s Store) Publishers() store.PublisherRepository {
+	return publisherRepository{db: s.db}
+}
+
+func (s Store) Subjects() store.SubjectRepository {
+	return subjectRepository{db: s.db}
+}
+
+func (s Store) Categories() store.CategoryRepository {
+	return categoryRepository{db: s.db}
+}
+
+func (s Store) Tags() store.TagRepository {
+	return tagRepository{db: s.db}
+}
+
+func (s Store) Loans() store.LoanRepository {
+	return loanRepository{db: s.db}
+}
+
+func (s Store) Reservations() store.ReservationRepository {
+	return reservationRepository{db: s.db}
+}
+
+func (s Store) PasswordResets() store.PasswordResetRepository {
+	return passwordResetRepository{db: s.db}
+}
+
+func (s Store) LoginAttempts() store.LoginAttemptRepository {
+	return loginAttemptRepository{db: s.db}
+}
+
+func (s Store) RefreshTokens() store.RefreshTokenRepository {
+	return refreshTokenRepository{db: s.db}
+}
+
+func (s Store) APIKeys() store.APIKeyRepository {
+	return apiKeyRepository{db: s.db}
+}
+
+func (s Store) AuditLog() store.AuditLogRepository {
+	return auditLogRepository{db: s.db}
+}
+
+// batchSize is how many rows CreateMany methods insert per statement, which
+// keeps them well under the limit PostgreSQL puts on the size of a statement.
+const batchSize = 1000
+
+// mustAffect reports ErrNotFound when a statement matched no rows.
+func mustAffect(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func translateErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if pgErr := (pgdriver.Error{}); errors.As(err, &pgErr) {
+		switch pgErr.Field('C') {
+		case pgerrcode.UniqueViolation:
+			return store.ErrConflict
+		case pgerrcode.ForeignKeyViolation:
+			return store.ErrInvalidReference
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,33 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type subjectRepository struct {
+	db bun.IDB
+}
+
+func (sr subjectRepository) Ensure(ctx context.Context, names []string) ([]model.Subject, error) {
+	subjects := make([]model.Subject, 0, len(names))
+	for _, name := range names {
+		subjects = append(subjects, model.Subject{Name: name})
+	}
+	if err := ensureNames(ctx, sr.db, subjects); err != nil {
+		return nil, err
+	}
+
+	return subjects, nil
+}
+
+func (sr subjectRepository) GetByID(ctx context.Context, id int32) (*model.Subject, error) {
+	return getNameByID[model.Subject](ctx, sr.db, id)
+}
+
+func (sr subjectRepository) List(ctx context.Context, filter store.NameFilter) ([]model.Subject, error) {
+	return listNames[model.Subject](ctx, sr.db, filter)
+}
@@ -0,0 +1,33 @@
+package bunstore
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type tagRepository struct {
+	db bun.IDB
+}
+
+func (tr tagRepository) Ensure(ctx context.Context, names []string) ([]model.Tag, error) {
+	tags := make([]model.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, model.Tag{Name: name})
+	}
+	if err := ensureNames(ctx, tr.db, tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (tr tagRepository) GetByID(ctx context.Context, id int32) (*model.Tag, error) {
+	return getNameByID[model.Tag](ctx, tr.db, id)
+}
+
+func (tr tagRepository) List(ctx context.Context, filter store.NameFilter) ([]model.Tag, error) {
+	return listNames[model.Tag](ctx, tr.db, filter)
+}
@@ -0,0 +1,77 @@
+package bunstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/utilyre/lms/internal/store"
+)
+
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// RunInTx runs fn in a serializable transaction and retries it when Postgres
+// aborts it due to a serialization failure or a deadlock.
+func (s Store) RunInTx(ctx context.Context, fn func(ctx context.Context, tx store.Store) error) error {
+	if _, ok := s.db.(bun.Tx); ok {
+		return fn(ctx, s)
+	}
+
+	var err error
+	for attempt := range maxTxAttempts {
+		if attempt > 0 {
+			slog.DebugContext(ctx, "retrying transaction", "attempt", attempt+1, "error", err)
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return err
+			}
+		}
+
+		err = s.db.RunInTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(ctx context.Context, tx bun.Tx) error {
+			return fn(ctx, New(tx))
+		})
+		if !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func isRetryable(err error) bool {
+	if pgErr := (pgdriver.Error{}); errors.As(err, &pgErr) {
+		switch pgErr.Field('C') {
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff doubles the delay with every attempt and adds up to as much jitter
+// so that conflicting transactions don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := txRetryDelay << (attempt - 1)
+	return d + rand.N(d)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package bunstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/utilyre/lms/internal/model"
+)
+
+type userRepository struct {
+	db bun.IDB
+}
+
+func (ur userRepository) Create(ctx context.Context, user *model.User) error {
+	user.Version = 1
+	if _, err := ur.db.NewInsert().Model(user).Exec(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (ur userRepository) GetByID(ctx context.Context, id int32) (*model.User, error) {
+	var user model.User
+	if err := ur.db.
+		NewSelect().
+		Model(&user).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &user, nil
+}
+
+func (ur userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := ur.db.
+		NewSelect().
+		Model(&user).
+		Where("email = ?", email).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &user, nil
+}
+
+func (ur userRepository) Update(ctx context.Context, user *model.User, columns ...string) error {
+	q := ur.db.
+		NewUpdate().
+		Model(user).
+		Value("version", "version + 1").
+		WherePK()
+	if len(columns) == 0 {
+		q = q.OmitZero()
+	} else {
+		q = q.Column(append(slices.Clip(columns), "version")...)
+	}
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := ur.db.
+		NewSelect().
+		Model(user).
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	return nil
+}
+
+func (ur userRepository) DeleteByID(ctx context.Context, id int32) error {
+	res, err := ur.db.
+		NewDelete().
+		Model((*model.User)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+func (ur userRepository) GetDeletedByID(ctx context.Context, id int32) (*model.User, error) {
+	var user model.User
+	if err := ur.db.
+		NewSelect().
+		Model(&user).
+		WhereDeleted().
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return nil, translateErr(err)
+	}
+
+	return &user, nil
+}
+
+func (ur userRepository) Restore(ctx context.Context, id int32) error {
+	res, err := ur.db.
+		NewUpdate().
+		Model((*model.User)(nil)).
+		Set("deleted_at = NULL").
+		Set("version = version + 1").
+		WhereDeleted().
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+func (ur userRepository) Purge(ctx context.Context, id int32) error {
+	res, err := ur.db.
+		NewDelete().
+		Model((*model.User)(nil)).
+		WhereDeleted().
+		Where("id = ?", id).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return mustAffect(res)
+}
+
+func (ur userRepository) Anonymize(ctx context.Context, user *model.User) error {
+	res, err := ur.db.
+		NewUpdate().
+		Model(user).
+		Value("version", "version + 1").
+		Column("name", "email", "password", "version").
+		WhereAllWithDeleted().
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return translateErr(err)
+	}
+	if err := mustAffect(res); err != nil {
+		return err
+	}
+	if err := ur.db.
+		NewSelect().
+		Model(user).
+		WhereAllWithDeleted().
+		WherePK().
+		Scan(ctx); err != nil {
+		return translateErr(err)
+	}
+
+	for _, table := range []any{
+		(*model.PasswordReset)(nil),
+		(*model.RefreshToken)(nil),
+		(*model.APIKey)(nil),
+		(*model.LoginAttempt)(nil),
+	} {
+		if _, err := ur.db.
+			NewDelete().
+			Model(table).
+			Where("user_id = ?", user.ID).
+			Exec(ctx); err != nil {
+			return translateErr(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type apiKeyRepository struct {
+	s *Store
+}
+
+func (akr apiKeyRepository) Create(_ context.Context, key *model.APIKey) error {
+	akr.s.mu.Lock()
+	defer akr.s.mu.Unlock()
+
+	if _, ok := akr.s.users.rows[key.UserID]; !ok {
+		return store.ErrInvalidReference
+	}
+	for _, k := range akr.s.apiKeys.rows {
+		if k.Prefix == key.Prefix {
+			return store.ErrConflict
+		}
+	}
+
+	key.Scopes = slices.Clone(key.Scopes)
+	key.ID = akr.s.apiKeys.insert(*key)
+	akr.s.apiKeys.rows[key.ID] = *key
+	return nil
+}
+
+func (akr apiKeyRepository) GetByID(_ context.Context, id int32) (*model.APIKey, error) {
+	akr.s.mu.RLock()
+	defer akr.s.mu.RUnlock()
+
+	key, ok := akr.s.apiKeys.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	key.Scopes = slices.Clone(key.Scopes)
+	return &key, nil
+}
+
+func (akr apiKeyRepository) GetByPrefix(_ context.Context, prefix string) (*model.APIKey, error) {
+	akr.s.mu.RLock()
+	defer akr.s.mu.RUnlock()
+
+	for _, key := range akr.s.apiKeys.rows {
+		if key.Prefix == prefix {
+			key.Scopes = slices.Clone(key.Scopes)
+			return &key, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (akr apiKeyRepository) ListByUserID(_ context.Context, userID int32) ([]model.APIKey, error) {
+	akr.s.mu.RLock()
+	defer akr.s.mu.RUnlock()
+
+	var keys []model.APIKey
+	for _, key := range akr.s.apiKeys.rows {
+		if key.UserID == userID {
+			key.Scopes = slices.Clone(key.Scopes)
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b model.APIKey) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return keys, nil
+}
+
+func (akr apiKeyRepository) Update(_ context.Context, key *model.APIKey) error {
+	akr.s.mu.Lock()
+	defer akr.s.mu.Unlock()
+
+	row, ok := akr.s.apiKeys.rows[key.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	setNonZero(&row.Name, key.Name)
+	setNonZero(&row.ExpiresAt, key.ExpiresAt)
+	setNonZero(&row.LastUsedAt, key.LastUsedAt)
+	if key.Scopes != nil {
+		row.Scopes = slices.Clone(key.Scopes)
+	}
+
+	akr.s.apiKeys.rows[key.ID] = row
+	*key = row
+	key.Scopes = slices.Clone(row.Scopes)
+	return nil
+}
+
+func (akr apiKeyRepository) DeleteByID(_ context.Context, id int32) error {
+	akr.s.mu.Lock()
+	defer akr.s.mu.Unlock()
+
+	if _, ok := akr.s.apiKeys.rows[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(akr.s.apiKeys.rows, id)
+	return nil
+}
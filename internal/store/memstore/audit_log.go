@@ -0,0 +1,93 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type auditLogRepository struct {
+	s *Store
+}
+
+func (alr auditLogRepository) Create(_ context.Context, entry *model.AuditEntry) error {
+	alr.s.mu.Lock()
+	defer alr.s.mu.Unlock()
+
+	entry.ID = alr.s.auditLog.insert(*entry)
+	alr.s.auditLog.rows[entry.ID] = *entry
+	return nil
+}
+
+func (alr auditLogRepository) CreateMany(_ context.Context, entries []model.AuditEntry) error {
+	alr.s.mu.Lock()
+	defer alr.s.mu.Unlock()
+
+	for i := range entries {
+		entries[i].ID = alr.s.auditLog.insert(entries[i])
+		alr.s.auditLog.rows[entries[i].ID] = entries[i]
+	}
+
+	return nil
+}
+
+func (alr auditLogRepository) List(_ context.Context, filter store.AuditFilter) ([]model.AuditEntry, error) {
+	alr.s.mu.RLock()
+	defer alr.s.mu.RUnlock()
+
+	entries := []model.AuditEntry{}
+	for _, entry := range alr.s.auditLog.rows {
+		if (filter.ActorID == 0 || entry.ActorID.Valid && entry.ActorID.Int32 == filter.ActorID) &&
+			(filter.Action == "" || entry.Action == filter.Action) &&
+			(filter.Entity == "" || entry.Entity == filter.Entity) &&
+			(filter.EntityID == 0 || entry.EntityID == filter.EntityID) &&
+			(filter.Since.IsZero() || !entry.CreatedAt.Before(filter.Since)) &&
+			(filter.Until.IsZero() || !entry.CreatedAt.After(filter.Until)) &&
+			(filter.BeforeID == 0 || entry.ID < filter.BeforeID) {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b model.AuditEntry) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
+
+func (alr auditLogRepository) ListByUserID(_ context.Context, userID int32) ([]model.AuditEntry, error) {
+	alr.s.mu.RLock()
+	defer alr.s.mu.RUnlock()
+
+	entries := []model.AuditEntry{}
+	for _, entry := range alr.s.auditLog.rows {
+		if entry.ActorID.Valid && entry.ActorID.Int32 == userID ||
+			entry.Entity == "user" && entry.EntityID == userID {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b model.AuditEntry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return entries, nil
+}
+
+func (alr auditLogRepository) ClearIPByActorID(_ context.Context, actorID int32) error {
+	alr.s.mu.Lock()
+	defer alr.s.mu.Unlock()
+
+	for id, entry := range alr.s.auditLog.rows {
+		if entry.ActorID.Valid && entry.ActorID.Int32 == actorID {
+			entry.IP = ""
+			alr.s.auditLog.rows[id] = entry
+		}
+	}
+
+	return nil
+}
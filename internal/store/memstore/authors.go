@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type authorRepository struct {
+	s *Store
+}
+
+func authorName(author model.Author) string {
+	return author.Name
+}
+
+func (ar authorRepository) Ensure(_ context.Context, names []string) ([]model.Author, error) {
+	ar.s.mu.Lock()
+	defer ar.s.mu.Unlock()
+
+	return ensureNames(&ar.s.authors, names, authorName, func(id int32, name string) model.Author {
+		return model.Author{ID: id, Name: name}
+	}), nil
+}
+
+func (ar authorRepository) GetByID(_ context.Context, id int32) (*model.Author, error) {
+	ar.s.mu.RLock()
+	defer ar.s.mu.RUnlock()
+
+	author, ok := ar.s.authors.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &author, nil
+}
+
+func (ar authorRepository) List(_ context.Context, filter store.NameFilter) ([]model.Author, error) {
+	ar.s.mu.RLock()
+	defer ar.s.mu.RUnlock()
+
+	return listNames(&ar.s.authors, filter, authorName), nil
+}
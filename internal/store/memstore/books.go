@@ -0,0 +1,357 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type bookRepository struct {
+	s *Store
+}
+
+func (br bookRepository) Create(_ context.Context, book *model.Book) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	if err := br.checkLinks(*book); err != nil {
+		return err
+	}
+
+	book.Version = 1
+	cloneLinks(book)
+	book.ID = br.s.books.insert(*book)
+	br.s.books.rows[book.ID] = *book
+	return nil
+}
+
+func (br bookRepository) CreateMany(_ context.Context, books []model.Book) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	for _, book := range books {
+		if err := br.checkLinks(book); err != nil {
+			return err
+		}
+	}
+	for i := range books {
+		books[i].Version = 1
+		cloneLinks(&books[i])
+		books[i].ID = br.s.books.insert(books[i])
+		br.s.books.rows[books[i].ID] = books[i]
+	}
+
+	return nil
+}
+
+func (br bookRepository) GetByID(_ context.Context, id int32) (*model.Book, error) {
+	br.s.mu.RLock()
+	defer br.s.mu.RUnlock()
+
+	book, ok := br.s.books.rows[id]
+	if !ok || !book.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+
+	cloneLinks(&book)
+	return &book, nil
+}
+
+func (br bookRepository) List(_ context.Context, filter store.BookFilter) ([]model.Book, error) {
+	br.s.mu.RLock()
+	defer br.s.mu.RUnlock()
+
+	var categories map[int32]bool
+	if filter.CategoryID != 0 {
+		categories = br.subtree(filter.CategoryID)
+	}
+	books := []model.Book{}
+	for _, book := range br.s.books.rows {
+		if book.DeletedAt.IsZero() && matchBook(book, filter, categories) {
+			cloneLinks(&book)
+			books = append(books, book)
+		}
+	}
+	slices.SortFunc(books, func(a, b model.Book) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if filter.Limit > 0 && len(books) > filter.Limit {
+		books = books[:filter.Limit]
+	}
+
+	return books, nil
+}
+
+func (br bookRepository) ListByISBNs(_ context.Context, isbns []string) ([]model.Book, error) {
+	br.s.mu.RLock()
+	defer br.s.mu.RUnlock()
+
+	books := []model.Book{}
+	for _, book := range br.s.books.rows {
+		if book.DeletedAt.IsZero() && slices.Contains(isbns, book.ISBN) {
+			cloneLinks(&book)
+			books = append(books, book)
+		}
+	}
+	slices.SortFunc(books, func(a, b model.Book) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return books, nil
+}
+
+func (br bookRepository) ListShelf(_ context.Context, filter store.ShelfFilter) ([]model.Book, error) {
+	br.s.mu.RLock()
+	defer br.s.mu.RUnlock()
+
+	compare := func(a, b model.Book) int {
+		return cmp.Or(strings.Compare(a.CallNumberKey, b.CallNumberKey), cmp.Compare(a.ID, b.ID))
+	}
+	after, ok := br.s.books.rows[filter.AfterID]
+	if filter.AfterID != 0 && (!ok || after.CallNumberKey == "") {
+		// Like SQL comparing with NULL, nothing is after a book that isn't
+		// shelved.
+		return []model.Book{}, nil
+	}
+
+	books := []model.Book{}
+	for _, book := range br.s.books.rows {
+		if !book.DeletedAt.IsZero() || book.Classification != filter.Classification || book.CallNumberKey == "" {
+			continue
+		}
+		if book.CallNumberKey < filter.From || filter.Before != "" && book.CallNumberKey >= filter.Before {
+			continue
+		}
+		if filter.AfterID != 0 && compare(book, after) <= 0 {
+			continue
+		}
+
+		cloneLinks(&book)
+		books = append(books, book)
+	}
+	slices.SortFunc(books, compare)
+	if filter.Limit > 0 && len(books) > filter.Limit {
+		books = books[:filter.Limit]
+	}
+
+	return books, nil
+}
+
+func (br bookRepository) Update(_ context.Context, book *model.Book, columns ...string) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	row, ok := br.s.books.rows[book.ID]
+	if !ok || !row.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	if err := br.checkLinks(*book); err != nil {
+		return err
+	}
+
+	setColumn(columns, "title", &row.Title, book.Title)
+	setColumn(columns, "isbn", &row.ISBN, book.ISBN)
+	setColumn(columns, "publication_year", &row.PublicationYear, book.PublicationYear)
+	setColumn(columns, "edition", &row.Edition, book.Edition)
+	setColumn(columns, "language", &row.Language, book.Language)
+	setColumn(columns, "page_count", &row.PageCount, book.PageCount)
+	setColumn(columns, "classification", &row.Classification, book.Classification)
+	setColumn(columns, "call_number", &row.CallNumber, book.CallNumber)
+	setColumn(columns, "call_number_key", &row.CallNumberKey, book.CallNumberKey)
+	setColumn(columns, "availability_status", &row.AvailabilityStatus, book.AvailabilityStatus)
+	setLinks(columns, "authors", &row.Authors, book.Authors)
+	setLinks(columns, "publishers", &row.Publishers, book.Publishers)
+	setLinks(columns, "subjects", &row.Subjects, book.Subjects)
+	setLinks(columns, "categories", &row.Categories, book.Categories)
+	setLinks(columns, "tags", &row.Tags, book.Tags)
+
+	row.Version++
+	br.s.books.rows[book.ID] = row
+	*book = row
+	cloneLinks(book)
+	return nil
+}
+
+func (br bookRepository) DeleteByID(_ context.Context, id int32) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	book, ok := br.s.books.rows[id]
+	if !ok || !book.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	book.DeletedAt = time.Now()
+	br.s.books.rows[id] = book
+	return nil
+}
+
+func (br bookRepository) GetDeletedByID(_ context.Context, id int32) (*model.Book, error) {
+	br.s.mu.RLock()
+	defer br.s.mu.RUnlock()
+
+	book, ok := br.s.books.rows[id]
+	if !ok || book.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+
+	cloneLinks(&book)
+	return &book, nil
+}
+
+func (br bookRepository) Restore(_ context.Context, id int32) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	book, ok := br.s.books.rows[id]
+	if !ok || book.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	book.DeletedAt = time.Time{}
+	book.Version++
+	br.s.books.rows[id] = book
+	return nil
+}
+
+func (br bookRepository) Purge(_ context.Context, id int32) error {
+	br.s.mu.Lock()
+	defer br.s.mu.Unlock()
+
+	if book, ok := br.s.books.rows[id]; !ok || book.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	delete(br.s.books.rows, id)
+	for loanID, loan := range br.s.loans.rows {
+		if loan.BookID == id {
+			delete(br.s.loans.rows, loanID)
+		}
+	}
+	for reservationID, reservation := range br.s.reservations.rows {
+		if reservation.BookID == id {
+			delete(br.s.reservations.rows, reservationID)
+		}
+	}
+
+	return nil
+}
+
+// matchBook reports whether book matches filter, categories being the
+// subtree of its category.
+func matchBook(book model.Book, filter store.BookFilter, categories map[int32]bool) bool {
+	if filter.AuthorID != 0 && !slices.ContainsFunc(book.Authors, func(author model.Author) bool {
+		return author.ID == filter.AuthorID
+	}) {
+		return false
+	}
+	if filter.PublisherID != 0 && !slices.ContainsFunc(book.Publishers, func(publisher model.Publisher) bool {
+		return publisher.ID == filter.PublisherID
+	}) {
+		return false
+	}
+	if filter.SubjectID != 0 && !slices.ContainsFunc(book.Subjects, func(subject model.Subject) bool {
+		return subject.ID == filter.SubjectID
+	}) {
+		return false
+	}
+	if filter.CategoryID != 0 && !slices.ContainsFunc(book.Categories, func(category model.Category) bool {
+		return categories[category.ID]
+	}) {
+		return false
+	}
+	if filter.TagID != 0 && !slices.ContainsFunc(book.Tags, func(tag model.Tag) bool {
+		return tag.ID == filter.TagID
+	}) {
+		return false
+	}
+	if filter.Language != "" && book.Language != filter.Language {
+		return false
+	}
+	// Books of unknown year are left out of ranges, as NULL is in SQL.
+	if filter.YearFrom != 0 && (book.PublicationYear == 0 || book.PublicationYear < filter.YearFrom) {
+		return false
+	}
+	if filter.YearTo != 0 && (book.PublicationYear == 0 || book.PublicationYear > filter.YearTo) {
+		return false
+	}
+
+	return book.ID > filter.AfterID
+}
+
+// checkLinks fails with ErrInvalidReference when the book is linked to an
+// entity that doesn't exist.
+func (br bookRepository) checkLinks(book model.Book) error {
+	for _, author := range book.Authors {
+		if _, ok := br.s.authors.rows[author.ID]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+	for _, publisher := range book.Publishers {
+		if _, ok := br.s.publishers.rows[publisher.ID]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+	for _, subject := range book.Subjects {
+		if _, ok := br.s.subjects.rows[subject.ID]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+	for _, category := range book.Categories {
+		if _, ok := br.s.categories.rows[category.ID]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+	for _, tag := range book.Tags {
+		if _, ok := br.s.tags.rows[tag.ID]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+
+	return nil
+}
+
+// cloneLinks keeps the entities linked to book from sharing memory with the
+// store.
+func cloneLinks(book *model.Book) {
+	book.Authors = slices.Clone(book.Authors)
+	book.Publishers = slices.Clone(book.Publishers)
+	book.Subjects = slices.Clone(book.Subjects)
+	book.Categories = slices.Clone(book.Categories)
+	book.Tags = slices.Clone(book.Tags)
+}
+
+// subtree returns the IDs of the category and its descendants.
+func (br bookRepository) subtree(id int32) map[int32]bool {
+	ids := map[int32]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, category := range br.s.categories.rows {
+			if ids[category.ParentID] && !ids[category.ID] {
+				ids[category.ID] = true
+				changed = true
+			}
+		}
+	}
+
+	return ids
+}
+
+// setLinks is setColumn for the links of books.
+func setLinks[T any](columns []string, column string, dst *[]T, src []T) {
+	if len(columns) == 0 {
+		if len(src) > 0 {
+			*dst = src
+		}
+		return
+	}
+	if slices.Contains(columns, column) {
+		*dst = src
+	}
+}
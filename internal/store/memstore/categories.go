@@ -0,0 +1,84 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type categoryRepository struct {
+	s *Store
+}
+
+func (cr categoryRepository) Create(_ context.Context, category *model.Category) error {
+	cr.s.mu.Lock()
+	defer cr.s.mu.Unlock()
+
+	if _, ok := cr.s.categories.rows[category.ParentID]; category.ParentID != 0 && !ok {
+		return store.ErrInvalidReference
+	}
+	for _, sibling := range cr.s.categories.rows {
+		if sibling.ParentID == category.ParentID && sibling.Name == category.Name {
+			return store.ErrConflict
+		}
+	}
+
+	category.ID = cr.s.categories.insert(*category)
+	cr.s.categories.rows[category.ID] = *category
+	return nil
+}
+
+func (cr categoryRepository) GetByID(_ context.Context, id int32) (*model.Category, error) {
+	cr.s.mu.RLock()
+	defer cr.s.mu.RUnlock()
+
+	category, ok := cr.s.categories.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &category, nil
+}
+
+func (cr categoryRepository) List(_ context.Context) ([]model.Category, error) {
+	cr.s.mu.RLock()
+	defer cr.s.mu.RUnlock()
+
+	categories := make([]model.Category, 0, len(cr.s.categories.rows))
+	for _, category := range cr.s.categories.rows {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b model.Category) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+
+	return categories, nil
+}
+
+func (cr categoryRepository) DeleteByID(_ context.Context, id int32) error {
+	cr.s.mu.Lock()
+	defer cr.s.mu.Unlock()
+
+	if _, ok := cr.s.categories.rows[id]; !ok {
+		return store.ErrNotFound
+	}
+	for _, category := range cr.s.categories.rows {
+		if category.ParentID == id {
+			return store.ErrInvalidReference
+		}
+	}
+
+	delete(cr.s.categories.rows, id)
+	for bookID, book := range cr.s.books.rows {
+		book.Categories = slices.DeleteFunc(slices.Clone(book.Categories), func(category model.Category) bool {
+			return category.ID == id
+		})
+		cr.s.books.rows[bookID] = book
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type loanRepository struct {
+	s *Store
+}
+
+func (lr loanRepository) Create(_ context.Context, loan *model.Loan) error {
+	lr.s.mu.Lock()
+	defer lr.s.mu.Unlock()
+
+	if _, ok := lr.s.users.rows[loan.UserID]; !ok {
+		return store.ErrInvalidReference
+	}
+	if _, ok := lr.s.books.rows[loan.BookID]; !ok {
+		return store.ErrInvalidReference
+	}
+
+	loan.ID = lr.s.loans.insert(*loan)
+	lr.s.loans.rows[loan.ID] = *loan
+	return nil
+}
+
+func (lr loanRepository) GetByID(_ context.Context, id int32) (*model.Loan, error) {
+	lr.s.mu.RLock()
+	defer lr.s.mu.RUnlock()
+
+	loan, ok := lr.s.loans.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &loan, nil
+}
+
+func (lr loanRepository) Update(_ context.Context, loan *model.Loan) error {
+	lr.s.mu.Lock()
+	defer lr.s.mu.Unlock()
+
+	row, ok := lr.s.loans.rows[loan.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	setNonZero(&row.UserID, loan.UserID)
+	setNonZero(&row.BookID, loan.BookID)
+	setNonZero(&row.LoanDate, loan.LoanDate)
+	setNonZero(&row.DueDate, loan.DueDate)
+	setNonZero(&row.ReturnDate, loan.ReturnDate)
+
+	lr.s.loans.rows[loan.ID] = row
+	*loan = row
+	return nil
+}
+
+func (lr loanRepository) ListByUserID(_ context.Context, userID int32) ([]model.Loan, error) {
+	return lr.list(func(loan model.Loan) bool {
+		return loan.UserID == userID
+	}), nil
+}
+
+func (lr loanRepository) ListOpenByUserID(_ context.Context, userID int32) ([]model.Loan, error) {
+	return lr.list(func(loan model.Loan) bool {
+		return loan.UserID == userID && !loan.ReturnDate.Valid
+	}), nil
+}
+
+func (lr loanRepository) ListOpenByBookID(_ context.Context, bookID int32) ([]model.Loan, error) {
+	return lr.list(func(loan model.Loan) bool {
+		return loan.BookID == bookID && !loan.ReturnDate.Valid
+	}), nil
+}
+
+func (lr loanRepository) ListOverdue(_ context.Context, now time.Time) ([]model.Loan, error) {
+	return lr.list(func(loan model.Loan) bool {
+		if !loan.ReturnDate.Valid {
+			return now.After(loan.DueDate)
+		}
+
+		return loan.ReturnDate.Time.After(loan.DueDate)
+	}), nil
+}
+
+func (lr loanRepository) MostBorrowed(_ context.Context, limit int) ([]store.BookBorrows, error) {
+	lr.s.mu.RLock()
+	defer lr.s.mu.RUnlock()
+
+	borrows := make(map[int32]int)
+	for _, loan := range lr.s.loans.rows {
+		borrows[loan.BookID]++
+	}
+
+	results := make([]store.BookBorrows, 0, len(borrows))
+	for bookID, count := range borrows {
+		book := lr.s.books.rows[bookID]
+		if !book.DeletedAt.IsZero() {
+			continue
+		}
+
+		results = append(results, store.BookBorrows{
+			ID:      bookID,
+			Title:   book.Title,
+			Borrows: count,
+		})
+	}
+	slices.SortFunc(results, func(a, b store.BookBorrows) int {
+		return cmp.Or(cmp.Compare(b.Borrows, a.Borrows), cmp.Compare(a.ID, b.ID))
+	})
+
+	return results[:min(limit, len(results))], nil
+}
+
+func (lr loanRepository) list(pred func(model.Loan) bool) []model.Loan {
+	lr.s.mu.RLock()
+	defer lr.s.mu.RUnlock()
+
+	var loans []model.Loan
+	for _, loan := range lr.s.loans.rows {
+		if pred(loan) {
+			loans = append(loans, loan)
+		}
+	}
+	slices.SortFunc(loans, func(a, b model.Loan) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return loans
+}
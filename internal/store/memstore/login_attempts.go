@@ -0,0 +1,46 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type loginAttemptRepository struct {
+	s *Store
+}
+
+func (lar loginAttemptRepository) Create(_ context.Context, attempt *model.LoginAttempt) error {
+	lar.s.mu.Lock()
+	defer lar.s.mu.Unlock()
+
+	if attempt.UserID.Valid {
+		if _, ok := lar.s.users.rows[attempt.UserID.Int32]; !ok {
+			return store.ErrInvalidReference
+		}
+	}
+
+	attempt.ID = lar.s.loginAttempts.insert(*attempt)
+	lar.s.loginAttempts.rows[attempt.ID] = *attempt
+	return nil
+}
+
+func (lar loginAttemptRepository) ListByUserID(_ context.Context, userID int32) ([]model.LoginAttempt, error) {
+	lar.s.mu.RLock()
+	defer lar.s.mu.RUnlock()
+
+	var attempts []model.LoginAttempt
+	for _, attempt := range lar.s.loginAttempts.rows {
+		if attempt.UserID.Valid && attempt.UserID.Int32 == userID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	slices.SortFunc(attempts, func(a, b model.LoginAttempt) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return attempts, nil
+}
@@ -0,0 +1,55 @@
+package memstore
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/utilyre/lms/internal/store"
+)
+
+// The helpers below implement the repositories of authors, publishers,
+// subjects and tags, given how to get the name of a row and how to make one.
+
+func ensureNames[T any](t *table[T], names []string, nameOf func(T) string, newRow func(id int32, name string) T) []T {
+	rows := make([]T, 0, len(names))
+	for _, name := range names {
+		row, ok := findName(t, name, nameOf)
+		if !ok {
+			row = newRow(t.nextID, name)
+			t.insert(row)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func findName[T any](t *table[T], name string, nameOf func(T) string) (T, bool) {
+	for _, row := range t.rows {
+		if nameOf(row) == name {
+			return row, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+func listNames[T any](t *table[T], filter store.NameFilter, nameOf func(T) string) []T {
+	rows := []T{}
+	prefix := strings.ToLower(filter.Prefix)
+	for _, row := range t.rows {
+		name := nameOf(row)
+		if strings.HasPrefix(strings.ToLower(name), prefix) && name > filter.After {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b T) int {
+		return strings.Compare(nameOf(a), nameOf(b))
+	})
+	if filter.Limit > 0 && len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+	}
+
+	return rows
+}
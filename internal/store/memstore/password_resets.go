@@ -0,0 +1,78 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type passwordResetRepository struct {
+	s *Store
+}
+
+func (prr passwordResetRepository) Create(_ context.Context, reset *model.PasswordReset) error {
+	prr.s.mu.Lock()
+	defer prr.s.mu.Unlock()
+
+	if _, ok := prr.s.users.rows[reset.UserID]; !ok {
+		return store.ErrInvalidReference
+	}
+	for _, r := range prr.s.passwordResets.rows {
+		if bytes.Equal(r.TokenHash, reset.TokenHash) {
+			return store.ErrConflict
+		}
+	}
+
+	reset.ID = prr.s.passwordResets.insert(*reset)
+	prr.s.passwordResets.rows[reset.ID] = *reset
+	return nil
+}
+
+func (prr passwordResetRepository) GetByTokenHash(_ context.Context, tokenHash []byte) (*model.PasswordReset, error) {
+	prr.s.mu.RLock()
+	defer prr.s.mu.RUnlock()
+
+	for _, reset := range prr.s.passwordResets.rows {
+		if bytes.Equal(reset.TokenHash, tokenHash) {
+			return &reset, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (prr passwordResetRepository) Update(_ context.Context, reset *model.PasswordReset) error {
+	prr.s.mu.Lock()
+	defer prr.s.mu.Unlock()
+
+	row, ok := prr.s.passwordResets.rows[reset.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	setNonZero(&row.UserID, reset.UserID)
+	setNonZero(&row.ExpiresAt, reset.ExpiresAt)
+	setNonZero(&row.UsedAt, reset.UsedAt)
+
+	prr.s.passwordResets.rows[reset.ID] = row
+	*reset = row
+	return nil
+}
+
+func (prr passwordResetRepository) UseUpByUserID(_ context.Context, userID int32, at time.Time) error {
+	prr.s.mu.Lock()
+	defer prr.s.mu.Unlock()
+
+	for id, reset := range prr.s.passwordResets.rows {
+		if reset.UserID == userID && !reset.UsedAt.Valid {
+			reset.UsedAt = sql.NullTime{Time: at, Valid: true}
+			prr.s.passwordResets.rows[id] = reset
+		}
+	}
+
+	return nil
+}
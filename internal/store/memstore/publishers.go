@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type publisherRepository struct {
+	s *Store
+}
+
+func publisherName(publisher model.Publisher) string {
+	return publisher.Name
+}
+
+func (pr publisherRepository) Ensure(_ context.Context, names []string) ([]model.Publisher, error) {
+	pr.s.mu.Lock()
+	defer pr.s.mu.Unlock()
+
+	return ensureNames(&pr.s.publishers, names, publisherName, func(id int32, name string) model.Publisher {
+		return model.Publisher{ID: id, Name: name}
+	}), nil
+}
+
+func (pr publisherRepository) GetByID(_ context.Context, id int32) (*model.Publisher, error) {
+	pr.s.mu.RLock()
+	defer pr.s.mu.RUnlock()
+
+	publisher, ok := pr.s.publishers.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &publisher, nil
+}
+
+func (pr publisherRepository) List(_ context.Context, filter store.NameFilter) ([]model.Publisher, error) {
+	pr.s.mu.RLock()
+	defer pr.s.mu.RUnlock()
+
+	return listNames(&pr.s.publishers, filter, publisherName), nil
+}
@@ -0,0 +1,91 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type refreshTokenRepository struct {
+	s *Store
+}
+
+func (rtr refreshTokenRepository) Create(_ context.Context, token *model.RefreshToken) error {
+	rtr.s.mu.Lock()
+	defer rtr.s.mu.Unlock()
+
+	if _, ok := rtr.s.users.rows[token.UserID]; !ok {
+		return store.ErrInvalidReference
+	}
+	for _, t := range rtr.s.refreshTokens.rows {
+		if bytes.Equal(t.TokenHash, token.TokenHash) {
+			return store.ErrConflict
+		}
+	}
+
+	token.ID = rtr.s.refreshTokens.insert(*token)
+	rtr.s.refreshTokens.rows[token.ID] = *token
+	return nil
+}
+
+func (rtr refreshTokenRepository) GetByTokenHash(_ context.Context, tokenHash []byte) (*model.RefreshToken, error) {
+	rtr.s.mu.RLock()
+	defer rtr.s.mu.RUnlock()
+
+	for _, token := range rtr.s.refreshTokens.rows {
+		if bytes.Equal(token.TokenHash, tokenHash) {
+			return &token, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (rtr refreshTokenRepository) Update(_ context.Context, token *model.RefreshToken) error {
+	rtr.s.mu.Lock()
+	defer rtr.s.mu.Unlock()
+
+	row, ok := rtr.s.refreshTokens.rows[token.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	setNonZero(&row.UserID, token.UserID)
+	setNonZero(&row.ExpiresAt, token.ExpiresAt)
+	setNonZero(&row.UsedAt, token.UsedAt)
+	setNonZero(&row.RevokedAt, token.RevokedAt)
+
+	rtr.s.refreshTokens.rows[token.ID] = row
+	*token = row
+	return nil
+}
+
+func (rtr refreshTokenRepository) RevokeByFamilyID(_ context.Context, familyID []byte, at time.Time) error {
+	rtr.revokeWhere(func(token model.RefreshToken) bool {
+		return bytes.Equal(token.FamilyID, familyID)
+	}, at)
+	return nil
+}
+
+func (rtr refreshTokenRepository) RevokeByUserID(_ context.Context, userID int32, at time.Time) error {
+	rtr.revokeWhere(func(token model.RefreshToken) bool {
+		return token.UserID == userID
+	}, at)
+	return nil
+}
+
+func (rtr refreshTokenRepository) revokeWhere(match func(model.RefreshToken) bool, at time.Time) {
+	rtr.s.mu.Lock()
+	defer rtr.s.mu.Unlock()
+
+	for id, token := range rtr.s.refreshTokens.rows {
+		if match(token) && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: at, Valid: true}
+			rtr.s.refreshTokens.rows[id] = token
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type reservationRepository struct {
+	s *Store
+}
+
+func (rr reservationRepository) Create(_ context.Context, reservation *model.Reservation) error {
+	rr.s.mu.Lock()
+	defer rr.s.mu.Unlock()
+
+	if _, ok := rr.s.users.rows[reservation.UserID]; !ok {
+		return store.ErrInvalidReference
+	}
+	if _, ok := rr.s.books.rows[reservation.BookID]; !ok {
+		return store.ErrInvalidReference
+	}
+
+	reservation.ID = rr.s.reservations.insert(*reservation)
+	rr.s.reservations.rows[reservation.ID] = *reservation
+	return nil
+}
+
+func (rr reservationRepository) GetByID(_ context.Context, id int32) (*model.Reservation, error) {
+	rr.s.mu.RLock()
+	defer rr.s.mu.RUnlock()
+
+	reservation, ok := rr.s.reservations.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &reservation, nil
+}
+
+func (rr reservationRepository) GetByBookID(_ context.Context, bookID int32) (*model.Reservation, error) {
+	rr.s.mu.RLock()
+	defer rr.s.mu.RUnlock()
+
+	var found *model.Reservation
+	for _, reservation := range rr.s.reservations.rows {
+		if reservation.BookID != bookID || reservation.CanceledAt.Valid {
+			continue
+		}
+		if found == nil || reservation.ID < found.ID {
+			found = &reservation
+		}
+	}
+	if found == nil {
+		return nil, store.ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (rr reservationRepository) ListByUserID(_ context.Context, userID int32) ([]model.Reservation, error) {
+	return rr.list(func(reservation model.Reservation) bool {
+		return reservation.UserID == userID
+	}), nil
+}
+
+func (rr reservationRepository) ListActiveByUserID(_ context.Context, userID int32) ([]model.Reservation, error) {
+	return rr.list(func(reservation model.Reservation) bool {
+		return reservation.UserID == userID && !reservation.CanceledAt.Valid
+	}), nil
+}
+
+func (rr reservationRepository) ListActiveByBookID(_ context.Context, bookID int32) ([]model.Reservation, error) {
+	return rr.list(func(reservation model.Reservation) bool {
+		return reservation.BookID == bookID && !reservation.CanceledAt.Valid
+	}), nil
+}
+
+func (rr reservationRepository) Update(_ context.Context, reservation *model.Reservation) error {
+	rr.s.mu.Lock()
+	defer rr.s.mu.Unlock()
+
+	row, ok := rr.s.reservations.rows[reservation.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	setNonZero(&row.UserID, reservation.UserID)
+	setNonZero(&row.BookID, reservation.BookID)
+	setNonZero(&row.CanceledAt, reservation.CanceledAt)
+
+	rr.s.reservations.rows[reservation.ID] = row
+	*reservation = row
+	return nil
+}
+
+func (rr reservationRepository) list(pred func(model.Reservation) bool) []model.Reservation {
+	rr.s.mu.RLock()
+	defer rr.s.mu.RUnlock()
+
+	var reservations []model.Reservation
+	for _, reservation := range rr.s.reservations.rows {
+		if pred(reservation) {
+			reservations = append(reservations, reservation)
+		}
+	}
+	slices.SortFunc(reservations, func(a, b model.Reservation) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return reservations
+}
@@ -0,0 +1,128 @@
+package memstore
+
+import (
+	"sync"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+// Store keeps every entity in maps guarded by a single lock. It mirrors the
+// constraints of the database schema closely enough for service tests, and
+// hands out copies so that callers never share memory with it.
+type Store struct {
+	mu   sync.RWMutex
+	txMu sync.Mutex
+
+	users        table[model.User]
+	books        table[model.Book]
+	loans        table[model.Loan]
+	reservations table[model.Reservation]
+
+	authors    table[model.Author]
+	publishers table[model.Publisher]
+	subjects   table[model.Subject]
+	categories table[model.Category]
+	tags       table[model.Tag]
+
+	passwordResets table[model.PasswordReset]
+	loginAttempts  table[model.LoginAttempt]
+	refreshTokens  table[model.RefreshToken]
+	apiKeys        table[model.APIKey]
+	auditLog       table[model.AuditEntry]
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:        newTable[model.User](),
+		books:        newTable[model.Book](),
+		loans:        newTable[model.Loan](),
+		reservations: newTable[model.Reservation](),
+
+		authors:    newTable[model.Author](),
+		publishers: newTable[model.Publisher](),
+		subjects:   newTable[model.Subject](),
+		categories: newTable[model.Category](),
+		tags:       newTable[model.Tag](),
+
+		passwordResets: newTable[model.PasswordReset](),
+		loginAttempts:  newTable[model.LoginAttempt](),
+		refreshTokens:  newTable[model.RefreshToken](),
+		apiKeys:        newTable[model.APIKey](),
+		auditLog:       newTable[model.AuditEntry](),
+	}
+}
+
+func (s *Store) Users() store.UserRepository {
+	return userRepository{s: s}
+}
+
+func (s *Store) Books() store.BookRepository {
+	return bookRepository{s: s}
+}
+
+func (s *Store) Authors() store.AuthorRepository {
+	return authorRepository{s: s}
+}
+
+func (s *Store) Publishers() store.PublisherRepository {
+	return publisherRepository{s: s}
+}
+
+func (s *Store) Subjects() store.SubjectRepository {
+	return subjectRepository{s: s}
+}
+
+func (s *Store) Categories() store.CategoryRepository {
+	return categoryRepository{s: s}
+}
+
+func (s *Store) Tags() store.TagRepository {
+	return tagRepository{s: s}
+}
+
+func (s *Store) Loans() store.LoanRepository {
+	return loanRepository{s: s}
+}
+
+func (s *Store) Reservations() store.ReservationRepository {
+	return reservationRepository{s: s}
+}
+
+func (s *Store) PasswordResets() store.PasswordResetRepository {
+	return passwordResetRepository{s: s}
+}
+
+func (s *Store) LoginAttempts() store.LoginAttemptRepository {
+	return loginAttemptRepository{s: s}
+}
+
+func (s *Store) RefreshTokens() store.RefreshTokenRepository {
+	return refreshTokenRepository{s: s}
+}
+
+func (s *Store) APIKeys() store.APIKeyRepository {
+	return apiKeyRepository{s: s}
+}
+
+func (s *Store) AuditLog() store.AuditLogRepository {
+	return auditLogRepository{s: s}
+}
+
+type table[T any] struct {
+	rows   map[int32]T
+	nextID int32
+}
+
+func newTable[T any]() table[T] {
+	return table[T]{rows: make(map[int32]T), nextID: 1}
+}
+
+func (t *table[T]) insert(row T) int32 {
+	id := t.nextID
+	t.nextID++
+	t.rows[id] = row
+	return id
+}
@@ -0,0 +1,192 @@
+package memstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+	"github.com/utilyre/lms/internal/store/memstore"
+)
+
+func TestConcurrentCreate(t *testing.T) {
+	st := memstore.New()
+
+	const n = 100
+	ids := make(chan int32, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			user := model.User{Email: fmt.Sprintf("user%d@example.com", i)}
+			if err := st.Users().Create(context.Background(), &user); err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- user.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int32]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("id %d assigned twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("created %d users; want %d", len(seen), n)
+	}
+}
+
+func TestReturnsCopies(t *testing.T) {
+	st := memstore.New()
+	ctx := context.Background()
+
+	book := model.Book{Title: "Original"}
+	if err := st.Books().Create(ctx, &book); err != nil {
+		t.Fatal(err)
+	}
+	book.Title = "Changed"
+
+	got, err := st.Books().GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Original" {
+		t.Fatalf("title = %q; want %q", got.Title, "Original")
+	}
+}
+
+func TestDeleteKeepsLoans(t *testing.T) {
+	st := memstore.New()
+	ctx := context.Background()
+
+	user := model.User{Email: "jane@example.com"}
+	book := model.Book{Title: "Title"}
+	if err := st.Users().Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Books().Create(ctx, &book); err != nil {
+		t.Fatal(err)
+	}
+
+	loan := model.Loan{UserID: user.ID, BookID: book.ID}
+	if err := st.Loans().Create(ctx, &loan); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users().DeleteByID(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Books().DeleteByID(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.Users().GetByID(ctx, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("deleted user: err = %v; want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.Loans().GetByID(ctx, loan.ID); err != nil {
+		t.Fatalf("loan of deleted user: %v", err)
+	}
+	if borrows, err := st.Loans().MostBorrowed(ctx, 10); err != nil || len(borrows) != 0 {
+		t.Fatalf("most borrowed = %v, %v; want none", borrows, err)
+	}
+
+	// Deleted users give up their email.
+	if err := st.Users().Create(ctx, &model.User{Email: user.Email}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users().Restore(ctx, user.ID); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("restore: err = %v; want %v", err, store.ErrConflict)
+	}
+}
+
+func TestPurgeCascades(t *testing.T) {
+	st := memstore.New()
+	ctx := context.Background()
+
+	user := model.User{Email: "jane@example.com"}
+	book := model.Book{Title: "Title"}
+	if err := st.Users().Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Books().Create(ctx, &book); err != nil {
+		t.Fatal(err)
+	}
+
+	loan := model.Loan{UserID: user.ID, BookID: book.ID}
+	if err := st.Loans().Create(ctx, &loan); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users().Purge(ctx, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("purge before deletion: err = %v; want %v", err, store.ErrNotFound)
+	}
+	if err := st.Users().DeleteByID(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users().Purge(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.Loans().GetByID(ctx, loan.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("err = %v; want %v", err, store.ErrNotFound)
+	}
+	if err := st.Loans().Create(ctx, &model.Loan{UserID: user.ID, BookID: book.ID}); !errors.Is(err, store.ErrInvalidReference) {
+		t.Fatalf("err = %v; want %v", err, store.ErrInvalidReference)
+	}
+}
+
+func TestRunInTxRollsBack(t *testing.T) {
+	st := memstore.New()
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var book model.Book
+	err := st.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		book = model.Book{Title: "Title"}
+		if err := tx.Books().Create(ctx, &book); err != nil {
+			return err
+		}
+
+		// Nested calls join the running transaction instead of deadlocking.
+		return tx.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+			if _, err := tx.Books().GetByID(ctx, book.ID); err != nil {
+				return err
+			}
+
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err = %v; want %v", err, errAbort)
+	}
+
+	if _, err := st.Books().GetByID(ctx, book.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("err = %v; want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestRunInTxCommits(t *testing.T) {
+	st := memstore.New()
+	ctx := context.Background()
+
+	var book model.Book
+	if err := st.RunInTx(ctx, func(ctx context.Context, tx store.Store) error {
+		book = model.Book{Title: "Title"}
+		return tx.Books().Create(ctx, &book)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.Books().GetByID(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type subjectRepository struct {
+	s *Store
+}
+
+func subjectName(subject model.Subject) string {
+	return subject.Name
+}
+
+func (sr subjectRepository) Ensure(_ context.Context, names []string) ([]model.Subject, error) {
+	sr.s.mu.Lock()
+	defer sr.s.mu.Unlock()
+
+	return ensureNames(&sr.s.subjects, names, subjectName, func(id int32, name string) model.Subject {
+		return model.Subject{ID: id, Name: name}
+	}), nil
+}
+
+func (sr subjectRepository) GetByID(_ context.Context, id int32) (*model.Subject, error) {
+	sr.s.mu.RLock()
+	defer sr.s.mu.RUnlock()
+
+	subject, ok := sr.s.subjects.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &subject, nil
+}
+
+func (sr subjectRepository) List(_ context.Context, filter store.NameFilter) ([]model.Subject, error) {
+	sr.s.mu.RLock()
+	defer sr.s.mu.RUnlock()
+
+	return listNames(&sr.s.subjects, filter, subjectName), nil
+}
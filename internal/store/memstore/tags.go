@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type tagRepository struct {
+	s *Store
+}
+
+func tagName(tag model.Tag) string {
+	return tag.Name
+}
+
+func (tr tagRepository) Ensure(_ context.Context, names []string) ([]model.Tag, error) {
+	tr.s.mu.Lock()
+	defer tr.s.mu.Unlock()
+
+	return ensureNames(&tr.s.tags, names, tagName, func(id int32, name string) model.Tag {
+		return model.Tag{ID: id, Name: name}
+	}), nil
+}
+
+func (tr tagRepository) GetByID(_ context.Context, id int32) (*model.Tag, error) {
+	tr.s.mu.RLock()
+	defer tr.s.mu.RUnlock()
+
+	tag, ok := tr.s.tags.rows[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &tag, nil
+}
+
+func (tr tagRepository) List(_ context.Context, filter store.NameFilter) ([]model.Tag, error) {
+	tr.s.mu.RLock()
+	defer tr.s.mu.RUnlock()
+
+	return listNames(&tr.s.tags, filter, tagName), nil
+}
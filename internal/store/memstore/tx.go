@@ -0,0 +1,98 @@
+package memstore
+
+import (
+	"context"
+	"maps"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type txKey struct{}
+
+// RunInTx serializes transactions with one another and undoes the writes of
+// fn when it fails. Operations made outside of a transaction are not isolated
+// from it.
+func (s *Store) RunInTx(ctx context.Context, fn func(ctx context.Context, tx store.Store) error) error {
+	if ctx.Value(txKey{}) == s {
+		return fn(ctx, s)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	snap := s.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{}, s), s); err != nil {
+		s.restore(snap)
+		return err
+	}
+
+	return nil
+}
+
+type snapshot struct {
+	users        table[model.User]
+	books        table[model.Book]
+	loans        table[model.Loan]
+	reservations table[model.Reservation]
+
+	authors    table[model.Author]
+	publishers table[model.Publisher]
+	subjects   table[model.Subject]
+	categories table[model.Category]
+	tags       table[model.Tag]
+
+	passwordResets table[model.PasswordReset]
+	loginAttempts  table[model.LoginAttempt]
+	refreshTokens  table[model.RefreshToken]
+	apiKeys        table[model.APIKey]
+	auditLog       table[model.AuditEntry]
+}
+
+func (s *Store) snapshot() snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return snapshot{
+		users:        s.users.clone(),
+		books:        s.books.clone(),
+		loans:        s.loans.clone(),
+		reservations: s.reservations.clone(),
+
+		authors:    s.authors.clone(),
+		publishers: s.publishers.clone(),
+		subjects:   s.subjects.clone(),
+		categories: s.categories.clone(),
+		tags:       s.tags.clone(),
+
+		passwordResets: s.passwordResets.clone(),
+		loginAttempts:  s.loginAttempts.clone(),
+		refreshTokens:  s.refreshTokens.clone(),
+		apiKeys:        s.apiKeys.clone(),
+		auditLog:       s.auditLog.clone(),
+	}
+}
+
+func (s *Store) restore(snap snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = snap.users
+	s.books = snap.books
+	s.loans = snap.loans
+	s.reservations = snap.reservations
+	s.authors = snap.authors
+	s.publishers = snap.publishers
+	s.subjects = snap.subjects
+	s.categories = snap.categories
+	s.tags = snap.tags
+	s.passwordResets = snap.passwordResets
+	s.loginAttempts = snap.loginAttempts
+	s.refreshTokens = snap.refreshTokens
+	s.apiKeys = snap.apiKeys
+	s.auditLog = snap.auditLog
+}
+
+func (t table[T]) clone() table[T] {
+	return table[T]{rows: maps.Clone(t.rows), nextID: t.nextID}
+}
@@ -0,0 +1,242 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+	"github.com/utilyre/lms/internal/store"
+)
+
+type userRepository struct {
+	s *Store
+}
+
+func (ur userRepository) Create(_ context.Context, user *model.User) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	if ur.emailTaken(user.Email, 0) {
+		return store.ErrConflict
+	}
+
+	user.Version = 1
+	user.ID = ur.s.users.insert(*user)
+	ur.s.users.rows[user.ID] = *user
+	return nil
+}
+
+func (ur userRepository) GetByID(_ context.Context, id int32) (*model.User, error) {
+	ur.s.mu.RLock()
+	defer ur.s.mu.RUnlock()
+
+	user, ok := ur.s.users.rows[id]
+	if !ok || !user.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (ur userRepository) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	ur.s.mu.RLock()
+	defer ur.s.mu.RUnlock()
+
+	for _, user := range ur.s.users.rows {
+		if user.Email == email && user.DeletedAt.IsZero() {
+			return &user, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (ur userRepository) Update(_ context.Context, user *model.User, columns ...string) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	row, ok := ur.s.users.rows[user.ID]
+	if !ok || !row.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	if user.Email != "" && user.Email != row.Email && ur.emailTaken(user.Email, user.ID) {
+		return store.ErrConflict
+	}
+
+	setColumn(columns, "name", &row.Name, user.Name)
+	setColumn(columns, "email", &row.Email, user.Email)
+	setColumn(columns, "role", &row.Role, user.Role)
+	if (len(columns) == 0 && user.Password != nil) || slices.Contains(columns, "password") {
+		row.Password = user.Password
+	}
+
+	row.Version++
+	ur.s.users.rows[user.ID] = row
+	*user = row
+	return nil
+}
+
+func (ur userRepository) DeleteByID(_ context.Context, id int32) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	user, ok := ur.s.users.rows[id]
+	if !ok || !user.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	user.DeletedAt = time.Now()
+	ur.s.users.rows[id] = user
+	return nil
+}
+
+func (ur userRepository) GetDeletedByID(_ context.Context, id int32) (*model.User, error) {
+	ur.s.mu.RLock()
+	defer ur.s.mu.RUnlock()
+
+	user, ok := ur.s.users.rows[id]
+	if !ok || user.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (ur userRepository) Restore(_ context.Context, id int32) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	user, ok := ur.s.users.rows[id]
+	if !ok || user.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+	if ur.emailTaken(user.Email, id) {
+		return store.ErrConflict
+	}
+
+	user.DeletedAt = time.Time{}
+	user.Version++
+	ur.s.users.rows[id] = user
+	return nil
+}
+
+func (ur userRepository) Purge(_ context.Context, id int32) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	if user, ok := ur.s.users.rows[id]; !ok || user.DeletedAt.IsZero() {
+		return store.ErrNotFound
+	}
+
+	delete(ur.s.users.rows, id)
+	for loanID, loan := range ur.s.loans.rows {
+		if loan.UserID == id {
+			delete(ur.s.loans.rows, loanID)
+		}
+	}
+	for reservationID, reservation := range ur.s.reservations.rows {
+		if reservation.UserID == id {
+			delete(ur.s.reservations.rows, reservationID)
+		}
+	}
+	for resetID, reset := range ur.s.passwordResets.rows {
+		if reset.UserID == id {
+			delete(ur.s.passwordResets.rows, resetID)
+		}
+	}
+	for tokenID, token := range ur.s.refreshTokens.rows {
+		if token.UserID == id {
+			delete(ur.s.refreshTokens.rows, tokenID)
+		}
+	}
+	for keyID, key := range ur.s.apiKeys.rows {
+		if key.UserID == id {
+			delete(ur.s.apiKeys.rows, keyID)
+		}
+	}
+	for attemptID, attempt := range ur.s.loginAttempts.rows {
+		if attempt.UserID.Valid && attempt.UserID.Int32 == id {
+			attempt.UserID = sql.NullInt32{}
+			ur.s.loginAttempts.rows[attemptID] = attempt
+		}
+	}
+
+	return nil
+}
+
+func (ur userRepository) Anonymize(_ context.Context, user *model.User) error {
+	ur.s.mu.Lock()
+	defer ur.s.mu.Unlock()
+
+	row, ok := ur.s.users.rows[user.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if ur.emailTaken(user.Email, user.ID) {
+		return store.ErrConflict
+	}
+
+	row.Name = user.Name
+	row.Email = user.Email
+	row.Password = user.Password
+	row.Version++
+	ur.s.users.rows[user.ID] = row
+	*user = row
+
+	for resetID, reset := range ur.s.passwordResets.rows {
+		if reset.UserID == user.ID {
+			delete(ur.s.passwordResets.rows, resetID)
+		}
+	}
+	for tokenID, token := range ur.s.refreshTokens.rows {
+		if token.UserID == user.ID {
+			delete(ur.s.refreshTokens.rows, tokenID)
+		}
+	}
+	for keyID, key := range ur.s.apiKeys.rows {
+		if key.UserID == user.ID {
+			delete(ur.s.apiKeys.rows, keyID)
+		}
+	}
+	for attemptID, attempt := range ur.s.loginAttempts.rows {
+		if attempt.UserID.Valid && attempt.UserID.Int32 == user.ID {
+			delete(ur.s.loginAttempts.rows, attemptID)
+		}
+	}
+
+	return nil
+}
+
+// emailTaken reports whether a user other than the one identified by id,
+// which isn't deleted, has email. Callers must hold the lock.
+func (ur userRepository) emailTaken(email string, id int32) bool {
+	for _, user := range ur.s.users.rows {
+		if user.ID != id && user.Email == email && user.DeletedAt.IsZero() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setNonZero[T comparable](dst *T, src T) {
+	var zero T
+	if src != zero {
+		*dst = src
+	}
+}
+
+// setColumn copies src to dst when columns contains column or, if no columns
+// are given, when src is non-zero.
+func setColumn[T comparable](columns []string, column string, dst *T, src T) {
+	if len(columns) == 0 {
+		setNonZero(dst, src)
+		return
+	}
+	if slices.Contains(columns, column) {
+		*dst = src
+	}
+}
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/utilyre/lms/internal/model"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// Store groups the repositories of every entity. Repositories return
+// ErrNotFound when the requested row doesn't exist, ErrConflict when a write
+// violates a uniqueness constraint and ErrInvalidReference when it refers to
+// a row that doesn't exist.
+type Store interface {
+	Users() UserRepository
+	Books() BookRepository
+	Authors() AuthorRepository
+	Publishers() PublisherRepository
+	Subjects() SubjectRepository
+	Categories() CategoryRepository
+	Tags() TagRepository
+	Loans() LoanRepository
+	Reservations() ReservationRepository
+	PasswordResets() PasswordResetRepository
+	LoginAttempts() LoginAttemptRepository
+	RefreshTokens() RefreshTokenRepository
+	APIKeys() APIKeyRepository
+	AuditLog() AuditLogRepository
+
+	// RunInTx calls fn with a Store whose repositories all operate within a
+	// single transaction, which is committed when fn returns nil and rolled
+	// back otherwise. The transaction is retried from the start when it fails
+	// to serialize with concurrent ones, so fn must not have side effects
+	// outside of tx. Calling RunInTx on tx joins the running transaction.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, tx Store) error) error
+}
+
+type UserRepository interface {
+	// Create inserts user at version 1.
+	Create(ctx context.Context, user *model.User) error
+	GetByID(ctx context.Context, id int32) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	// Update writes the given columns of user, or all of its non-zero fields
+	// when no columns are given, to the row identified by user.ID, bumps its
+	// version and then reloads user from that row.
+	Update(ctx context.Context, user *model.User, columns ...string) error
+	// DeleteByID marks the user as deleted, after which the other methods
+	// treat it as if it didn't exist, except for those below.
+	DeleteByID(ctx context.Context, id int32) error
+	// GetDeletedByID returns the user only if it's deleted.
+	GetDeletedByID(ctx context.Context, id int32) (*model.User, error)
+	// Restore undoes the deletion of the user and bumps its version.
+	Restore(ctx context.Context, id int32) error
+	// Purge permanently removes the deleted user along with its loans,
+	// reservations, tokens and API keys.
+	Purge(ctx context.Context, id int32) error
+	// Anonymize overwrites the name, email and password of the user
+	// identified by user.ID, deleted or not, with those of user, bumps its
+	// version and reloads user from its row. It also removes the user's
+	// password resets, refresh tokens, API keys and login attempts, leaving
+	// only their loans and reservations.
+	Anonymize(ctx context.Context, user *model.User) error
+}
+
+// BookRepository stores books along with their links to authors, publishers,
+// subjects, categories and tags, which must already exist. Books are
+// returned with the entities they're linked to, and written with those of
+// their Authors, Publishers, Subjects, Categories and Tags fields,
+// identified by ID.
+type BookRepository interface {
+	// Create inserts book at version 1.
+	Create(ctx context.Context, book *model.Book) error
+	// CreateMany inserts books at version 1 in batches, which is much faster
+	// than creating them one at a time.
+	CreateMany(ctx context.Context, books []model.Book) error
+	GetByID(ctx context.Context, id int32) (*model.Book, error)
+	// List lists the books matching filter, ordered by ID.
+	List(ctx context.Context, filter BookFilter) ([]model.Book, error)
+	// ListByISBNs lists the books that have any of the ISBNs, ordered by ID.
+	ListByISBNs(ctx context.Context, isbns []string) ([]model.Book, error)
+	// ListShelf lists the books matching filter in shelf order, that is by
+	// call number key and then by ID.
+	ListShelf(ctx context.Context, filter ShelfFilter) ([]model.Book, error)
+	// Update writes the given columns of book, or all of its non-zero fields
+	// when no columns are given, to the row identified by book.ID, bumps its
+	// version and then reloads book from that row. The columns "authors",
+	// "publishers", "subjects", "categories" and "tags" replace the links of
+	// the book.
+	Update(ctx context.Context, book *model.Book, columns ...string) error
+	// DeleteByID marks the book as deleted, just like that of users.
+	DeleteByID(ctx context.Context, id int32) error
+	GetDeletedByID(ctx context.Context, id int32) (*model.Book, error)
+	Restore(ctx context.Context, id int32) error
+	// Purge permanently removes the deleted book along with its loans and
+	// reservations.
+	Purge(ctx context.Context, id int32) error
+}
+
+// BookFilter narrows down books. Zero fields match any book.
+type BookFilter struct {
+	AuthorID    int32
+	PublisherID int32
+	SubjectID   int32
+	// CategoryID matches books in the category or any of its descendants.
+	CategoryID int32
+	TagID      int32
+	Language   string
+	// YearFrom and YearTo bound the publication year, inclusively.
+	YearFrom int32
+	YearTo   int32
+	// AfterID only matches books newer than the one it identifies, for
+	// paging through the catalogue.
+	AfterID int32
+	Limit   int
+}
+
+type AuthorRepository interface {
+	// Ensure returns the authors with the names, which must be distinct, in
+	// the same order, creating those that don't exist yet.
+	Ensure(ctx context.Context, names []string) ([]model.Author, error)
+	GetByID(ctx context.Context, id int32) (*model.Author, error)
+	// List lists the authors matching filter, ordered by name.
+	List(ctx context.Context, filter NameFilter) ([]model.Author, error)
+}
+
+// PublisherRepository is the same as AuthorRepository, for publishers.
+type PublisherRepository interface {
+	Ensure(ctx context.Context, names []string) ([]model.Publisher, error)
+	GetByID(ctx context.Context, id int32) (*model.Publisher, error)
+	List(ctx context.Context, filter NameFilter) ([]model.Publisher, error)
+}
+
+// SubjectRepository is the same as AuthorRepository, for subjects.
+type SubjectRepository interface {
+	Ensure(ctx context.Context, names []string) ([]model.Subject, error)
+	GetByID(ctx context.Context, id int32) (*model.Subject, error)
+	List(ctx context.Context, filter NameFilter) ([]model.Subject, error)
+}
+
+// ShelfFilter narrows down the books of a classification that have call
+// numbers.
+type ShelfFilter struct {
+	Classification string
+	// From and Before bound the call number keys, the former inclusively
+	// and the latter exclusively.
+	From   string
+	Before string
+	// AfterID only matches books shelved after the one it identifies, for
+	// paging through the shelf.
+	AfterID int32
+	Limit   int
+}
+
+type CategoryRepository interface {
+	// Create inserts category, failing with ErrConflict when its parent
+	// already has a child with its name and with ErrInvalidReference when
+	// its parent doesn't exist.
+	Create(ctx context.Context, category *model.Category) error
+	GetByID(ctx context.Context, id int32) (*model.Category, error)
+	// List lists every category, ordered by name.
+	List(ctx context.Context) ([]model.Category, error)
+	// DeleteByID deletes the category, unlinking it from its books. It fails
+	// with ErrInvalidReference when the category has children.
+	DeleteByID(ctx context.Context, id int32) error
+}
+
+// TagRepository is the same as AuthorRepository, for tags.
+type TagRepository interface {
+	Ensure(ctx context.Context, names []string) ([]model.Tag, error)
+	GetByID(ctx context.Context, id int32) (*model.Tag, error)
+	List(ctx context.Context, filter NameFilter) ([]model.Tag, error)
+}
+
+// NameFilter narrows down authors, publishers, subjects or tags. Zero fields match
+// any of them.
+type NameFilter struct {
+	// Prefix only matches names starting with it, regardless of case.
+	Prefix string
+	// After only matches names that sort after it, for paging through them.
+	After string
+	Limit int
+}
+
+type BookBorrows struct {
+	ID      int32
+	Title   string
+	Borrows int
+}
+
+type LoanRepository interface {
+	Create(ctx context.Context, loan *model.Loan) error
+	GetByID(ctx context.Context, id int32) (*model.Loan, error)
+	// Update writes the non-zero fields of loan to the row identified by
+	// loan.ID and then reloads loan from that row.
+	Update(ctx context.Context, loan *model.Loan) error
+	ListByUserID(ctx context.Context, userID int32) ([]model.Loan, error)
+	// ListOpenByUserID lists the loans of the user that haven't been
+	// returned, from oldest to newest.
+	ListOpenByUserID(ctx context.Context, userID int32) ([]model.Loan, error)
+	// ListOpenByBookID does the same for the loans of the book.
+	ListOpenByBookID(ctx context.Context, bookID int32) ([]model.Loan, error)
+	// ListOverdue lists loans that are still out past their due date as of
+	// now, or that were returned late.
+	ListOverdue(ctx context.Context, now time.Time) ([]model.Loan, error)
+	// MostBorrowed lists at most limit books that aren't deleted ordered by
+	// how many times they have been borrowed.
+	MostBorrowed(ctx context.Context, limit int) ([]BookBorrows, error)
+}
+
+type ReservationRepository interface {
+	Create(ctx context.Context, reservation *model.Reservation) error
+	GetByID(ctx context.Context, id int32) (*model.Reservation, error)
+	// GetByBookID returns the oldest reservation of the book that hasn't been
+	// canceled.
+	GetByBookID(ctx context.Context, bookID int32) (*model.Reservation, error)
+	// ListByUserID lists the reservations of the user from oldest to newest.
+	ListByUserID(ctx context.Context, userID int32) ([]model.Reservation, error)
+	// ListActiveByUserID lists the reservations of the user that haven't
+	// been canceled, from oldest to newest.
+	ListActiveByUserID(ctx context.Context, userID int32) ([]model.Reservation, error)
+	// ListActiveByBookID does the same for the reservations of the book.
+	ListActiveByBookID(ctx context.Context, bookID int32) ([]model.Reservation, error)
+	// Update writes the non-zero fields of reservation to the row identified
+	// by reservation.ID and then reloads reservation from that row.
+	Update(ctx context.Context, reservation *model.Reservation) error
+}
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *model.PasswordReset) error
+	GetByTokenHash(ctx context.Context, tokenHash []byte) (*model.PasswordReset, error)
+	// Update writes the non-zero fields of reset to the row identified by
+	// reset.ID and then reloads reset from that row.
+	Update(ctx context.Context, reset *model.PasswordReset) error
+	// UseUpByUserID marks the unused resets of the user as used at the given
+	// time, so that their tokens no longer work.
+	UseUpByUserID(ctx context.Context, userID int32, at time.Time) error
+}
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *model.LoginAttempt) error
+	// ListByUserID lists the attempts to log in as the user from oldest to
+	// newest.
+	ListByUserID(ctx context.Context, userID int32) ([]model.LoginAttempt, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByTokenHash(ctx context.Context, tokenHash []byte) (*model.RefreshToken, error)
+	// Update writes the non-zero fields of token to the row identified by
+	// token.ID and then reloads token from that row.
+	Update(ctx context.Context, token *model.RefreshToken) error
+	// RevokeByFamilyID revokes the tokens of the family that aren't revoked
+	// yet as of at.
+	RevokeByFamilyID(ctx context.Context, familyID []byte, at time.Time) error
+	// RevokeByUserID does the same for every token of the user.
+	RevokeByUserID(ctx context.Context, userID int32, at time.Time) error
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByID(ctx context.Context, id int32) (*model.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	// ListByUserID lists the keys of the user from oldest to newest.
+	ListByUserID(ctx context.Context, userID int32) ([]model.APIKey, error)
+	// Update writes the non-zero fields of key to the row identified by
+	// key.ID and then reloads key from that row.
+	Update(ctx context.Context, key *model.APIKey) error
+	DeleteByID(ctx context.Context, id int32) error
+}
+
+// AuditFilter narrows down audit log entries. Zero fields match any entry.
+type AuditFilter struct {
+	ActorID  int32
+	Action   string
+	Entity   string
+	EntityID int32
+	// Since and Until bound when entries were made, inclusively.
+	Since time.Time
+	Until time.Time
+	// BeforeID only matches entries older than the one it identifies, for
+	// paging through the log.
+	BeforeID int32
+	Limit    int
+}
+
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *model.AuditEntry) error
+	// CreateMany inserts entries in batches, just like that of books.
+	CreateMany(ctx context.Context, entries []model.AuditEntry) error
+	// List lists the entries matching filter from newest to oldest.
+	List(ctx context.Context, filter AuditFilter) ([]model.AuditEntry, error)
+	// ListByUserID lists the entries of actions the user took or that were
+	// taken on them from oldest to newest.
+	ListByUserID(ctx context.Context, userID int32) ([]model.AuditEntry, error)
+	// ClearIPByActorID clears the IP of the entries of actions the user took,
+	// the only change entries allow.
+	ClearIPByActorID(ctx context.Context, actorID int32) error
+}
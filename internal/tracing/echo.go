@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// JSONSerializer wraps echo's default serializer in spans so that encoding
+// and decoding time shows up separately from the handler's own work.
+type JSONSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+func (js JSONSerializer) Serialize(c echo.Context, i any, indent string) (err error) {
+	_, span := Start(c.Request().Context(), "json.Encode")
+	defer func() { End(span, err) }()
+
+	return js.DefaultJSONSerializer.Serialize(c, i, indent)
+}
+
+func (js JSONSerializer) Deserialize(c echo.Context, i any) (err error) {
+	_, span := Start(c.Request().Context(), "json.Decode")
+	defer func() { End(span, err) }()
+
+	return js.DefaultJSONSerializer.Deserialize(c, i)
+}
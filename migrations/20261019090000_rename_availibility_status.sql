@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "books" RENAME COLUMN "availibility_status" TO "availability_status";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "books" RENAME COLUMN "availability_status" TO "availibility_status";
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "reservations" ADD COLUMN "canceled_at" TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "reservations" WHERE "canceled_at" IS NOT NULL;
+ALTER TABLE "reservations" DROP COLUMN "canceled_at";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "books" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "books" DROP COLUMN "version";
+ALTER TABLE "users" DROP COLUMN "version";
+-- +goose StatementEnd
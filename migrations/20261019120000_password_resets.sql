@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "password_resets" (
+    "id" SERIAL PRIMARY KEY,
+
+    "user_id" INTEGER NOT NULL REFERENCES "users" ON DELETE CASCADE,
+    "token_hash" BYTEA NOT NULL UNIQUE,
+
+    "expires_at" TIMESTAMP NOT NULL,
+    "used_at" TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "password_resets";
+-- +goose StatementEnd
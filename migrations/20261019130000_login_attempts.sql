@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "login_attempts" (
+    "id" SERIAL PRIMARY KEY,
+
+    "user_id" INTEGER REFERENCES "users" ON DELETE SET NULL,
+    "email" VARCHAR(300) NOT NULL,
+    "ip" VARCHAR(45) NOT NULL,
+    "succeeded" BOOLEAN NOT NULL,
+
+    "created_at" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX "login_attempts_user_id_idx" ON "login_attempts" ("user_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "login_attempts";
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "refresh_tokens" (
+    "id" SERIAL PRIMARY KEY,
+
+    "user_id" INTEGER NOT NULL REFERENCES "users" ON DELETE CASCADE,
+    "family_id" BYTEA NOT NULL,
+    "token_hash" BYTEA NOT NULL UNIQUE,
+
+    "created_at" TIMESTAMP NOT NULL,
+    "expires_at" TIMESTAMP NOT NULL,
+    "used_at" TIMESTAMP,
+    "revoked_at" TIMESTAMP
+);
+
+CREATE INDEX "refresh_tokens_user_id_idx" ON "refresh_tokens" ("user_id");
+CREATE INDEX "refresh_tokens_family_id_idx" ON "refresh_tokens" ("family_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "refresh_tokens";
+-- +goose StatementEnd
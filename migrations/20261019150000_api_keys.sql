@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "api_keys" (
+    "id" SERIAL PRIMARY KEY,
+
+    "user_id" INTEGER NOT NULL REFERENCES "users" ON DELETE CASCADE,
+    "name" VARCHAR(100) NOT NULL,
+    "prefix" VARCHAR(16) NOT NULL UNIQUE,
+    "key_hash" BYTEA NOT NULL,
+    "scopes" TEXT[] NOT NULL,
+
+    "created_at" TIMESTAMP NOT NULL,
+    "expires_at" TIMESTAMP,
+    "last_used_at" TIMESTAMP
+);
+
+CREATE INDEX "api_keys_user_id_idx" ON "api_keys" ("user_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "api_keys";
+-- +goose StatementEnd
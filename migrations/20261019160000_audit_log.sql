@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "audit_log" (
+    "id" SERIAL PRIMARY KEY,
+
+    -- Not references, so that entries outlive their actors.
+    "actor_id" INTEGER,
+    "api_key_id" INTEGER,
+    "action" VARCHAR(20) NOT NULL,
+    "entity" VARCHAR(50) NOT NULL,
+    "entity_id" INTEGER NOT NULL,
+    "before" JSONB,
+    "after" JSONB,
+    "ip" VARCHAR(45),
+    "request_id" VARCHAR(100),
+
+    "created_at" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX "audit_log_actor_id_idx" ON "audit_log" ("actor_id");
+CREATE INDEX "audit_log_entity_idx" ON "audit_log" ("entity", "entity_id");
+CREATE INDEX "audit_log_created_at_idx" ON "audit_log" ("created_at");
+
+CREATE FUNCTION "audit_log_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_log_append_only"
+BEFORE UPDATE OR DELETE ON "audit_log"
+FOR EACH ROW EXECUTE FUNCTION "audit_log_append_only"();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "audit_log";
+DROP FUNCTION "audit_log_append_only";
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "deleted_at" TIMESTAMP;
+ALTER TABLE "books" ADD COLUMN "deleted_at" TIMESTAMP;
+
+-- Deleted users give up their email for someone else to sign up with.
+ALTER TABLE "users" DROP CONSTRAINT "users_email_key";
+CREATE UNIQUE INDEX "users_email_key" ON "users" ("email") WHERE "deleted_at" IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Deleted rows can neither be dropped, which would cascade to the loans they
+-- kept, nor restored, as their emails may have been taken since.
+DO $$
+BEGIN
+    IF EXISTS (SELECT FROM "users" WHERE "deleted_at" IS NOT NULL)
+        OR EXISTS (SELECT FROM "books" WHERE "deleted_at" IS NOT NULL) THEN
+        RAISE EXCEPTION 'cannot roll back while deleted users or books exist';
+    END IF;
+END;
+$$;
+
+DROP INDEX "users_email_key";
+ALTER TABLE "users" ADD CONSTRAINT "users_email_key" UNIQUE ("email");
+
+ALTER TABLE "books" DROP COLUMN "deleted_at";
+ALTER TABLE "users" DROP COLUMN "deleted_at";
+-- +goose StatementEnd
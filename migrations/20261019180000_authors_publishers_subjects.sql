@@ -0,0 +1,84 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "authors" (
+    "id" SERIAL PRIMARY KEY,
+
+    "name" VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE "publishers" (
+    "id" SERIAL PRIMARY KEY,
+
+    "name" VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE "subjects" (
+    "id" SERIAL PRIMARY KEY,
+
+    "name" VARCHAR(100) NOT NULL UNIQUE
+);
+
+-- The position of a link orders the authors, publishers and subjects of a
+-- book as they were given, so that the first author stays first.
+CREATE TABLE "book_authors" (
+    "book_id" INTEGER NOT NULL REFERENCES "books" ON DELETE CASCADE,
+    "author_id" INTEGER NOT NULL REFERENCES "authors" ON DELETE CASCADE,
+    "position" SMALLINT NOT NULL,
+
+    PRIMARY KEY ("book_id", "author_id")
+);
+CREATE INDEX "book_authors_author_id_idx" ON "book_authors" ("author_id");
+
+CREATE TABLE "book_publishers" (
+    "book_id" INTEGER NOT NULL REFERENCES "books" ON DELETE CASCADE,
+    "publisher_id" INTEGER NOT NULL REFERENCES "publishers" ON DELETE CASCADE,
+    "position" SMALLINT NOT NULL,
+
+    PRIMARY KEY ("book_id", "publisher_id")
+);
+CREATE INDEX "book_publishers_publisher_id_idx" ON "book_publishers" ("publisher_id");
+
+CREATE TABLE "book_subjects" (
+    "book_id" INTEGER NOT NULL REFERENCES "books" ON DELETE CASCADE,
+    "subject_id" INTEGER NOT NULL REFERENCES "subjects" ON DELETE CASCADE,
+    "position" SMALLINT NOT NULL,
+
+    PRIMARY KEY ("book_id", "subject_id")
+);
+CREATE INDEX "book_subjects_subject_id_idx" ON "book_subjects" ("subject_id");
+
+INSERT INTO "authors" ("name")
+SELECT DISTINCT "author" FROM "books" WHERE "author" IS NOT NULL AND "author" <> '';
+INSERT INTO "book_authors" ("book_id", "author_id", "position")
+SELECT "books"."id", "authors"."id", 0
+FROM "books" JOIN "authors" ON "authors"."name" = "books"."author";
+
+ALTER TABLE "books" DROP COLUMN "author";
+ALTER TABLE "books" ADD COLUMN "publication_year" SMALLINT;
+ALTER TABLE "books" ADD COLUMN "edition" VARCHAR(50);
+ALTER TABLE "books" ADD COLUMN "language" VARCHAR(3);
+ALTER TABLE "books" ADD COLUMN "page_count" INTEGER;
+CREATE INDEX "books_language_idx" ON "books" ("language");
+CREATE INDEX "books_publication_year_idx" ON "books" ("publication_year");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "books" DROP COLUMN "page_count";
+ALTER TABLE "books" DROP COLUMN "language";
+ALTER TABLE "books" DROP COLUMN "edition";
+ALTER TABLE "books" DROP COLUMN "publication_year";
+ALTER TABLE "books" ADD COLUMN "author" VARCHAR(100);
+
+-- Books keep only their first author.
+UPDATE "books" SET "author" = "authors"."name"
+FROM "book_authors" JOIN "authors" ON "authors"."id" = "book_authors"."author_id"
+WHERE "book_authors"."book_id" = "books"."id" AND "book_authors"."position" = 0;
+
+DROP TABLE "book_subjects";
+DROP TABLE "book_publishers";
+DROP TABLE "book_authors";
+DROP TABLE "subjects";
+DROP TABLE "publishers";
+DROP TABLE "authors";
+-- +goose StatementEnd
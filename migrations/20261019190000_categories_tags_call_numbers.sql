@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Categories form a tree, in which siblings have distinct names.
+CREATE TABLE "categories" (
+    "id" SERIAL PRIMARY KEY,
+
+    "parent_id" INTEGER REFERENCES "categories",
+    "name" VARCHAR(100) NOT NULL,
+
+    UNIQUE NULLS NOT DISTINCT ("parent_id", "name")
+);
+
+CREATE TABLE "tags" (
+    "id" SERIAL PRIMARY KEY,
+
+    "name" VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE "book_categories" (
+    "book_id" INTEGER NOT NULL REFERENCES "books" ON DELETE CASCADE,
+    "category_id" INTEGER NOT NULL REFERENCES "categories" ON DELETE CASCADE,
+    "position" SMALLINT NOT NULL,
+
+    PRIMARY KEY ("book_id", "category_id")
+);
+CREATE INDEX "book_categories_category_id_idx" ON "book_categories" ("category_id");
+
+CREATE TABLE "book_tags" (
+    "book_id" INTEGER NOT NULL REFERENCES "books" ON DELETE CASCADE,
+    "tag_id" INTEGER NOT NULL REFERENCES "tags" ON DELETE CASCADE,
+    "position" SMALLINT NOT NULL,
+
+    PRIMARY KEY ("book_id", "tag_id")
+);
+CREATE INDEX "book_tags_tag_id_idx" ON "book_tags" ("tag_id");
+
+-- The key of a call number sorts it in shelf order when compared byte by
+-- byte, hence the C collation.
+ALTER TABLE "books" ADD COLUMN "classification" VARCHAR(5);
+ALTER TABLE "books" ADD COLUMN "call_number" VARCHAR(50);
+ALTER TABLE "books" ADD COLUMN "call_number_key" VARCHAR(100) COLLATE "C";
+CREATE INDEX "books_classification_call_number_key_idx" ON "books" ("classification", "call_number_key", "id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "books" DROP COLUMN "call_number_key";
+ALTER TABLE "books" DROP COLUMN "call_number";
+ALTER TABLE "books" DROP COLUMN "classification";
+
+DROP TABLE "book_tags";
+DROP TABLE "book_categories";
+DROP TABLE "tags";
+DROP TABLE "categories";
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Entries may have their IP cleared, so that anonymizing their actor leaves
+-- no trace of where they were, but are otherwise append-only.
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW."ip" IS NULL AND to_jsonb(NEW) - 'ip' = to_jsonb(OLD) - 'ip' THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Names and emails of users are no longer recorded, so scrub those recorded
+-- so far, along with the IPs of users anonymized so far.
+ALTER TABLE "audit_log" DISABLE TRIGGER "audit_log_append_only";
+
+UPDATE "audit_log"
+SET "before" = "before" - 'name' - 'email', "after" = "after" - 'name' - 'email'
+WHERE "entity" = 'user';
+
+UPDATE "audit_log"
+SET "ip" = NULL
+WHERE "actor_id" IN (SELECT "id" FROM "users" WHERE "email" LIKE 'anonymized-%@lms.invalid');
+
+ALTER TABLE "audit_log" ENABLE TRIGGER "audit_log_append_only";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd